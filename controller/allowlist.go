@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"cmp"
+	"errors"
+	"log"
+	"sanitize/data"
+	"strings"
+)
+
+// errEmptyPhrase is the reason a phrase without any characters besides whitespace is refused
+var errEmptyPhrase = errors.New("phrase is empty")
+
+func doAllowlistOperation(operation crudOperation, request AllowList, database data.WordStore) (result AllowList, crudError error) {
+	records, err := database.ListAllowedPhrases()
+	if err != nil {
+		crudError = err
+		return
+	}
+
+	switch operation {
+	case SELECT:
+		for _, v := range records {
+			result.Phrases = append(result.Phrases, v)
+		}
+
+		return result, nil
+	case INSERT:
+		//The phrases of the request are added together, and record a single version
+		var cause error
+		err = database.Batch(func(batch data.WordStore) error {
+			result, cause = AllowList{}, nil
+			if records, err = batch.ListAllowedPhrases(); err != nil {
+				return err
+			}
+
+			for _, rec := range request.Phrases {
+				item := ItemResult{Item: rec}
+				if strings.TrimSpace(rec) == "" {
+					item.Status, item.Message = StatusInvalid, errEmptyPhrase.Error()
+					cause = cmp.Or(cause, errEmptyPhrase)
+				} else if containsPhrase(records, rec) {
+					item.Status, item.Message = StatusDuplicate, "the phrase is already stored"
+					cause = cmp.Or(cause, data.ErrDuplicate)
+				} else if id, err := batch.AddAllowedPhrase(rec); err != nil {
+					log.Printf("Unable to add %s reason %v", rec, err)
+					item.Status, item.Message = statusOf(err), err.Error()
+					cause = cmp.Or(cause, err)
+				} else {
+					records[id] = strings.ToUpper(rec)
+					item.Status = StatusAdded
+					result.Phrases = append(result.Phrases, rec)
+				}
+				result.Results = append(result.Results, item)
+			}
+			return nil
+		})
+		if err != nil {
+			return AllowList{}, err
+		}
+
+		if len(result.Phrases) == 0 {
+			crudError = noneChanged("add entries", cause)
+			return
+		}
+	case UPDATE:
+		if len(request.Phrases)%2 > 0 {
+			crudError = invalidField("phrases", errUnpairedUpdate)
+			return
+		}
+
+		//The phrases of the request are changed in place together, and record a single version
+		var cause error
+		err = database.Batch(func(batch data.WordStore) error {
+			result, cause = AllowList{}, nil
+			if records, err = batch.ListAllowedPhrases(); err != nil {
+				return err
+			}
+
+			for i := 0; i < len(request.Phrases); i += 2 {
+				from, to := request.Phrases[i], request.Phrases[i+1]
+				item := ItemResult{Item: from, Status: StatusUpdated}
+				id, found := findPhrase(records, from)
+				if !found {
+					item.Status, item.Message = StatusNotFound, "the phrase is not stored"
+					cause = cmp.Or(cause, data.ErrNotFound)
+				} else if strings.TrimSpace(to) == "" {
+					item.Status, item.Message = StatusInvalid, errEmptyPhrase.Error()
+					cause = cmp.Or(cause, errEmptyPhrase)
+				} else if !strings.EqualFold(from, to) && containsPhrase(records, to) {
+					item.Status, item.Message = StatusDuplicate, "the new phrase is already stored"
+					cause = cmp.Or(cause, data.ErrDuplicate)
+				} else if err := batch.UpdateAllowedPhrase(id, to); err != nil {
+					log.Printf("Unable to update entry %v: %v", id, err)
+					item.Status, item.Message = statusOf(err), err.Error()
+					cause = cmp.Or(cause, err)
+				} else {
+					//The following pairs are compared against the phrases as they are now
+					records[id] = strings.ToUpper(to)
+					result.Phrases = append(result.Phrases, to)
+				}
+				result.Results = append(result.Results, item)
+			}
+			return nil
+		})
+		if err != nil {
+			return AllowList{}, err
+		}
+
+		if len(result.Phrases) == 0 {
+			crudError = noneChanged("update entries", cause)
+			return
+		}
+	case DELETE:
+		//The phrases of the request are removed together, and record a single version
+		var cause error
+		err = database.Batch(func(batch data.WordStore) error {
+			result, cause = AllowList{}, nil
+			if records, err = batch.ListAllowedPhrases(); err != nil {
+				return err
+			}
+
+			for _, rec := range request.Phrases {
+				item := ItemResult{Item: rec, Status: StatusNotFound, Message: "the phrase is not stored"}
+				itemErr := data.ErrNotFound
+				for id, currentPhrase := range records {
+					if strings.EqualFold(currentPhrase, rec) {
+						itemErr = batch.RemoveAllowedPhrase(id)
+						if itemErr != nil {
+							log.Printf("Unable to remove entry %v: %v", id, itemErr)
+							item.Status, item.Message = statusOf(itemErr), itemErr.Error()
+						} else {
+							item.Status, item.Message = StatusRemoved, ""
+							result.Phrases = append(result.Phrases, rec)
+						}
+					}
+				}
+				cause = cmp.Or(cause, itemErr)
+				result.Results = append(result.Results, item)
+			}
+			return nil
+		})
+		if err != nil {
+			return AllowList{}, err
+		}
+
+		if len(result.Phrases) == 0 {
+			crudError = noneChanged("remove entries", cause)
+			return
+		}
+	default:
+		log.Println("Unsupported operation")
+		return AllowList{}, errors.New("unhandled default case")
+	}
+
+	return result, nil
+}
+
+// containsPhrase reports whether the phrase is one of the stored phrases
+func containsPhrase(records map[uint]string, phrase string) bool {
+	_, found := findPhrase(records, phrase)
+	return found
+}
+
+// findPhrase returns the id of the stored phrase, phrases are compared regardless of their case
+func findPhrase(records map[uint]string, phrase string) (uint, bool) {
+	for id, current := range records {
+		if strings.EqualFold(current, phrase) {
+			return id, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"cmp"
+	"errors"
+	"log"
+	"sanitize/data"
+)
+
+func doCategoryOperation(operation crudOperation, request WordCategories, database data.WordStore) (result WordCategories, crudError error) {
+	if operation == SELECT {
+		categories, err := database.ListCategories()
+		if err != nil {
+			crudError = err
+			return
+		}
+
+		result.Categories = categories
+		return result, nil
+	}
+
+	records, err := database.ListWords()
+	if err != nil {
+		crudError = err
+		return
+	}
+
+	var change func(store data.WordStore, id uint) error
+	switch operation {
+	case INSERT:
+		change = func(store data.WordStore, id uint) error { return store.AddCategories(id, request.Categories) }
+	case DELETE:
+		change = func(store data.WordStore, id uint) error { return store.RemoveCategories(id, request.Categories) }
+	default:
+		log.Println("Unsupported operation")
+		return WordCategories{}, errors.New("unhandled default case")
+	}
+
+	//The words of the request are changed together, and record a single version
+	var cause error
+	err = database.Batch(func(batch data.WordStore) error {
+		result, cause = WordCategories{Categories: request.Categories}, nil
+		if records, err = batch.ListWords(); err != nil {
+			return err
+		}
+
+		for _, rec := range request.Words {
+			item := ItemResult{Item: rec, Status: StatusNotFound, Message: "the word is not stored"}
+			itemErr := data.ErrNotFound
+			for _, currentWord := range records {
+				if !currentWord.Equals(rec) {
+					continue
+				}
+
+				itemErr = change(batch, currentWord.ID)
+				if itemErr != nil {
+					log.Printf("Unable to change the categories of %v: %v", currentWord.ID, itemErr)
+					item.Status, item.Message = statusOf(itemErr), itemErr.Error()
+				} else {
+					item.Status, item.Message = StatusUpdated, ""
+					result.Words = append(result.Words, rec)
+				}
+			}
+			cause = cmp.Or(cause, itemErr)
+			result.Results = append(result.Results, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return WordCategories{}, err
+	}
+
+	if len(result.Words) == 0 {
+		crudError = noneChanged("change categories", cause)
+		return
+	}
+
+	return result, nil
+}
@@ -1,36 +1,287 @@
 package controller
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"log"
 	"net/http"
 	"sanitize/data"
+	"sanitize/logic"
+	"strings"
+	"sync"
+	"time"
 )
 
 type Controller struct {
-	Database *data.SanitizeDB
+	Database data.WordStore
+
+	//defaults holds the default match and mask options of the deployment, a request can override them
+	defaults logic.Options
+
+	//tenantState holds the compiled word lists of the shared tenant, and tenants those of every other tenant that
+	//received a request, keyed by the tenant name. Only configured tenants can receive a request, see resolveTenant
+	tenantState
+	tenants     sync.Map
+	reloadMutex sync.Mutex
+
+	//apiKeys maps the configured API keys to their tenant, no keys means the tenant is taken from the tenant header
+	apiKeys map[string]string
+	//tenantNames holds the tenants the tenant header may name, see SetTenants
+	tenantNames map[string]bool
+}
+
+// compiledList is a version of the word list, the allowlist and the policies of a tenant, compiled
+type compiledList struct {
+	version  uint
+	matcher  *logic.Matcher
+	policies map[string]compiledPolicy
+}
+
+// compiledPolicy is a named policy resolved against its base policies and compiled
+type compiledPolicy struct {
+	matcher *logic.Matcher
+	mask    logic.MaskOptions
+}
+
+// NewController creates the controller and compiles the current word list, so that it is ready to serve requests
+func NewController(db data.WordStore) (*Controller, error) {
+	c := &Controller{Database: db, defaults: logic.Options{Mask: logic.DefaultMaskOptions()}}
+	if err := c.reloadMatcher("startup"); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// SetMaskDefaults configures the default mask options of the deployment, which are used when a request does not
+// override them. The hash key is only required by the hash strategy.
+func (c *Controller) SetMaskDefaults(mask Mask, hashKey string) error {
+	defaults := logic.DefaultMaskOptions()
+	defaults.HashKey = []byte(hashKey)
+
+	options, err := maskOptions(&mask, defaults)
+	if err != nil {
+		return err
+	}
+
+	c.defaults.Mask = options
+	return nil
+}
+
+// SetObfuscationDefault configures whether obfuscated words are matched when a request does not specify it
+func (c *Controller) SetObfuscationDefault(enabled bool) {
+	c.defaults.Obfuscation = enabled
+}
+
+// SetCanonicalizationDefault configures the canonicalization stages applied when a request does not specify them
+func (c *Controller) SetCanonicalizationDefault(stages []string) error {
+	parsed, err := parseStages(stages)
+	if err != nil {
+		return err
+	}
+
+	c.defaults.Canonicalize = parsed
+	return nil
 }
 
-// NewController example
-func NewController(db *data.SanitizeDB) *Controller {
-	return &Controller{Database: db}
+// SetDecodingDefault configures the decodings applied when a request does not specify them, and how many times
+// nested encodings are decoded
+func (c *Controller) SetDecodingDefault(decodings []string, depth int) error {
+	options, err := matchOptions(Matching{Decode: &decodings, DecodeDepth: &depth}, c.defaults)
+	if err != nil {
+		return err
+	}
+
+	c.defaults = options
+	return nil
+}
+
+// SetBlockDefault configures when requests are refused instead of sanitized, when a request does not override it. By
+// default nothing is blocked.
+func (c *Controller) SetBlockDefault(block Block) error {
+	policy, err := blockPolicy(&block, c.defaults.Block)
+	if err != nil {
+		return err
+	}
+
+	c.defaults.Block = policy
+	return nil
+}
+
+// reloadMatcher records the word lists, the allowlists and the policies of the shared tenant and of every known tenant
+// as a new version when they changed, compiles them and swaps them in for the sanitize requests. Reloads are serialized
+// so that an older snapshot can never replace a newer one.
+func (c *Controller) reloadMatcher(reason string) error {
+	c.reloadMutex.Lock()
+	defer c.reloadMutex.Unlock()
+
+	if err := c.reloadTenant("", &c.tenantState, reason); err != nil {
+		return err
+	}
+
+	//The shared word list is part of every tenant's word list
+	var err error
+	c.tenants.Range(func(tenant, state any) bool {
+		err = c.reloadTenant(tenant.(string), state.(*tenantState), reason)
+		return err == nil
+	})
+	return err
+}
+
+// reloadTenant compiles the latest version of the word list, the allowlist and the policies of a single tenant. Every
+// change through the store records its own version, a new version is only recorded here when they were changed directly
+// on the storage or when the shared tenant changed, as the word list and allowlist of a tenant include those of the
+// shared tenant. The caller holds the reload mutex.
+func (c *Controller) reloadTenant(tenant string, state *tenantState, reason string) error {
+	list, err := c.Database.ForTenant(tenant).RecordVersion(reason)
+	if err != nil {
+		return err
+	}
+
+	//The latest version is already compiled when nothing changed since the last reload
+	if current := state.list.Load(); current != nil && list.Number != 0 && current.version == list.Number {
+		return nil
+	}
+
+	compiled, err := compileList(list)
+	if err != nil {
+		return err
+	}
+
+	state.list.Store(compiled)
+	return nil
+}
+
+// patterns converts stored words to the patterns they are matched with
+func patterns(words []data.Word) []logic.Pattern {
+	result := make([]logic.Pattern, 0, len(words))
+	for _, word := range words {
+		result = append(result, logic.Pattern{
+			ID:            word.ID,
+			Value:         word.Sensitive,
+			Type:          word.PatternType,
+			Severity:      word.Severity,
+			Categories:    word.Categories,
+			Replacement:   word.Replacement,
+			CaseSensitive: word.CaseSensitive,
+			Boundary:      word.Boundary,
+			Disabled:      word.Disabled,
+		})
+	}
+	return result
+}
+
+var errUnknownPolicy = errors.New("unknown policy")
+
+// selectPolicy returns the compiled word list and the default options of the tenant's named policy, or of the tenant's
+// global list when no policy is named, together with the version of the word list. The current version is used unless
+// the request pins a version.
+func (c *Controller) selectPolicy(tenant string, matching Matching) (*logic.Matcher, logic.Options, uint, error) {
+	state, err := c.tenant(tenant)
+	if err != nil {
+		return nil, logic.Options{}, 0, err
+	}
+
+	list := state.list.Load()
+	if matching.Version != nil && *matching.Version != list.version {
+		list, err = c.pinnedList(tenant, state, *matching.Version)
+		if err != nil {
+			return nil, logic.Options{}, 0, err
+		}
+	}
+
+	name := strings.ToLower(strings.TrimSpace(matching.Policy))
+	if name == "" || name == data.DefaultPolicy {
+		return list.matcher, c.defaults, list.version, nil
+	}
+
+	p, ok := list.policies[name]
+	if !ok {
+		return nil, logic.Options{}, 0, fmt.Errorf("%w %q", errUnknownPolicy, name)
+	}
+
+	defaults := c.defaults
+	defaults.Mask = defaults.Mask.Merge(p.mask)
+	return p.matcher, defaults, list.version, nil
+}
+
+// afterChange is called after every operation that changed the word list of the tenant, the reason describes the
+// operation in the versions that follow from it. A change to the shared tenant changes the word list of every tenant.
+func (c *Controller) afterChange(tenant string, reason string) {
+	var err error
+	if tenant == "" {
+		err = c.reloadMatcher(reason)
+	} else {
+		//The tenant is loaded when it was not yet, so that the change is recorded right away
+		c.reloadMutex.Lock()
+		state, loaded := c.tenants.Load(tenant)
+		if !loaded {
+			state = &tenantState{}
+		}
+		err = c.reloadTenant(tenant, state.(*tenantState), reason)
+		if err == nil && !loaded {
+			c.tenants.Store(tenant, state)
+		}
+		c.reloadMutex.Unlock()
+	}
+	if err != nil {
+		log.Printf("Unable to reload the word list of tenant %q: %v", tenant, err)
+	}
+}
+
+// Watch reloads the word lists whenever the store reports a change that was made outside of this controller, such as
+// by another instance of the service, until the context is done. The store is checked every interval.
+func (c *Controller) Watch(ctx context.Context, interval time.Duration) error {
+	changes, err := c.Database.Watch(ctx, interval)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for range changes {
+			c.afterChange("", "reload")
+		}
+	}()
+	return nil
+}
+
+// changeReason describes the request that changed the word list, in the versions it records
+func changeReason(ctx *gin.Context) string {
+	return ctx.Request.Method + " " + ctx.FullPath()
+}
+
+// changeStore returns the store of the tenant of the request, which records the request as the reason of the versions
+// of its changes
+func (c *Controller) changeStore(ctx *gin.Context) data.WordStore {
+	return c.Database.ForTenant(tenantOf(ctx)).WithReason(changeReason(ctx))
 }
 
 // ListWords godoc
 //
 // @Summary		Sanitized Words
-// @Description	Returns all the current words that will be used to sanitize text.
+// @Description	Returns all the current words that will be used to sanitize text, the details list every word with its type,
+// severity, categories, replacement, case sensitivity, boundary, enabled state, description, author and timestamps. The
+// optional categories only return the words in at least one of the categories.
 // @Tags			CRUD
 // @Accept		json
 // @Produce		json
+// @Param		category	query	[]string	false	"Category"	collectionFormat(multi)
 // @Success		200	{object}   controller.SanitizeWord
+// @Failure		default	{object}   controller.Problem
 // @Error        500
 // @Router		/words [get]
 func (c *Controller) ListWords(ctx *gin.Context) {
-	operation, err := doCrudOperation(SELECT, SanitizeWord{}, c.Database)
+	request := SanitizeWord{Categories: ctx.QueryArray("category")}
+	if _, err := logic.ParseCategories(request.Categories); err != nil {
+		failed(ctx, "ParseCategories", invalidField("category", err))
+		return
+	}
+
+	operation, err := doCrudOperation(SELECT, request, c.Database.ForTenant(tenantOf(ctx)))
 	if err != nil {
-		log.Printf("Error in doCrudOperation: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{})
+		failed(ctx, "doCrudOperation", err)
 	} else {
 		ctx.JSON(200, operation)
 	}
@@ -40,63 +291,79 @@ func (c *Controller) ListWords(ctx *gin.Context) {
 //
 // @Summary		Add Sanitized Words
 // @Description	Provides the ability to add sanitized words. Returns a list of words that was successfully added.
+// The optional type defines how the words are matched, literal (default), glob where * and ? match word characters, or
+// a regex which is a RE2 regular expression. Invalid patterns are not added. The optional categories are added to every
+// word, such as sql-keyword or profanity. The optional replacement replaces the words instead of the mask, case sensitive
+// words only match the exact case, the boundary none also matches words inside larger words, and disabled words are kept
+// but not matched.
 // @Tags			CRUD
 // @Accept		json
 // @Produce		json
 // @Param		account	body    controller.SanitizeWord	true "Add Sanitized Word"
 // @Success		200	{object}   controller.SanitizeWord
+// @Success		207	{object}   controller.SanitizeWord
+// @Failure		422	{object}   controller.SanitizeWord
+// @Failure		default	{object}   controller.Problem
 // @Error       500
 // @Router		/words [put]
 func (c *Controller) AddWords(ctx *gin.Context) {
 	var request SanitizeWord
 	if err := ctx.ShouldBindJSON(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{})
+		invalidBody(ctx, err)
 		return
 	}
 
 	if len(request.Words) == 0 {
-		ctx.JSON(http.StatusBadRequest, gin.H{})
+		missingField(ctx, "words")
 		return
 	}
 
-	operation, err := doCrudOperation(INSERT, request, c.Database)
-	if err != nil {
-		log.Printf("Error in doCrudOperation: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{})
+	if err := validateWords(request); err != nil {
+		failed(ctx, "validateWords", err)
+		return
+	}
+
+	operation, err := doCrudOperation(INSERT, request, c.changeStore(ctx))
+	c.afterChange(tenantOf(ctx), changeReason(ctx))
+	if err != nil && len(operation.Results) == 0 {
+		failed(ctx, "doCrudOperation", err)
 	} else {
-		ctx.JSON(200, operation)
+		ctx.JSON(itemsStatus(operation.Results), operation)
 	}
 }
 
 // DeleteWords godoc
 //
 // @Summary		Remove Sanitized Words
-// @Description	Provides the ability to add sanitized words. Returns a list of words that was successfully deleted.
+// @Description	Provides the ability to remove sanitized words. Returns a list of words that was successfully deleted.
 // @Tags			CRUD
 // @Accept		json
 // @Produce		json
 // @Param		account	body    controller.SanitizeWord	true "Remove Sanitized Word"
 // @Success		200	{object}   controller.SanitizeWord
+// @Success		207	{object}   controller.SanitizeWord
+// @Failure		422	{object}   controller.SanitizeWord
+// @Failure		default	{object}   controller.Problem
 // @Error       500
 // @Router		/words [delete]
 func (c *Controller) DeleteWords(ctx *gin.Context) {
 	var request SanitizeWord
 	if err := ctx.ShouldBindJSON(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{})
+		invalidBody(ctx, err)
 		return
 	}
 
 	if len(request.Words) == 0 {
-		ctx.JSON(http.StatusBadRequest, gin.H{})
+		missingField(ctx, "words")
 		return
 	}
 
-	operation, err := doCrudOperation(DELETE, request, c.Database)
-	if err != nil {
-		log.Printf("Error in doCrudOperation: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{})
+	operation, err := doCrudOperation(DELETE, request, c.changeStore(ctx))
+	c.afterChange(tenantOf(ctx), changeReason(ctx))
+	if err != nil && len(operation.Results) == 0 {
+		failed(ctx, "doCrudOperation", err)
 	} else {
-		ctx.JSON(200, operation)
+		ctx.JSON(itemsStatus(operation.Results), operation)
 	}
 }
 
@@ -105,32 +372,114 @@ func (c *Controller) DeleteWords(ctx *gin.Context) {
 // @Summary		Update Sanitized Words
 // @Description	Provides the ability to update an existing word(s). The first word in the list is the value that should be
 // updated and the second is the value the first will update to. There is no limitation of the number of expect that all request
-// must contain the first and second word. Returns all values that was updated to.
+// must contain the first and second word. Returns all values that was updated to. The words keep their id and options, and
+// either every word is updated or none. Version 2 of the API replaces the pairs with explicit from and to objects.
 // @Tags		CRUD
 // @Accept		json
 // @Produce		json
 // @Param		account	body    controller.SanitizeWord	true "Update Sanitized Word"
 // @Success		200	{object}   controller.SanitizeWord
+// @Success		207	{object}   controller.SanitizeWord
+// @Failure		422	{object}   controller.SanitizeWord
+// @Failure		default	{object}   controller.Problem
 // @Error       500
 // @Router		/words [post]
 func (c *Controller) UpdateWords(ctx *gin.Context) {
 	var request SanitizeWord
 	if err := ctx.ShouldBindJSON(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{})
+		invalidBody(ctx, err)
 		return
 	}
 
 	if len(request.Words) == 0 {
-		ctx.JSON(http.StatusBadRequest, gin.H{})
+		missingField(ctx, "words")
 		return
 	}
 
-	operation, err := doCrudOperation(UPDATE, request, c.Database)
-	if err != nil {
-		log.Printf("Error in doCrudOperation: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{})
+	if err := validateWords(request); err != nil {
+		failed(ctx, "validateWords", err)
+		return
+	}
+
+	operation, err := doCrudOperation(UPDATE, request, c.changeStore(ctx))
+	c.afterChange(tenantOf(ctx), changeReason(ctx))
+	if err != nil && len(operation.Results) == 0 {
+		failed(ctx, "doCrudOperation", err)
 	} else {
-		ctx.JSON(200, operation)
+		ctx.JSON(itemsStatus(operation.Results), operation)
+	}
+}
+
+// UpdateWordPairs godoc
+//
+// @Summary		Update Sanitized Words
+// @Description	Provides the ability to change the value of existing words, every update changes the word from to the value to.
+// The words keep their id and options, and the new value is validated against the pattern type of the word. All the updates
+// are applied in a single transaction, either every word is updated or none. Returns the outcome of every update, when any
+// update failed the request is refused with 422 and the outcome explains which updates failed.
+// @Tags		CRUD
+// @Accept		json
+// @Produce		json
+// @Param		updates	body    controller.WordUpdates	true "Update Sanitized Words"
+// @Success		200	{object}   controller.WordUpdates
+// @Failure		422	{object}   controller.WordUpdates
+// @Failure		default	{object}   controller.Problem
+// @Error       500
+// @Router		/v2/words [post]
+func (c *Controller) UpdateWordPairs(ctx *gin.Context) {
+	var request WordUpdates
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		invalidBody(ctx, err)
+		return
+	}
+
+	if len(request.Updates) == 0 {
+		missingField(ctx, "updates")
+		return
+	}
+
+	result, err := doUpdateOperation(request, c.changeStore(ctx))
+	c.afterChange(tenantOf(ctx), changeReason(ctx))
+	if err != nil && len(result.Updates) == 0 {
+		failed(ctx, "doUpdateOperation", err)
+	} else {
+		ctx.JSON(itemsStatus(result.results()), result)
+	}
+}
+
+// EnableWords godoc
+//
+// @Summary		Enable Sanitized Words
+// @Description	Provides the ability to enable or disable stored words, a disabled word is kept but not matched until it is
+// enabled again. Returns a list of words that was successfully changed.
+// @Tags			CRUD
+// @Accept		json
+// @Produce		json
+// @Param		state	body    controller.WordState	true "Enable Sanitized Words"
+// @Success		200	{object}   controller.WordState
+// @Success		207	{object}   controller.WordState
+// @Failure		422	{object}   controller.WordState
+// @Failure		default	{object}   controller.Problem
+// @Error       500
+// @Router		/words/enabled [post]
+func (c *Controller) EnableWords(ctx *gin.Context) {
+	var request WordState
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		invalidBody(ctx, err)
+		return
+	}
+
+	if len(request.Words) == 0 {
+		missingField(ctx, "words")
+		return
+	}
+
+	result, err := doEnableOperation(request, c.changeStore(ctx))
+	c.afterChange(tenantOf(ctx), changeReason(ctx))
+	if err != nil && len(result.Results) == 0 {
+		failed(ctx, "doEnableOperation", err)
+	} else {
+		ctx.JSON(itemsStatus(result.Results), result)
 	}
 }
 
@@ -138,32 +487,588 @@ func (c *Controller) UpdateWords(ctx *gin.Context) {
 //
 // @Summary		Sanitize
 // @Description	Provides the ability to sanitize strings based on the stored values. Returns the sanitized list in sequence the
-// requests occurred.
+// requests occurred. The optional mask overrides how matches are replaced for this request, either every character
+// (default), a fixed token, a partial reveal of the first and last characters, a category label or a keyed hash.
+// Setting obfuscation also matches words disguised with separators, inline comments or character substitutions,
+// canonicalize lists the canonicalization stages applied before matching, and decode lists the encodings decoded before
+// matching. Matches that were only found once a sentence was decoded are listed with the decodings that exposed them.
+// The include and exclude categories restrict the words that are matched by their categories, and the optional policy
+// selects the named policy whose words, allowlist and mask options are used instead of the global list. The request
+// is refused with the indexes of the offending sentences, instead of sanitized, when a sentence contains a word of at
+// least the block severity, or at least the block number of matches. The response reports the version of the word list
+// that was used, and the optional version pins an older version.
 // @Tags		Sanitize
 // @Accept		json
 // @Produce		json
 // @Param		sanitize	body    controller.Sanitize	true "Sanitize Request"
 // @Success		200	{object}   controller.Sanitize
+// @Failure		422	{object}   controller.Rejection
+// @Failure		default	{object}   controller.Problem
 // @Error       500
 // @Router		/sanitize [post]
 func (c *Controller) Sanitize(ctx *gin.Context) {
 	var request Sanitize
 
 	if err := ctx.ShouldBindJSON(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{})
+		invalidBody(ctx, err)
+		return
+	}
+
+	if len(request.Sentences) == 0 {
+		missingField(ctx, "sentences")
+		return
+	}
+
+	matcher, defaults, version, err := c.selectPolicy(tenantOf(ctx), request.Matching)
+	if err != nil {
+		failed(ctx, "selectPolicy", err)
+		return
+	}
+
+	if _, err := maskOptions(request.Mask, defaults.Mask); err != nil {
+		failed(ctx, "maskOptions", err)
+		return
+	}
+
+	if _, err := matchOptions(request.Matching, defaults); err != nil {
+		failed(ctx, "matchOptions", err)
+		return
+	}
+
+	if _, err := blockPolicy(request.Block, defaults.Block); err != nil {
+		failed(ctx, "blockPolicy", err)
+		return
+	}
+
+	result, blocked, err := doSanitize(request, matcher, defaults)
+	result.Version = &version
+	if err != nil {
+		failed(ctx, "doSanitize", err)
+	} else if len(blocked) > 0 {
+		ctx.JSON(http.StatusUnprocessableEntity, Rejection{Message: "the request contains blocked content", Sentences: blocked})
+	} else {
+		ctx.JSON(200, result)
+	}
+}
+
+// Detect godoc
+//
+// @Summary		Detect
+// @Description	Provides the ability to find the stored words in strings without altering them. Returns the matches of every
+// string in sequence the requests occurred, with the id of the stored word that matched and the offsets of the match in
+// bytes, runes and UTF-16 code units. Setting obfuscation also matches words disguised with separators, inline comments
+// or character substitutions, canonicalize lists the canonicalization stages applied before matching, and decode lists
+// the encodings decoded before matching. The optional policy selects the named policy whose words are matched, and the
+// optional version pins the version of the word list.
+// @Tags		Sanitize
+// @Accept		json
+// @Produce		json
+// @Param		detect	body    controller.Detect	true "Detect Request"
+// @Success		200	{object}   controller.Detection
+// @Failure		default	{object}   controller.Problem
+// @Error       500
+// @Router		/detect [post]
+func (c *Controller) Detect(ctx *gin.Context) {
+	var request Detect
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		invalidBody(ctx, err)
+		return
+	}
+
+	if len(request.Sentences) == 0 {
+		missingField(ctx, "sentences")
+		return
+	}
+
+	matcher, defaults, version, err := c.selectPolicy(tenantOf(ctx), request.Matching)
+	if err != nil {
+		failed(ctx, "selectPolicy", err)
+		return
+	}
+
+	if _, err := matchOptions(request.Matching, defaults); err != nil {
+		failed(ctx, "matchOptions", err)
+		return
+	}
+
+	result, err := doDetect(request, matcher, defaults)
+	result.Version = version
+	if err != nil {
+		failed(ctx, "doDetect", err)
+	} else {
+		ctx.JSON(200, result)
+	}
+}
+
+// Score godoc
+//
+// @Summary		Score
+// @Description	Provides the ability to rate how much strings look like SQL injection attempts, without altering them. Returns
+// the score of every string in sequence the requests occurred, between 0 and 100, with a risk level and the reason codes of
+// the signals that contributed to it. The score combines the stored words found in the string with comment tokens, stacked
+// statements, tautologies, UNION SELECT chains and unbalanced quotes. The match options work the same as for Detect.
+// @Tags		Sanitize
+// @Accept		json
+// @Produce		json
+// @Param		score	body    controller.Score	true "Score Request"
+// @Success		200	{object}   controller.Scoring
+// @Failure		default	{object}   controller.Problem
+// @Error       500
+// @Router		/score [post]
+func (c *Controller) Score(ctx *gin.Context) {
+	var request Score
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		invalidBody(ctx, err)
 		return
 	}
 
 	if len(request.Sentences) == 0 {
-		ctx.JSON(http.StatusBadRequest, gin.H{})
+		missingField(ctx, "sentences")
+		return
+	}
+
+	matcher, defaults, version, err := c.selectPolicy(tenantOf(ctx), request.Matching)
+	if err != nil {
+		failed(ctx, "selectPolicy", err)
+		return
+	}
+
+	if _, err := matchOptions(request.Matching, defaults); err != nil {
+		failed(ctx, "matchOptions", err)
 		return
 	}
 
-	result, err := doSanitize(request, c.Database)
+	result, err := doScore(request, matcher, defaults)
+	result.Version = version
 	if err != nil {
-		log.Printf("Error in doSanitize: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{})
+		failed(ctx, "doScore", err)
 	} else {
 		ctx.JSON(200, result)
 	}
 }
+
+// ListAllowedPhrases godoc
+//
+// @Summary		Allowlist
+// @Description	Returns all the current allowlist phrases. A match of a sanitized word that is covered by an allowlist phrase is
+// not sanitized, for example the phrase "your order" allows the word ORDER in "your order is on its way".
+// @Tags			Allowlist
+// @Accept		json
+// @Produce		json
+// @Success		200	{object}   controller.AllowList
+// @Failure		default	{object}   controller.Problem
+// @Error        500
+// @Router		/allowlist [get]
+func (c *Controller) ListAllowedPhrases(ctx *gin.Context) {
+	operation, err := doAllowlistOperation(SELECT, AllowList{}, c.Database.ForTenant(tenantOf(ctx)))
+	if err != nil {
+		failed(ctx, "doAllowlistOperation", err)
+	} else {
+		ctx.JSON(200, operation)
+	}
+}
+
+// AddAllowedPhrases godoc
+//
+// @Summary		Add Allowlist Phrases
+// @Description	Provides the ability to add allowlist phrases. Returns a list of phrases that was successfully added.
+// @Tags			Allowlist
+// @Accept		json
+// @Produce		json
+// @Param		allowlist	body    controller.AllowList	true "Add Allowlist Phrase"
+// @Success		200	{object}   controller.AllowList
+// @Success		207	{object}   controller.AllowList
+// @Failure		422	{object}   controller.AllowList
+// @Failure		default	{object}   controller.Problem
+// @Error       500
+// @Router		/allowlist [put]
+func (c *Controller) AddAllowedPhrases(ctx *gin.Context) {
+	c.changeAllowlist(ctx, INSERT)
+}
+
+// UpdateAllowedPhrases godoc
+//
+// @Summary		Update Allowlist Phrases
+// @Description	Provides the ability to update existing allowlist phrase(s). The first phrase in the list is the value that
+// should be updated and the second is the value the first will update to. Returns all values that was updated to.
+// @Tags			Allowlist
+// @Accept		json
+// @Produce		json
+// @Param		allowlist	body    controller.AllowList	true "Update Allowlist Phrase"
+// @Success		200	{object}   controller.AllowList
+// @Success		207	{object}   controller.AllowList
+// @Failure		422	{object}   controller.AllowList
+// @Failure		default	{object}   controller.Problem
+// @Error       500
+// @Router		/allowlist [post]
+func (c *Controller) UpdateAllowedPhrases(ctx *gin.Context) {
+	c.changeAllowlist(ctx, UPDATE)
+}
+
+// DeleteAllowedPhrases godoc
+//
+// @Summary		Remove Allowlist Phrases
+// @Description	Provides the ability to remove allowlist phrases. Returns a list of phrases that was successfully deleted.
+// @Tags			Allowlist
+// @Accept		json
+// @Produce		json
+// @Param		allowlist	body    controller.AllowList	true "Remove Allowlist Phrase"
+// @Success		200	{object}   controller.AllowList
+// @Success		207	{object}   controller.AllowList
+// @Failure		422	{object}   controller.AllowList
+// @Failure		default	{object}   controller.Problem
+// @Error       500
+// @Router		/allowlist [delete]
+func (c *Controller) DeleteAllowedPhrases(ctx *gin.Context) {
+	c.changeAllowlist(ctx, DELETE)
+}
+
+func (c *Controller) changeAllowlist(ctx *gin.Context, operation crudOperation) {
+	var request AllowList
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		invalidBody(ctx, err)
+		return
+	}
+
+	if len(request.Phrases) == 0 {
+		missingField(ctx, "phrases")
+		return
+	}
+
+	result, err := doAllowlistOperation(operation, request, c.changeStore(ctx))
+	c.afterChange(tenantOf(ctx), changeReason(ctx))
+	if err != nil && len(result.Results) == 0 {
+		failed(ctx, "doAllowlistOperation", err)
+	} else {
+		ctx.JSON(itemsStatus(result.Results), result)
+	}
+}
+
+// ListCategories godoc
+//
+// @Summary		Word Categories
+// @Description	Returns all the categories that stored words are part of.
+// @Tags			CRUD
+// @Accept		json
+// @Produce		json
+// @Success		200	{object}   controller.WordCategories
+// @Failure		default	{object}   controller.Problem
+// @Error        500
+// @Router		/words/categories [get]
+func (c *Controller) ListCategories(ctx *gin.Context) {
+	operation, err := doCategoryOperation(SELECT, WordCategories{}, c.Database.ForTenant(tenantOf(ctx)))
+	if err != nil {
+		failed(ctx, "doCategoryOperation", err)
+	} else {
+		ctx.JSON(200, operation)
+	}
+}
+
+// AddCategories godoc
+//
+// @Summary		Add Word Categories
+// @Description	Provides the ability to add categories to stored words. Every category is added to every word, returns a list
+// of words that was successfully changed.
+// @Tags			CRUD
+// @Accept		json
+// @Produce		json
+// @Param		categories	body    controller.WordCategories	true "Add Word Categories"
+// @Success		200	{object}   controller.WordCategories
+// @Success		207	{object}   controller.WordCategories
+// @Failure		422	{object}   controller.WordCategories
+// @Failure		default	{object}   controller.Problem
+// @Error       500
+// @Router		/words/categories [put]
+func (c *Controller) AddCategories(ctx *gin.Context) {
+	c.changeCategories(ctx, INSERT)
+}
+
+// DeleteCategories godoc
+//
+// @Summary		Remove Word Categories
+// @Description	Provides the ability to remove categories from stored words. Returns a list of words that was successfully
+// changed.
+// @Tags			CRUD
+// @Accept		json
+// @Produce		json
+// @Param		categories	body    controller.WordCategories	true "Remove Word Categories"
+// @Success		200	{object}   controller.WordCategories
+// @Success		207	{object}   controller.WordCategories
+// @Failure		422	{object}   controller.WordCategories
+// @Failure		default	{object}   controller.Problem
+// @Error       500
+// @Router		/words/categories [delete]
+func (c *Controller) DeleteCategories(ctx *gin.Context) {
+	c.changeCategories(ctx, DELETE)
+}
+
+func (c *Controller) changeCategories(ctx *gin.Context, operation crudOperation) {
+	var request WordCategories
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		invalidBody(ctx, err)
+		return
+	}
+
+	if len(request.Words) == 0 {
+		missingField(ctx, "words")
+		return
+	}
+
+	if len(request.Categories) == 0 {
+		missingField(ctx, "categories")
+		return
+	}
+
+	if _, err := logic.ParseCategories(request.Categories); err != nil {
+		failed(ctx, "ParseCategories", invalidField("categories", err))
+		return
+	}
+
+	result, err := doCategoryOperation(operation, request, c.changeStore(ctx))
+	c.afterChange(tenantOf(ctx), changeReason(ctx))
+	if err != nil && len(result.Results) == 0 {
+		failed(ctx, "doCategoryOperation", err)
+	} else {
+		ctx.JSON(itemsStatus(result.Results), result)
+	}
+}
+
+// ListPolicies godoc
+//
+// @Summary		Policies
+// @Description	Returns all the named policies, with their own words, removed words, allowlist phrases and mask options.
+// @Tags			Policies
+// @Accept		json
+// @Produce		json
+// @Success		200	{object}   controller.Policies
+// @Failure		default	{object}   controller.Problem
+// @Error        500
+// @Router		/policies [get]
+func (c *Controller) ListPolicies(ctx *gin.Context) {
+	operation, err := doPolicyOperation(SELECT, Policy{}, c.Database.ForTenant(tenantOf(ctx)))
+	if err != nil {
+		failed(ctx, "doPolicyOperation", err)
+	} else {
+		ctx.JSON(200, operation)
+	}
+}
+
+// GetPolicy godoc
+//
+// @Summary		Policy
+// @Description	Returns the named policy, with its own words, removed words, allowlist phrases and mask options.
+// @Tags			Policies
+// @Accept		json
+// @Produce		json
+// @Param		name	path	string	true	"Policy Name"
+// @Success		200	{object}   controller.Policy
+// @Failure		404
+// @Failure		default	{object}   controller.Problem
+// @Error        500
+// @Router		/policies/{name} [get]
+func (c *Controller) GetPolicy(ctx *gin.Context) {
+	operation, err := doPolicyOperation(SELECT, Policy{Name: ctx.Param("name")}, c.Database.ForTenant(tenantOf(ctx)))
+	if err != nil {
+		failed(ctx, "doPolicyOperation", err)
+	} else if len(operation.Policies) == 0 {
+		problem(ctx, http.StatusNotFound, CodeNotFound, "name", "policy not found")
+	} else {
+		ctx.JSON(200, operation.Policies[0])
+	}
+}
+
+// AddPolicy godoc
+//
+// @Summary		Add Policy
+// @Description	Provides the ability to add a named policy. A policy inherits the words, allowlist phrases and mask options of
+// its base policy, where the base policy default refers to the global list. The words of the policy are added to the words
+// of the base policy, the removed words of the base policy are not matched, and the phrases are added to the allowlist.
+// The mask options of the policy override the options of the base policy. Returns the policy that was added.
+// @Tags			Policies
+// @Accept		json
+// @Produce		json
+// @Param		policy	body    controller.Policy	true "Add Policy"
+// @Success		200	{object}   controller.Policy
+// @Failure		default	{object}   controller.Problem
+// @Error       500
+// @Router		/policies [put]
+func (c *Controller) AddPolicy(ctx *gin.Context) {
+	c.changePolicy(ctx, INSERT)
+}
+
+// UpdatePolicy godoc
+//
+// @Summary		Update Policy
+// @Description	Provides the ability to replace the base, mask options, words, removed words and allowlist phrases of the named
+// policy as a whole. Returns the policy as it was updated.
+// @Tags			Policies
+// @Accept		json
+// @Produce		json
+// @Param		policy	body    controller.Policy	true "Update Policy"
+// @Success		200	{object}   controller.Policy
+// @Failure		default	{object}   controller.Problem
+// @Error       500
+// @Router		/policies [post]
+func (c *Controller) UpdatePolicy(ctx *gin.Context) {
+	c.changePolicy(ctx, UPDATE)
+}
+
+// DeletePolicy godoc
+//
+// @Summary		Remove Policy
+// @Description	Provides the ability to remove a named policy, together with its words and allowlist phrases. A policy that is
+// the base of another policy can not be removed.
+// @Tags			Policies
+// @Accept		json
+// @Produce		json
+// @Param		name	path	string	true	"Policy Name"
+// @Success		200	{object}   controller.Policy
+// @Failure		default	{object}   controller.Problem
+// @Error       500
+// @Router		/policies/{name} [delete]
+func (c *Controller) DeletePolicy(ctx *gin.Context) {
+	result, err := doPolicyOperation(DELETE, Policy{Name: ctx.Param("name")}, c.changeStore(ctx))
+	c.afterChange(tenantOf(ctx), changeReason(ctx))
+	if err != nil {
+		failed(ctx, "doPolicyOperation", err)
+	} else {
+		ctx.JSON(200, result.Policies[0])
+	}
+}
+
+func (c *Controller) changePolicy(ctx *gin.Context, operation crudOperation) {
+	var request Policy
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		invalidBody(ctx, err)
+		return
+	}
+
+	if strings.TrimSpace(request.Name) == "" {
+		missingField(ctx, "name")
+		return
+	}
+
+	if _, err := maskOptions(request.Mask, c.defaults.Mask); err != nil {
+		failed(ctx, "maskOptions", err)
+		return
+	}
+
+	for _, words := range request.Words {
+		if err := validateWords(words); err != nil {
+			failed(ctx, "validateWords", err)
+			return
+		}
+	}
+
+	result, err := doPolicyOperation(operation, request, c.changeStore(ctx))
+	c.afterChange(tenantOf(ctx), changeReason(ctx))
+	if err != nil {
+		failed(ctx, "doPolicyOperation", err)
+	} else {
+		ctx.JSON(200, result.Policies[0])
+	}
+}
+
+// ListVersions godoc
+//
+// @Summary		Versions
+// @Description	Returns all the recorded versions of the word list, the newest version first. Every change to the words, the
+// allowlist or the policies records a new version, with the request that changed them as the reason. The version of a
+// tenant also records the version of the shared word list it includes.
+// @Tags			Versions
+// @Accept		json
+// @Produce		json
+// @Success		200	{object}   controller.Versions
+// @Failure		default	{object}   controller.Problem
+// @Error        500
+// @Router		/versions [get]
+func (c *Controller) ListVersions(ctx *gin.Context) {
+	versions, err := c.Database.ForTenant(tenantOf(ctx)).ListVersions()
+	if err != nil {
+		failed(ctx, "ListVersions", err)
+		return
+	}
+
+	result := Versions{Versions: []Version{}}
+	for _, version := range versions {
+		result.Versions = append(result.Versions, fromDataVersion(version))
+	}
+	ctx.JSON(200, result)
+}
+
+// DiffVersions godoc
+//
+// @Summary		Compare Versions
+// @Description	Returns the changes that lead from one recorded version of the word list to another, the words, allowlist
+// phrases and policies that were added, removed or changed. A word is changed when its options differ.
+// @Tags			Versions
+// @Accept		json
+// @Produce		json
+// @Param		from	query	int	true	"From Version"
+// @Param		to	query	int	true	"To Version"
+// @Success		200	{object}   controller.VersionDiff
+// @Failure		default	{object}   controller.Problem
+// @Error        500
+// @Router		/versions/diff [get]
+func (c *Controller) DiffVersions(ctx *gin.Context) {
+	versions := make([]uint, 0, 2)
+	for _, field := range []string{"from", "to"} {
+		if ctx.Query(field) == "" {
+			missingField(ctx, field)
+			return
+		}
+		version, err := parseVersion(field, ctx.Query(field))
+		if err != nil {
+			failed(ctx, "parseVersion", err)
+			return
+		}
+		versions = append(versions, version)
+	}
+
+	diff, err := c.Database.ForTenant(tenantOf(ctx)).DiffVersions(versions[0], versions[1])
+	if err != nil {
+		failed(ctx, "DiffVersions", err)
+	} else {
+		ctx.JSON(200, fromDataDiff(diff))
+	}
+}
+
+// RollbackVersion godoc
+//
+// @Summary		Rollback Version
+// @Description	Provides the ability to restore the words, allowlist phrases and policies of a recorded version. The restored
+// words and policies keep their ids, and the rollback is recorded as a new version. Returns the version that is
+// current after the rollback.
+// @Tags			Versions
+// @Accept		json
+// @Produce		json
+// @Param		version	path	int	true	"Version"
+// @Success		200	{object}   controller.Version
+// @Failure		default	{object}   controller.Problem
+// @Error        500
+// @Router		/versions/{version}/rollback [post]
+func (c *Controller) RollbackVersion(ctx *gin.Context) {
+	version, err := parseVersion("version", ctx.Param("version"))
+	if err != nil {
+		failed(ctx, "parseVersion", err)
+		return
+	}
+
+	db := c.Database.ForTenant(tenantOf(ctx))
+	if err := db.RollbackVersion(version); err != nil {
+		failed(ctx, "RollbackVersion", err)
+		return
+	}
+	c.afterChange(tenantOf(ctx), fmt.Sprintf("rollback to version %d", version))
+
+	versions, err := db.ListVersions()
+	if err != nil {
+		failed(ctx, "ListVersions", err)
+	} else if len(versions) == 0 {
+		ctx.JSON(200, Version{})
+	} else {
+		ctx.JSON(200, fromDataVersion(versions[0]))
+	}
+}
@@ -1,16 +1,27 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
 	"log"
+	"maps"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"sanitize/data"
+	"sanitize/logic"
 	td "sanitize/testdata"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 const databaseName = "test.db"
-const sampleDatabase = "sqlite;test.db"
+const sampleDatabase = "sqlite:test.db"
 
 func TestInsert(t *testing.T) {
 	if _, err := os.Stat(databaseName); err == nil {
@@ -58,6 +69,7 @@ func TestDelete(t *testing.T) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer os.Remove("sql_sensitive_list.json")
 
 	if _, err := os.Stat(databaseName); err == nil {
 		os.Remove(databaseName)
@@ -84,16 +96,14 @@ func TestDelete(t *testing.T) {
 }
 
 func TestUpdate(t *testing.T) {
-	if _, err := os.Stat(databaseName); err == nil {
-		os.Remove(databaseName)
-	}
+	//TestInsertPrimaryKey recreates the database, so it has to run before this test opens it
+	TestInsertPrimaryKey(t)
 
 	db, err := data.Initialize(sampleDatabase)
 	if err != nil {
 		t.Error(err)
 	}
 
-	TestInsertPrimaryKey(t)
 	result, err := doCrudOperation(UPDATE, SanitizeWord{Words: []string{"TestInsert", "TestInsertUPDATED"}}, &db)
 	if err != nil {
 		t.Error(err)
@@ -102,7 +112,7 @@ func TestUpdate(t *testing.T) {
 	result, err = doCrudOperation(SELECT, SanitizeWord{}, &db)
 	present := false
 	for _, word := range result.Words {
-		if word == "TestInsertUPDATED" {
+		if strings.EqualFold(word, "TestInsertUPDATED") {
 			present = true
 			break
 		}
@@ -128,3 +138,1037 @@ func TestUpdateInvalidParameter(t *testing.T) {
 		t.Error("validation fails on incorrect parameters")
 	}
 }
+
+func TestSanitizeReload(t *testing.T) {
+	if _, err := os.Stat(databaseName); err == nil {
+		os.Remove(databaseName)
+	}
+
+	db, err := data.Initialize(sampleDatabase)
+	if err != nil {
+		t.Error(err)
+	}
+
+	c, err := NewController(&db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := Sanitize{Sentences: []string{"drop TestReload now"}}
+	before := c.list.Load().matcher
+	result, _, err := doSanitize(request, before, c.defaults)
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Sentences[0] != "drop TestReload now" {
+		t.Error("Expected sentence to be unchanged, got ", result.Sentences[0])
+	}
+
+	_, err = doCrudOperation(INSERT, SanitizeWord{Words: []string{"TestReload"}}, &db)
+	if err != nil {
+		t.Error(err)
+	}
+	c.afterChange("", "test")
+
+	result, _, err = doSanitize(request, c.list.Load().matcher, c.defaults)
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Sentences[0] != "drop ********** now" {
+		t.Error("Expected sentence to be sanitized, got ", result.Sentences[0])
+	}
+
+	//A snapshot taken before the change must not be affected by it
+	result, _, err = doSanitize(request, before, c.defaults)
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Sentences[0] != "drop TestReload now" {
+		t.Error("Expected old snapshot to be unchanged, got ", result.Sentences[0])
+	}
+}
+
+func TestInsertPatternType(t *testing.T) {
+	if _, err := os.Stat(databaseName); err == nil {
+		os.Remove(databaseName)
+	}
+
+	db, err := data.Initialize(sampleDatabase)
+	if err != nil {
+		t.Error(err)
+	}
+
+	result, err := doCrudOperation(INSERT, SanitizeWord{Words: []string{"SYS*", "SYSTBL*"}, Type: "glob"}, &db)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(result.Words) != 2 {
+		t.Error("Expected 2 words, got ", len(result.Words))
+	}
+
+	_, err = doCrudOperation(INSERT, SanitizeWord{Words: []string{"DROP("}, Type: "regex"}, &db)
+	if err == nil {
+		t.Error("invalid regular expression was added")
+	}
+
+	_, err = doCrudOperation(INSERT, SanitizeWord{Words: []string{"DROP"}, Type: "unknown"}, &db)
+	if err == nil {
+		t.Error("unknown pattern type was accepted")
+	}
+}
+
+func TestMaskOptions(t *testing.T) {
+	defaults := logic.DefaultMaskOptions()
+	defaults.Strategy = logic.MaskToken
+	negative := -1
+
+	options, err := maskOptions(nil, defaults)
+	if err != nil || options.Strategy != logic.MaskToken {
+		t.Error("Expected the deployment defaults without a request mask")
+	}
+
+	options, err = maskOptions(&Mask{Character: "#"}, defaults)
+	if err != nil || options.Strategy != logic.MaskToken || options.Character != '#' {
+		t.Error("Expected the request to override only the provided options")
+	}
+
+	invalid := []Mask{{Strategy: "unknown"}, {Character: "##"}, {Strategy: "hash"}, {RevealFirst: &negative}}
+	for _, mask := range invalid {
+		if _, err := maskOptions(&mask, defaults); err == nil {
+			t.Errorf("Expected %v to be invalid", mask)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	if _, err := os.Stat(databaseName); err == nil {
+		os.Remove(databaseName)
+	}
+
+	db, err := data.Initialize(sampleDatabase)
+	if err != nil {
+		t.Error(err)
+	}
+
+	id, err := db.AddEntry("TestDetect")
+	if err != nil {
+		t.Error(err)
+	}
+
+	c, err := NewController(&db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := doDetect(Detect{Sentences: []string{"😀 testdetect", "clean"}}, c.list.Load().matcher, c.defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Sentences) != 2 || len(result.Sentences[0].Matches) != 1 || len(result.Sentences[1].Matches) != 0 {
+		t.Fatalf("Unexpected detection %v", result)
+	}
+
+	match := result.Sentences[0].Matches[0]
+	if match.ID != id || match.Word != "TESTDETECT" || match.Text != "testdetect" {
+		t.Error("Unexpected match ", match)
+	}
+	if match.Bytes != (Offsets{5, 15}) || match.Runes != (Offsets{2, 12}) || match.UTF16 != (Offsets{3, 13}) {
+		t.Error("Unexpected offsets ", match)
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	if _, err := os.Stat(databaseName); err == nil {
+		os.Remove(databaseName)
+	}
+
+	db, err := data.Initialize(sampleDatabase)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = doCrudOperation(INSERT, SanitizeWord{Words: []string{"ORDER", "ON"}}, &db)
+	if err != nil {
+		t.Error(err)
+	}
+
+	result, err := doAllowlistOperation(INSERT, AllowList{Phrases: []string{"your order", "on its way"}}, &db)
+	if err != nil || len(result.Phrases) != 2 {
+		t.Error("Expected 2 phrases to be added ", err)
+	}
+
+	c, err := NewController(&db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sanitized, _, err := doSanitize(Sanitize{Sentences: []string{"your order is on its way", "order on"}}, c.list.Load().matcher, c.defaults)
+	if err != nil {
+		t.Error(err)
+	}
+	if !slices.Equal(sanitized.Sentences, []string{"your order is on its way", "***** **"}) {
+		t.Error("Unexpected sanitized sentences ", sanitized.Sentences)
+	}
+
+	//A refused update keeps the phrase, an update changes the phrase in place and records a single version
+	result, err = doAllowlistOperation(UPDATE, AllowList{Phrases: []string{"your order", "on its way"}}, &db)
+	if err == nil || len(result.Results) != 1 || result.Results[0].Status != StatusDuplicate {
+		t.Error("Expected the duplicate phrase to be refused ", result, err)
+	}
+	before, err := db.ListAllowedPhrases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions, err := db.ListVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = doAllowlistOperation(UPDATE, AllowList{Phrases: []string{"your order", "my order", "on its way", "on the way"}}, &db)
+	if err != nil || len(result.Phrases) != 2 {
+		t.Error("Expected 2 phrases to be updated ", err)
+	}
+	after, err := db.ListAllowedPhrases()
+	if err != nil || !slices.Equal(slices.Sorted(maps.Keys(after)), slices.Sorted(maps.Keys(before))) ||
+		!slices.Contains(slices.Collect(maps.Values(after)), "MY ORDER") {
+		t.Error("Expected the phrases to keep their ids ", before, after, err)
+	}
+	if updated, err := db.ListVersions(); err != nil || len(updated) != len(versions)+1 {
+		t.Error("Expected a single version of the update ", updated, err)
+	}
+
+	result, err = doAllowlistOperation(UPDATE, AllowList{Phrases: []string{"on the way", "on its way"}}, &db)
+	if err != nil || len(result.Phrases) != 1 {
+		t.Error("Expected 1 phrase to be updated ", err)
+	}
+
+	result, err = doAllowlistOperation(DELETE, AllowList{Phrases: []string{"ON ITS WAY"}}, &db)
+	if err != nil || len(result.Phrases) != 1 {
+		t.Error("Expected 1 phrase to be removed ", err)
+	}
+
+	result, err = doAllowlistOperation(SELECT, AllowList{}, &db)
+	if err != nil || !slices.Equal(result.Phrases, []string{"MY ORDER"}) {
+		t.Error("Unexpected allowlist ", result.Phrases)
+	}
+}
+
+func TestSanitizeObfuscation(t *testing.T) {
+	matcher := logic.NewMatcher(map[uint]string{1: "DROP"})
+	defaults := logic.Options{Mask: logic.DefaultMaskOptions()}
+
+	request := Sanitize{Sentences: []string{"D R 0 P it"}}
+	result, _, err := doSanitize(request, matcher, defaults)
+	if err != nil || result.Sentences[0] != "D R 0 P it" {
+		t.Error("Expected obfuscation to be disabled by default, got ", result.Sentences, err)
+	}
+
+	enabled := true
+	request.Matching.Obfuscation = &enabled
+	result, _, err = doSanitize(request, matcher, defaults)
+	if err != nil || result.Sentences[0] != "******* it" {
+		t.Error("Expected the request to enable obfuscation, got ", result.Sentences, err)
+	}
+
+	disabled := false
+	defaults.Obfuscation = true
+	detected, err := doDetect(Detect{Sentences: request.Sentences, Matching: Matching{Obfuscation: &disabled}}, matcher, defaults)
+	if err != nil || len(detected.Sentences[0].Matches) != 0 {
+		t.Error("Expected the request to disable obfuscation, got ", detected, err)
+	}
+}
+
+func TestMatchOptions(t *testing.T) {
+	defaults := logic.Options{Canonicalize: []logic.Stage{logic.StageNFKC}}
+
+	options, err := matchOptions(Matching{}, defaults)
+	if err != nil || !slices.Equal(options.Canonicalize, defaults.Canonicalize) {
+		t.Error("Expected the deployment defaults without request options")
+	}
+
+	none := []string{}
+	options, err = matchOptions(Matching{Canonicalize: &none}, defaults)
+	if err != nil || len(options.Canonicalize) != 0 {
+		t.Error("Expected an empty list to disable canonicalization")
+	}
+
+	stages := []string{"whitespace", "Invisible"}
+	options, err = matchOptions(Matching{Canonicalize: &stages}, defaults)
+	if err != nil || !slices.Equal(options.Canonicalize, []logic.Stage{logic.StageWhitespace, logic.StageInvisible}) {
+		t.Error("Unexpected stages ", options.Canonicalize, err)
+	}
+
+	stages = []string{"unknown"}
+	if _, err = matchOptions(Matching{Canonicalize: &stages}, defaults); err == nil {
+		t.Error("Expected an unknown stage to be invalid")
+	}
+}
+
+func TestSanitizeDecoded(t *testing.T) {
+	matcher := logic.NewMatcher(map[uint]string{1: "SELECT", 2: "DROP"})
+	defaults := logic.Options{Mask: logic.DefaultMaskOptions()}
+
+	decode := []string{"url", "char"}
+	request := Sanitize{Sentences: []string{"drop it", "id=%53%45%4C%45%43%54 CHAR(68)+CHAR(82)+CHAR(79)+CHAR(80)"}}
+	request.Matching.Decode = &decode
+
+	result, _, err := doSanitize(request, matcher, defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(result.Sentences, []string{"**** it", "id=****************** ***********************************"}) {
+		t.Error("Unexpected sanitized sentences ", result.Sentences)
+	}
+
+	if len(result.Decoded) != 2 || result.Decoded[0].Sentence != 1 || result.Decoded[0].ID != 1 ||
+		!slices.Equal(result.Decoded[0].Decodings, []string{"url"}) || !slices.Equal(result.Decoded[1].Decodings, []string{"char"}) {
+		t.Error("Unexpected decoded matches ", result.Decoded)
+	}
+
+	depth := logic.MaxDecodeDepth + 1
+	request.Matching.DecodeDepth = &depth
+	if _, _, err := doSanitize(request, matcher, defaults); err == nil {
+		t.Error("Expected the decode depth to be limited")
+	}
+}
+
+func TestScore(t *testing.T) {
+	matcher := logic.NewMatcher(map[uint]string{1: "SELECT", 2: "UNION"})
+
+	result, err := doScore(Score{Sentences: []string{"1 UNION SELECT password", "hello world"}}, matcher, logic.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Sentences) != 2 {
+		t.Fatalf("Unexpected scoring %v", result)
+	}
+
+	risky := result.Sentences[0]
+	if risky.Score != 60 || risky.Level != "high" || !slices.Equal(risky.Reasons, []string{"KEYWORD", "UNION_SELECT"}) ||
+		!slices.Equal(risky.Words, []string{"UNION", "SELECT"}) {
+		t.Error("Unexpected score ", risky)
+	}
+
+	clean := result.Sentences[1]
+	if clean.Score != 0 || clean.Level != "none" || len(clean.Reasons) != 0 {
+		t.Error("Unexpected score ", clean)
+	}
+}
+
+func TestBlock(t *testing.T) {
+	if _, err := os.Stat(databaseName); err == nil {
+		os.Remove(databaseName)
+	}
+
+	db, err := data.Initialize(sampleDatabase)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = doCrudOperation(INSERT, SanitizeWord{Words: []string{"BLOCKTEST"}, Severity: "critical"}, &db)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = doCrudOperation(INSERT, SanitizeWord{Words: []string{"MASKTEST"}}, &db)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if _, err = doCrudOperation(INSERT, SanitizeWord{Words: []string{"INVALIDTEST"}, Severity: "severe"}, &db); err == nil {
+		t.Error("Expected an unknown severity to be rejected")
+	}
+
+	c, err := NewController(&db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := Sanitize{Sentences: []string{"masktest", "blocktest", "clean"}}
+	result, blocked, err := doSanitize(request, c.list.Load().matcher, c.defaults)
+	if err != nil || len(blocked) != 0 || !slices.Equal(result.Sentences, []string{"********", "*********", "clean"}) {
+		t.Error("Expected masking to remain the default ", result.Sentences, blocked, err)
+	}
+
+	request.Block = &Block{Severity: "high"}
+	_, blocked, err = doSanitize(request, c.list.Load().matcher, c.defaults)
+	if err != nil || !slices.Equal(blocked, []int{1}) {
+		t.Error("Expected the critical word to be blocked ", blocked, err)
+	}
+
+	err = c.SetBlockDefault(Block{Matches: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Block = nil
+	_, blocked, err = doSanitize(request, c.list.Load().matcher, c.defaults)
+	if err != nil || !slices.Equal(blocked, []int{0, 1}) {
+		t.Error("Expected every sentence with a match to be blocked ", blocked, err)
+	}
+
+	detected, err := doDetect(Detect{Sentences: []string{"blocktest"}}, c.list.Load().matcher, c.defaults)
+	if err != nil || detected.Sentences[0].Matches[0].Severity != "critical" {
+		t.Error("Expected the severity to be detected ", detected, err)
+	}
+}
+
+func TestCategories(t *testing.T) {
+	if _, err := os.Stat(databaseName); err == nil {
+		os.Remove(databaseName)
+	}
+
+	db, err := data.Initialize(sampleDatabase)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = doCrudOperation(INSERT, SanitizeWord{Words: []string{"SELECT", "DROP"}, Categories: []string{"SQL Keyword"}}, &db)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = doCrudOperation(INSERT, SanitizeWord{Words: []string{"DARN"}, Categories: []string{"profanity"}}, &db)
+	if err != nil {
+		t.Error(err)
+	}
+
+	changed, err := doCategoryOperation(INSERT, WordCategories{Words: []string{"drop", "missing"}, Categories: []string{"destructive"}}, &db)
+	if err != nil || !slices.Equal(changed.Words, []string{"drop"}) {
+		t.Error("Expected the category to be added to 1 word ", changed, err)
+	}
+
+	categories, err := doCategoryOperation(SELECT, WordCategories{}, &db)
+	if err != nil || !slices.Equal(categories.Categories, []string{"destructive", "profanity", "sql-keyword"}) {
+		t.Error("Unexpected categories ", categories, err)
+	}
+
+	words, err := doCrudOperation(SELECT, SanitizeWord{Categories: []string{"sql-keyword"}}, &db)
+	if err != nil || !slices.Equal(words.Words, []string{"SELECT", "DROP"}) {
+		t.Error("Unexpected words ", words, err)
+	}
+
+	c, err := NewController(&db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exclude := []string{"sql-keyword"}
+	request := Sanitize{Sentences: []string{"darn, select it"}, Mask: &Mask{Strategy: "category"}}
+	request.ExcludeCategories = &exclude
+	result, _, err := doSanitize(request, c.list.Load().matcher, c.defaults)
+	if err != nil || result.Sentences[0] != "[PROFANITY], select it" {
+		t.Error("Expected only profanity to be masked ", result.Sentences, err)
+	}
+
+	request.ExcludeCategories = nil
+	include := []string{"SQL Keyword"}
+	request.IncludeCategories = &include
+	result, _, err = doSanitize(request, c.list.Load().matcher, c.defaults)
+	if err != nil || result.Sentences[0] != "darn, [SQL_KEYWORD] it" {
+		t.Error("Expected only SQL keywords to be masked ", result.Sentences, err)
+	}
+
+	changed, err = doCategoryOperation(DELETE, WordCategories{Words: []string{"drop"}, Categories: []string{"destructive"}}, &db)
+	if err != nil || len(changed.Words) != 1 {
+		t.Error("Expected the category to be removed ", changed, err)
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	if _, err := os.Stat(databaseName); err == nil {
+		os.Remove(databaseName)
+	}
+
+	db, err := data.Initialize(sampleDatabase)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = doCrudOperation(INSERT, SanitizeWord{Words: []string{"SELECT", "ORDER"}}, &db)
+	if err != nil {
+		t.Error(err)
+	}
+
+	policy := Policy{
+		Name:    "Support",
+		Base:    "default",
+		Mask:    &Mask{Strategy: "token", Token: "[HIDDEN]"},
+		Words:   []SanitizeWord{{Words: []string{"darn"}, Categories: []string{"profanity"}}},
+		Removed: []string{"order"},
+	}
+	result, err := doPolicyOperation(INSERT, policy, &db)
+	if err != nil || len(result.Policies) != 1 || result.Policies[0].Name != "support" {
+		t.Fatal("Expected the policy to be added ", result, err)
+	}
+
+	if _, err = doPolicyOperation(INSERT, Policy{Name: "broken", Base: "unknown"}, &db); err == nil {
+		t.Error("Expected an unknown base policy to be rejected")
+	}
+
+	c, err := NewController(&db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sentences := []string{"darn, select your order"}
+	matcher, defaults, _, err := c.selectPolicy("", Matching{Policy: "support"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sanitized, _, err := doSanitize(Sanitize{Sentences: sentences}, matcher, defaults)
+	if err != nil || sanitized.Sentences[0] != "[HIDDEN], [HIDDEN] your order" {
+		t.Error("Unexpected sanitized sentences with the policy ", sanitized.Sentences, err)
+	}
+
+	matcher, defaults, _, err = c.selectPolicy("", Matching{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sanitized, _, err = doSanitize(Sanitize{Sentences: sentences}, matcher, defaults)
+	if err != nil || sanitized.Sentences[0] != "darn, ****** your *****" {
+		t.Error("Unexpected sanitized sentences without a policy ", sanitized.Sentences, err)
+	}
+
+	if _, _, _, err = c.selectPolicy("", Matching{Policy: "unknown"}); err == nil {
+		t.Error("Expected an unknown policy to be rejected")
+	}
+
+	policy.Name = "support"
+	policy.Removed = nil
+	_, err = doPolicyOperation(UPDATE, policy, &db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.afterChange("", "test")
+
+	matcher, defaults, _, _ = c.selectPolicy("", Matching{Policy: "SUPPORT"})
+	sanitized, _, err = doSanitize(Sanitize{Sentences: sentences}, matcher, defaults)
+	if err != nil || sanitized.Sentences[0] != "[HIDDEN], [HIDDEN] your [HIDDEN]" {
+		t.Error("Expected the updated policy to be used ", sanitized.Sentences, err)
+	}
+
+	result, err = doPolicyOperation(DELETE, Policy{Name: "support"}, &db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = doPolicyOperation(SELECT, Policy{}, &db)
+	if err != nil || len(result.Policies) != 0 {
+		t.Error("Expected the policy to be removed ", result, err)
+	}
+}
+
+func TestTenants(t *testing.T) {
+	if _, err := os.Stat(databaseName); err == nil {
+		os.Remove(databaseName)
+	}
+
+	db, err := data.Initialize(sampleDatabase)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = doCrudOperation(INSERT, SanitizeWord{Words: []string{"SELECT"}}, &db)
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = doCrudOperation(INSERT, SanitizeWord{Words: []string{"acmeword"}}, db.ForTenant("acme"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	c, err := NewController(&db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := Sanitize{Sentences: []string{"select acmeword drop"}}
+	expected := map[string]string{
+		"":       "****** acmeword drop",
+		"acme":   "****** ******** drop",
+		"globex": "****** acmeword drop",
+	}
+	for tenant, sentence := range expected {
+		matcher, defaults, _, err := c.selectPolicy(tenant, Matching{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, _, err := doSanitize(request, matcher, defaults)
+		if err != nil || result.Sentences[0] != sentence {
+			t.Error("Unexpected sanitized sentence of tenant ", tenant, result.Sentences, err)
+		}
+	}
+
+	words, err := doCrudOperation(SELECT, SanitizeWord{}, db.ForTenant("globex"))
+	if err != nil || len(words.Words) != 0 {
+		t.Error("Expected the tenant to have no words of its own ", words, err)
+	}
+
+	//A change to the shared word list reaches every tenant
+	_, err = doCrudOperation(INSERT, SanitizeWord{Words: []string{"drop"}}, &db)
+	if err != nil {
+		t.Error(err)
+	}
+	c.afterChange("", "test")
+	matcher, defaults, _, _ := c.selectPolicy("acme", Matching{})
+	result, _, err := doSanitize(request, matcher, defaults)
+	if err != nil || result.Sentences[0] != "****** ******** ****" {
+		t.Error("Expected the shared word to be masked for the tenant ", result.Sentences, err)
+	}
+
+	//Without configured tenants only the shared tenant is served
+	if _, err := c.resolveTenant("", "acme"); !errors.Is(err, errUnknownTenant) {
+		t.Error("Expected a tenant to be refused when none are configured ", err)
+	}
+	if tenant, err := c.resolveTenant("", ""); err != nil || tenant != "" {
+		t.Error("Expected the shared tenant without the header ", tenant, err)
+	}
+
+	if err := c.SetTenants([]string{"acme", ""}); err == nil {
+		t.Error("Expected an empty tenant to be refused")
+	}
+	if err := c.SetTenants([]string{"Acme", "globex"}); err != nil {
+		t.Fatal(err)
+	}
+	if tenant, err := c.resolveTenant("", " ACME "); err != nil || tenant != "acme" {
+		t.Error("Expected the tenant header to be used ", tenant, err)
+	}
+	if _, err := c.resolveTenant("", "acme/../globex"); err == nil {
+		t.Error("Expected an invalid tenant to be rejected")
+	}
+	if _, err := c.resolveTenant("", ""); !errors.Is(err, errUnknownTenant) {
+		t.Error("Expected the header to be required once tenants are configured ", err)
+	}
+
+	//A request naming an unknown tenant is refused before any word list is compiled for it
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(c.ResolveTenant)
+	r.PUT("/words", c.AddWords)
+	for _, header := range []string{"initech", ""} {
+		request := httptest.NewRequest("PUT", "/words", strings.NewReader(`{"words": ["shared"]}`))
+		request.Header.Set(TenantHeader, header)
+		response := httptest.NewRecorder()
+		r.ServeHTTP(response, request)
+
+		var problem Problem
+		if err := json.Unmarshal(response.Body.Bytes(), &problem); err != nil || response.Code != 400 ||
+			problem.Code != CodeInvalidTenant {
+			t.Error("Expected the unknown tenant to be refused ", header, response.Code, problem, err)
+		}
+	}
+	if _, loaded := c.tenants.Load("initech"); loaded {
+		t.Error("Expected no word list to be kept for an unknown tenant")
+	}
+	if words, err := db.ListWords(); err != nil || slices.ContainsFunc(words, func(word data.Word) bool {
+		return word.Sensitive == "SHARED"
+	}) {
+		t.Error("Expected the shared word list not to be changed without a tenant ", err)
+	}
+
+	if err := c.SetAPIKeys(map[string]string{"secret-acme": "Acme", "secret-admin": ""}); err != nil {
+		t.Fatal(err)
+	}
+	if tenant, err := c.resolveTenant("secret-acme", "globex"); err != nil || tenant != "acme" {
+		t.Error("Expected the tenant of the API key to be used ", tenant, err)
+	}
+	if tenant, err := c.resolveTenant("secret-admin", ""); err != nil || tenant != "" {
+		t.Error("Expected the shared tenant for the admin key ", tenant, err)
+	}
+	if _, err := c.resolveTenant("guess", "acme"); err == nil {
+		t.Error("Expected an unknown API key to be rejected")
+	}
+}
+
+func TestWordMetadata(t *testing.T) {
+	if _, err := os.Stat(databaseName); err == nil {
+		os.Remove(databaseName)
+	}
+
+	db, err := data.Initialize(sampleDatabase)
+	if err != nil {
+		t.Error(err)
+	}
+
+	enabled := true
+	request := SanitizeWord{
+		Words:         []string{"Bob"},
+		CaseSensitive: true,
+		Replacement:   "[NAME]",
+		Enabled:       &enabled,
+		Description:   "A name",
+		Author:        "jane",
+	}
+	if _, err = doCrudOperation(INSERT, request, &db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = doCrudOperation(INSERT, SanitizeWord{Words: []string{"drop"}, Boundary: "none"}, &db); err != nil {
+		t.Fatal(err)
+	}
+
+	listed, err := doCrudOperation(SELECT, SanitizeWord{}, &db)
+	if err != nil || len(listed.Details) != 2 {
+		t.Fatal("Expected the details of 2 words ", listed, err)
+	}
+	detail := listed.Details[0]
+	if detail.Word != "Bob" || !detail.CaseSensitive || detail.Replacement != "[NAME]" || !detail.Enabled ||
+		detail.Author != "jane" || detail.CreatedAt.IsZero() || listed.Details[1].Boundary != "none" {
+		t.Error("Unexpected word details ", listed.Details)
+	}
+
+	c, err := NewController(&db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sentences := Sanitize{Sentences: []string{"Bob and bob backdrop"}}
+	result, _, err := doSanitize(sentences, c.list.Load().matcher, c.defaults)
+	if err != nil || result.Sentences[0] != "[NAME] and bob back****" {
+		t.Error("Expected the word metadata to be honoured ", result.Sentences, err)
+	}
+
+	changed, err := doEnableOperation(WordState{Words: []string{"drop"}, Enabled: false}, &db)
+	if err != nil || len(changed.Words) != 1 {
+		t.Fatal("Expected the word to be disabled ", changed, err)
+	}
+	if _, err = doEnableOperation(WordState{Words: []string{"bob"}, Enabled: false}, &db); err == nil {
+		t.Error("Expected a case sensitive word to require the exact case")
+	}
+	c.afterChange("", "test")
+	result, _, err = doSanitize(sentences, c.list.Load().matcher, c.defaults)
+	if err != nil || result.Sentences[0] != "[NAME] and bob backdrop" {
+		t.Error("Expected a disabled word not to be matched ", result.Sentences, err)
+	}
+}
+
+func TestUpdateWordPairs(t *testing.T) {
+	if _, err := os.Stat(databaseName); err == nil {
+		os.Remove(databaseName)
+	}
+
+	db, err := data.Initialize(sampleDatabase)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = doCrudOperation(INSERT, SanitizeWord{Words: []string{"select", "drop"}}, &db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _ := db.ListWords()
+
+	request := WordUpdates{Updates: []WordUpdate{{From: "select", To: "selected"}, {From: "missing", To: "found"}}}
+	result, err := doUpdateOperation(request, &db)
+	if err == nil || len(result.Updates) != 2 || result.Updates[0].Status != StatusSkipped || result.Updates[1].Status != StatusNotFound {
+		t.Error("Expected the updates to be rolled back ", result, err)
+	}
+
+	request.Updates[1] = WordUpdate{From: "DROP", To: "delete"}
+	result, err = doUpdateOperation(request, &db)
+	if err != nil || result.Updates[0].Status != StatusUpdated || result.Updates[1].Status != StatusUpdated {
+		t.Fatal("Expected the updates to be applied ", result, err)
+	}
+
+	after, _ := db.ListWords()
+	if after[0].ID != before[0].ID || after[0].Sensitive != "SELECTED" || after[1].ID != before[1].ID {
+		t.Error("Expected the words to keep their id ", before, after)
+	}
+}
+
+func TestItemResults(t *testing.T) {
+	if _, err := os.Stat(databaseName); err == nil {
+		os.Remove(databaseName)
+	}
+
+	db, err := data.Initialize(sampleDatabase)
+	if err != nil {
+		t.Error(err)
+	}
+
+	result, err := doCrudOperation(INSERT, SanitizeWord{Words: []string{"select", "SELECT", " "}}, &db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ItemStatus{StatusAdded, StatusDuplicate, StatusInvalid}
+	for i, item := range result.Results {
+		if item.Status != expected[i] {
+			t.Errorf("Expected %s for %q, got %v", expected[i], item.Item, item)
+		}
+	}
+	if itemsStatus(result.Results) != http.StatusMultiStatus {
+		t.Error("Expected a partial success, got ", itemsStatus(result.Results))
+	}
+
+	result, err = doCrudOperation(INSERT, SanitizeWord{Words: []string{"Select"}}, &db)
+	if err == nil || result.Results[0].Status != StatusDuplicate || itemsStatus(result.Results) != http.StatusUnprocessableEntity {
+		t.Error("Expected the duplicate to fail the request ", result, err)
+	}
+	if !errors.Is(err, data.ErrDuplicate) {
+		t.Error("Expected the error to wrap the reason the word was refused ", err)
+	}
+
+	result, err = doCrudOperation(DELETE, SanitizeWord{Words: []string{"select", "missing"}}, &db)
+	if err != nil || result.Results[0].Status != StatusRemoved || result.Results[1].Status != StatusNotFound {
+		t.Error("Unexpected delete results ", result, err)
+	}
+
+	if _, err = doCrudOperation(DELETE, SanitizeWord{Words: []string{"missing"}}, &db); !errors.Is(err, data.ErrNotFound) {
+		t.Error("Expected the error to wrap the reason the word was not removed ", err)
+	}
+
+	phrases, err := doAllowlistOperation(INSERT, AllowList{Phrases: []string{"your order", "YOUR ORDER", " "}}, &db)
+	if err != nil || phrases.Results[0].Status != StatusAdded || phrases.Results[1].Status != StatusDuplicate ||
+		phrases.Results[2].Status != StatusInvalid {
+		t.Error("Unexpected allowlist results ", phrases, err)
+	}
+
+	if status := itemsStatus([]ItemResult{{Status: StatusAdded}}); status != http.StatusOK {
+		t.Error("Expected a success, got ", status)
+	}
+	if status := itemsStatus([]ItemResult{{Status: StatusError}, {Status: StatusSkipped}}); status != http.StatusInternalServerError {
+		t.Error("Expected an error, got ", status)
+	}
+}
+
+// failingStore is a word store whose words can not be read
+type failingStore struct {
+	data.WordStore
+}
+
+var errUnavailable = errors.New("store unavailable")
+
+func (failingStore) ListWords() ([]data.Word, error) {
+	return nil, errUnavailable
+}
+
+func TestCrudStoreError(t *testing.T) {
+	for _, operation := range []crudOperation{SELECT, INSERT, UPDATE, DELETE} {
+		result, err := doCrudOperation(operation, SanitizeWord{Words: []string{"SELECT", "DROP"}}, failingStore{})
+		if !errors.Is(err, errUnavailable) || len(result.Words) != 0 || len(result.Results) != 0 {
+			t.Errorf("Expected operation %d to fail without results %v %v", operation, result, err)
+		}
+	}
+}
+
+func TestProblems(t *testing.T) {
+	if _, err := os.Stat(databaseName); err == nil {
+		os.Remove(databaseName)
+	}
+
+	db, err := data.Initialize(sampleDatabase)
+	if err != nil {
+		t.Error(err)
+	}
+
+	c, err := NewController(&db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.CustomRecovery(Recover), RequestID, c.ResolveTenant)
+	r.POST("/sanitize", c.Sanitize)
+	r.PUT("/words", c.AddWords)
+	r.POST("/words", c.UpdateWords)
+	r.POST("/allowlist", c.UpdateAllowedPhrases)
+	r.GET("/policies/:name", c.GetPolicy)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		code   ProblemCode
+		field  string
+	}{
+		{"POST", "/sanitize", `{"sentences": `, http.StatusBadRequest, CodeInvalidBody, ""},
+		{"POST", "/sanitize", `{"sentences": []}`, http.StatusBadRequest, CodeMissingField, "sentences"},
+		{"POST", "/sanitize", `{"sentences": ["a"], "policy": "unknown"}`, http.StatusBadRequest, CodeUnknownPolicy, "policy"},
+		{"POST", "/sanitize", `{"sentences": ["a"], "decodeDepth": 99}`, http.StatusBadRequest, CodeInvalidField, "decodeDepth"},
+		{"POST", "/sanitize", `{"sentences": ["a"], "mask": {"character": "ab"}}`, http.StatusBadRequest, CodeInvalidField, "mask"},
+		{"PUT", "/words", `{"words": ["a"], "type": "wildcard"}`, http.StatusBadRequest, CodeInvalidField, "type"},
+		{"POST", "/words", `{"words": ["select", "pick", "drop"]}`, http.StatusBadRequest, CodeInvalidField, "words"},
+		{"POST", "/allowlist", `{"phrases": ["your order"]}`, http.StatusBadRequest, CodeInvalidField, "phrases"},
+		{"GET", "/policies/missing", ``, http.StatusNotFound, CodeNotFound, "name"},
+		{"POST", "/sanitize", `{"sentences": ["a"], "version": 99}`, http.StatusBadRequest, CodeUnknownVersion, "version"},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		request.Header.Set(RequestIDHeader, "trace-1")
+		response := httptest.NewRecorder()
+		r.ServeHTTP(response, request)
+
+		var problem Problem
+		if err := json.Unmarshal(response.Body.Bytes(), &problem); err != nil {
+			t.Fatal(err)
+		}
+		if response.Code != test.status || problem.Status != test.status || problem.Code != test.code ||
+			problem.Field != test.field || problem.RequestID != "trace-1" {
+			t.Errorf("Unexpected problem for %s %s %s: %d %v", test.method, test.path, test.body, response.Code, problem)
+		}
+		if response.Header().Get("Content-Type") != "application/problem+json" {
+			t.Error("Expected a problem content type, got ", response.Header().Get("Content-Type"))
+		}
+	}
+
+	request := httptest.NewRequest("POST", "/sanitize", strings.NewReader(`{"sentences": []}`))
+	response := httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	if id := response.Header().Get(RequestIDHeader); len(id) != 32 {
+		t.Error("Expected a generated request id, got ", id)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	if _, err := os.Stat(databaseName); err == nil {
+		os.Remove(databaseName)
+	}
+
+	db, err := data.Initialize(sampleDatabase)
+	if err != nil {
+		t.Error(err)
+	}
+
+	c, err := NewController(&db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID, c.ResolveTenant)
+	r.PUT("/words", c.AddWords)
+	r.DELETE("/words", c.DeleteWords)
+	r.POST("/sanitize", c.Sanitize)
+	r.GET("/versions", c.ListVersions)
+	r.GET("/versions/diff", c.DiffVersions)
+	r.POST("/versions/:version/rollback", c.RollbackVersion)
+
+	serve := func(method string, path string, body string, result any) int {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		response := httptest.NewRecorder()
+		r.ServeHTTP(response, request)
+		if err := json.Unmarshal(response.Body.Bytes(), result); err != nil {
+			t.Fatal(err)
+		}
+		return response.Code
+	}
+
+	//Every request records a single version, however many words it changes
+	var words SanitizeWord
+	serve("PUT", "/words", `{"words": ["versionone", "versiontwo", "versionthree", "versionone"]}`, &words)
+	if len(words.Results) != 4 || words.Results[3].Status != StatusDuplicate {
+		t.Fatalf("Expected the outcome of every word %v", words)
+	}
+	serve("DELETE", "/words", `{"words": ["versiontwo", "versionthree"]}`, &words)
+
+	var versions Versions
+	if serve("GET", "/versions", ``, &versions) != 200 || len(versions.Versions) != 2 ||
+		versions.Versions[0].Version != 2 || versions.Versions[0].Reason != "DELETE /words" ||
+		versions.Versions[1].Reason != "PUT /words" {
+		t.Fatalf("Expected a version for every request %v", versions)
+	}
+
+	var sanitized Sanitize
+	serve("POST", "/sanitize", `{"sentences": ["versionone versiontwo"]}`, &sanitized)
+	if sanitized.Version == nil || *sanitized.Version != 2 || sanitized.Sentences[0] != "********** versiontwo" {
+		t.Fatalf("Expected the current version to be used %v", sanitized)
+	}
+
+	serve("POST", "/sanitize", `{"sentences": ["versionone versiontwo"], "version": 1}`, &sanitized)
+	if *sanitized.Version != 1 || sanitized.Sentences[0] != "********** **********" {
+		t.Fatalf("Expected the pinned version to be used %v", sanitized)
+	}
+
+	var diff VersionDiff
+	if serve("GET", "/versions/diff?from=1&to=2", ``, &diff) != 200 || len(diff.RemovedWords) != 2 ||
+		diff.RemovedWords[0].Word != "VERSIONTWO" || len(diff.AddedWords) != 0 {
+		t.Fatalf("Expected the removed words in the diff %v", diff)
+	}
+
+	var problem Problem
+	if serve("GET", "/versions/diff?from=1", ``, &problem) != 400 || problem.Field != "to" {
+		t.Fatalf("Expected the missing version to be refused %v", problem)
+	}
+	if serve("POST", "/versions/7/rollback", ``, &problem) != 404 || problem.Code != CodeNotFound {
+		t.Fatalf("Expected an unknown version not to be found %v", problem)
+	}
+
+	var version Version
+	if serve("POST", "/versions/1/rollback", ``, &version) != 200 || version.Version != 3 ||
+		version.Reason != "rollback to version 1" {
+		t.Fatalf("Expected the rollback to record a version %v", version)
+	}
+
+	//A request that changes nothing records no version
+	serve("DELETE", "/words", `{"words": ["unknown"]}`, &problem)
+	serve("POST", "/sanitize", `{"sentences": ["versionone versiontwo"]}`, &sanitized)
+	if *sanitized.Version != 3 || sanitized.Sentences[0] != "********** **********" {
+		t.Fatalf("Expected the rolled back words to be used %v", sanitized)
+	}
+}
+
+func TestWordStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.json")
+	store, err := data.OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewController(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err = c.Watch(ctx, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID, c.ResolveTenant)
+	r.PUT("/words", c.AddWords)
+	r.POST("/sanitize", c.Sanitize)
+
+	serve := func(method string, path string, body string, result any) int {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		response := httptest.NewRecorder()
+		r.ServeHTTP(response, request)
+		if err := json.Unmarshal(response.Body.Bytes(), result); err != nil {
+			t.Fatal(err)
+		}
+		return response.Code
+	}
+
+	var words SanitizeWord
+	if serve("PUT", "/words", `{"words": ["select"]}`, &words) != 200 {
+		t.Fatalf("Expected the word to be added %v", words)
+	}
+
+	//Another instance of the service changes the file
+	other, err := data.OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = other.AddWord(data.Word{Sensitive: "drop", PatternType: logic.Literal}); err != nil {
+		t.Fatal(err)
+	}
+
+	var sanitized Sanitize
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		serve("POST", "/sanitize", `{"sentences": ["select drop"]}`, &sanitized)
+		if sanitized.Sentences[0] == "****** ****" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the changed file to be reloaded %v", sanitized)
+		}
+	}
+	if *sanitized.Version != 2 {
+		t.Fatalf("Expected the reload to record a version %v", *sanitized.Version)
+	}
+}
@@ -1,11 +1,13 @@
 package controller
 
 import (
+	"cmp"
 	"errors"
+	"fmt"
 	"log"
-	"runtime/debug"
 	"sanitize/data"
-	"strings"
+	"sanitize/logic"
+	"slices"
 )
 
 type crudOperation int
@@ -17,81 +19,147 @@ const (
 	DELETE
 )
 
-func doCrudOperation(operation crudOperation, request SanitizeWord, database *data.SanitizeDB) (result SanitizeWord, crudError error) {
-	defer func() {
-		if r := recover(); r != nil {
-			debug.PrintStack()
-			crudError = errors.New("an error occurred, while performing crud operation")
-		}
-	}()
+// errUnpairedUpdate is the reason an update is refused whose list of values is not made up of pairs
+var errUnpairedUpdate = errors.New("update parameters not correctly specified, every value needs a new value")
+
+// errNoItems is the cause of an operation that did not change anything because the request has no items
+var errNoItems = errors.New("the request has no items")
+
+// noneChanged is the error of an operation that did not change any item of the request, it wraps the reason the first
+// item failed
+func noneChanged(action string, cause error) error {
+	if cause == nil {
+		cause = errNoItems
+	}
+	return fmt.Errorf("unable to %s: %w", action, cause)
+}
 
-	records, err := database.ListRecords()
+func doCrudOperation(operation crudOperation, request SanitizeWord, database data.WordStore) (result SanitizeWord, crudError error) {
+	records, err := database.ListWords()
 	if err != nil {
-		crudError = err
+		return SanitizeWord{}, err
 	}
 
 	switch operation {
 	case SELECT:
+		categories, err := logic.ParseCategories(request.Categories)
+		if err != nil {
+			crudError = err
+			return
+		}
+
 		for _, v := range records {
-			result.Words = append(result.Words, v)
+			if len(categories) > 0 && !slices.ContainsFunc(v.Categories, func(category string) bool {
+				return slices.Contains(categories, category)
+			}) {
+				continue
+			}
+			result.Words = append(result.Words, v.Sensitive)
+			result.Details = append(result.Details, wordDetail(v))
 		}
 
 		return result, nil
 	case INSERT:
-		for _, rec := range request.Words {
-			_, err = database.AddEntry(rec)
-			if err != nil {
-				log.Printf("Unable to add %s reason %v", rec, err)
-			} else {
-				result.Words = append(result.Words, rec)
+		words, err := toDataWords(request)
+		if err != nil {
+			crudError = err
+			return
+		}
+
+		//The words of the request are added together, and record a single version
+		var cause error
+		err = database.Batch(func(batch data.WordStore) error {
+			result, cause = SanitizeWord{}, nil
+			if records, err = batch.ListWords(); err != nil {
+				return err
 			}
+
+			for _, word := range words {
+				item := ItemResult{Item: word.Sensitive}
+				if err := logic.ValidatePattern(word.Sensitive, word.PatternType); err != nil {
+					item.Status, item.Message = StatusInvalid, err.Error()
+					cause = cmp.Or(cause, err)
+				} else if slices.ContainsFunc(records, func(current data.Word) bool { return current.Equals(word.Sensitive) }) {
+					item.Status, item.Message = StatusDuplicate, "the word is already stored"
+					cause = cmp.Or(cause, data.ErrDuplicate)
+				} else if word.ID, err = batch.AddWord(word); err != nil {
+					log.Printf("Unable to add %s reason %v", word.Sensitive, err)
+					item.Status, item.Message = statusOf(err), err.Error()
+					cause = cmp.Or(cause, err)
+				} else {
+					//Later words of the same request are compared against the words added so far
+					records = append(records, word)
+					item.Status = StatusAdded
+					result.Words = append(result.Words, word.Sensitive)
+				}
+				result.Results = append(result.Results, item)
+			}
+			return nil
+		})
+		if err != nil {
+			return SanitizeWord{}, err
 		}
 
 		if len(result.Words) == 0 {
-			crudError = errors.New("unable to add entries")
+			crudError = noneChanged("add entries", cause)
 			return
 		}
 	case UPDATE:
-		var removed bool
-
 		if len(request.Words)%2 > 0 {
-			crudError = errors.New("update parameters not correctly specified")
+			crudError = invalidField("words", errUnpairedUpdate)
 			return
 		}
 
-		for _, rec := range request.Words {
-			if !removed {
-				result, err = doCrudOperation(DELETE, SanitizeWord{Words: []string{rec}}, database)
-				if err != nil && result.Words == nil || len(result.Words) == 0 {
-					crudError = errors.New("unable to remove entries")
-					return
-				}
-				removed = true
-			} else {
-				result, err = doCrudOperation(INSERT, SanitizeWord{Words: []string{rec}}, database)
-				if err != nil && result.Words == nil || len(result.Words) == 0 {
-					crudError = errors.New("unable to add entries")
-					return
-				}
-				removed = false
-			}
+		//The flat list holds the pairs of the first version of the API, every word is followed by its new value
+		var updates WordUpdates
+		for i := 0; i < len(request.Words); i += 2 {
+			updates.Updates = append(updates.Updates, WordUpdate{From: request.Words[i], To: request.Words[i+1]})
+		}
+
+		updated, err := doUpdateOperation(updates, database)
+		result.Results = updated.results()
+		if err != nil {
+			crudError = err
+			return
+		}
+		for _, update := range updated.Updates {
+			result.Words = append(result.Words, update.To)
 		}
 	case DELETE:
-		for _, rec := range request.Words {
-			for id, currentWord := range records {
-				if currentWord == strings.ToUpper(rec) {
-					deleteErr := database.RemoveEntry(id)
-					if deleteErr != nil {
-						log.Printf("Unable to remove entry %v: %v", id, deleteErr)
-					} else {
-						result.Words = append(result.Words, rec)
+		//The words of the request are removed together, and record a single version
+		var cause error
+		err = database.Batch(func(batch data.WordStore) error {
+			result, cause = SanitizeWord{}, nil
+			if records, err = batch.ListWords(); err != nil {
+				return err
+			}
+
+			for _, rec := range request.Words {
+				item := ItemResult{Item: rec, Status: StatusNotFound, Message: "the word is not stored"}
+				itemErr := data.ErrNotFound
+				for _, currentWord := range records {
+					if currentWord.Equals(rec) {
+						itemErr = batch.RemoveEntry(currentWord.ID)
+						if itemErr != nil {
+							log.Printf("Unable to remove entry %v: %v", currentWord.ID, itemErr)
+							item.Status, item.Message = statusOf(itemErr), itemErr.Error()
+						} else {
+							item.Status, item.Message = StatusRemoved, ""
+							result.Words = append(result.Words, rec)
+						}
 					}
 				}
+				cause = cmp.Or(cause, itemErr)
+				result.Results = append(result.Results, item)
 			}
+			return nil
+		})
+		if err != nil {
+			return SanitizeWord{}, err
 		}
 
 		if len(result.Words) == 0 {
-			crudError = errors.New("unable remove entries")
+			crudError = noneChanged("remove entries", cause)
 			return
 		}
 	default:
@@ -101,3 +169,195 @@ func doCrudOperation(operation crudOperation, request SanitizeWord, database *da
 
 	return result, nil
 }
+
+// doUpdateOperation changes the values of the stored words in place, in a single transaction. Either every word is
+// changed or none, and the outcome of every update is returned in the same order as the request. An error is returned
+// when the updates were rolled back.
+func doUpdateOperation(request WordUpdates, database data.WordStore) (result WordUpdates, crudError error) {
+	records, err := database.ListWords()
+	if err != nil {
+		crudError = err
+		return
+	}
+
+	//Every update is checked before the database is changed, so that the reason of every failed update is known
+	entries := make([]data.EntryUpdate, 0, len(request.Updates))
+	var failed bool
+	var cause error
+	for _, update := range request.Updates {
+		outcome := WordUpdate{From: update.From, To: update.To}
+		index := slices.IndexFunc(records, func(current data.Word) bool { return current.Equals(update.From) })
+		if index < 0 {
+			outcome.Status, outcome.Message = StatusNotFound, "the word is not stored"
+			cause = cmp.Or(cause, data.ErrNotFound)
+		} else if err := logic.ValidatePattern(update.To, records[index].PatternType); err != nil {
+			outcome.Status, outcome.Message = StatusInvalid, err.Error()
+			cause = cmp.Or(cause, err)
+		} else if slices.ContainsFunc(records, func(current data.Word) bool {
+			return current.ID != records[index].ID && current.Equals(update.To)
+		}) {
+			outcome.Status, outcome.Message = StatusDuplicate, "the new value is already stored"
+			cause = cmp.Or(cause, data.ErrDuplicate)
+		} else {
+			entries = append(entries, data.EntryUpdate{ID: records[index].ID, Value: update.To})
+		}
+		failed = failed || outcome.Status != ""
+		result.Updates = append(result.Updates, outcome)
+	}
+
+	if !failed {
+		errs, err := database.UpdateEntries(entries)
+		for i := range result.Updates {
+			if errs[i] != nil {
+				result.Updates[i].Status, result.Updates[i].Message = statusOf(errs[i]), errs[i].Error()
+			} else {
+				result.Updates[i].Status = StatusUpdated
+			}
+		}
+		if err != nil {
+			log.Printf("Unable to update entries: %v", err)
+			failed = true
+			cause = err
+		}
+	}
+
+	if failed {
+		for i := range result.Updates {
+			if result.Updates[i].Status == "" || result.Updates[i].Status == StatusUpdated {
+				result.Updates[i].Status, result.Updates[i].Message = StatusSkipped, "not updated, another update failed"
+			}
+		}
+		crudError = noneChanged("update entries", cause)
+	}
+	return result, crudError
+}
+
+// validateWords checks the options of a request that adds or updates words, before any word is changed
+func validateWords(request SanitizeWord) error {
+	if _, err := logic.ParsePatternType(request.Type); err != nil {
+		return invalidField("type", err)
+	}
+	if _, err := logic.ParseSeverity(request.Severity); err != nil {
+		return invalidField("severity", err)
+	}
+	if _, err := logic.ParseCategories(request.Categories); err != nil {
+		return invalidField("categories", err)
+	}
+	if _, err := logic.ParseBoundary(request.Boundary); err != nil {
+		return invalidField("boundary", err)
+	}
+	return nil
+}
+
+// toDataWords converts the words of a request to the words stored in the database, every word gets the options of
+// the request
+func toDataWords(request SanitizeWord) ([]data.Word, error) {
+	if err := validateWords(request); err != nil {
+		return nil, err
+	}
+	patternType, _ := logic.ParsePatternType(request.Type)
+	severity, _ := logic.ParseSeverity(request.Severity)
+	boundary, _ := logic.ParseBoundary(request.Boundary)
+
+	words := make([]data.Word, 0, len(request.Words))
+	for _, rec := range request.Words {
+		words = append(words, data.Word{
+			Sensitive:     rec,
+			PatternType:   patternType,
+			Severity:      severity,
+			Categories:    request.Categories,
+			Replacement:   request.Replacement,
+			CaseSensitive: request.CaseSensitive,
+			Boundary:      boundary,
+			Disabled:      request.Enabled != nil && !*request.Enabled,
+			Description:   request.Description,
+			Author:        request.Author,
+		})
+	}
+	return words, nil
+}
+
+// wordOptions returns the options of a stored word as a request without words, see toDataWords
+func wordOptions(word data.Word) SanitizeWord {
+	options := SanitizeWord{
+		Type:          string(word.PatternType),
+		Severity:      word.Severity.String(),
+		Categories:    word.Categories,
+		Replacement:   word.Replacement,
+		CaseSensitive: word.CaseSensitive,
+		Boundary:      string(word.Boundary),
+		Description:   word.Description,
+		Author:        word.Author,
+	}
+	if word.Disabled {
+		enabled := false
+		options.Enabled = &enabled
+	}
+	return options
+}
+
+// wordDetail converts a stored word to its response
+func wordDetail(word data.Word) WordDetail {
+	return WordDetail{
+		ID:            word.ID,
+		Word:          word.Sensitive,
+		Type:          string(word.PatternType),
+		Severity:      word.Severity.String(),
+		Categories:    word.Categories,
+		Replacement:   word.Replacement,
+		CaseSensitive: word.CaseSensitive,
+		Boundary:      string(word.Boundary),
+		Enabled:       !word.Disabled,
+		Description:   word.Description,
+		Author:        word.Author,
+		CreatedAt:     word.CreatedAt,
+		UpdatedAt:     word.UpdatedAt,
+	}
+}
+
+// doEnableOperation enables or disables the stored words of the request, and returns the words that were changed
+func doEnableOperation(request WordState, database data.WordStore) (result WordState, crudError error) {
+	records, err := database.ListWords()
+	if err != nil {
+		crudError = err
+		return
+	}
+
+	//The words of the request are changed together, and record a single version
+	var cause error
+	err = database.Batch(func(batch data.WordStore) error {
+		result, cause = WordState{Enabled: request.Enabled}, nil
+		if records, err = batch.ListWords(); err != nil {
+			return err
+		}
+
+		for _, rec := range request.Words {
+			item := ItemResult{Item: rec, Status: StatusNotFound, Message: "the word is not stored"}
+			itemErr := data.ErrNotFound
+			for _, currentWord := range records {
+				if !currentWord.Equals(rec) {
+					continue
+				}
+				if itemErr = batch.SetEnabled(currentWord.ID, request.Enabled); itemErr != nil {
+					log.Printf("Unable to change entry %v: %v", currentWord.ID, itemErr)
+					item.Status, item.Message = statusOf(itemErr), itemErr.Error()
+				} else {
+					item.Status, item.Message = StatusUpdated, ""
+					result.Words = append(result.Words, rec)
+				}
+			}
+			cause = cmp.Or(cause, itemErr)
+			result.Results = append(result.Results, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return WordState{}, err
+	}
+
+	if len(result.Words) == 0 {
+		crudError = noneChanged("change entries", cause)
+		return
+	}
+	return result, nil
+}
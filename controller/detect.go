@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"sanitize/logic"
+)
+
+func doDetect(request Detect, matcher *logic.Matcher, defaults logic.Options) (result Detection, returnError error) {
+	options, err := matchOptions(request.Matching, defaults)
+	if err != nil {
+		returnError = err
+		return
+	}
+
+	detected, err := matcher.DetectWith(request.Sentences, options)
+	if err != nil {
+		returnError = err
+		return
+	}
+
+	result.Sentences = make([]DetectedSentence, 0, len(detected))
+	for _, matches := range detected {
+		sentence := DetectedSentence{Matches: make([]DetectedMatch, 0, len(matches))}
+		for _, match := range matches {
+			detected := DetectedMatch{
+				ID:         match.Pattern.ID,
+				Word:       match.Pattern.Value,
+				Severity:   max(match.Pattern.Severity, logic.SeverityLow).String(),
+				Categories: match.Pattern.Categories,
+				Text:       match.Text,
+				Bytes:      Offsets{Start: match.Start, End: match.End},
+				Runes:      Offsets{Start: match.RuneStart, End: match.RuneEnd},
+				UTF16:      Offsets{Start: match.UTF16Start, End: match.UTF16End},
+			}
+			if len(match.Decodings) > 0 {
+				detected.Decodings = decodingNames(match.Decodings)
+			}
+			sentence.Matches = append(sentence.Matches, detected)
+		}
+		result.Sentences = append(result.Sentences, sentence)
+	}
+
+	return
+}
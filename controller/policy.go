@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"reflect"
+	"sanitize/data"
+	"sanitize/logic"
+	"strings"
+)
+
+func doPolicyOperation(operation crudOperation, request Policy, database data.WordStore) (result Policies, crudError error) {
+	switch operation {
+	case SELECT:
+		policies, err := database.ListPolicies()
+		if err != nil {
+			crudError = err
+			return
+		}
+
+		result.Policies = []Policy{}
+		for _, p := range policies {
+			if request.Name == "" || strings.EqualFold(p.Name, strings.TrimSpace(request.Name)) {
+				result.Policies = append(result.Policies, fromDataPolicy(p))
+			}
+		}
+
+		return result, nil
+	case INSERT, UPDATE:
+		p, err := toDataPolicy(request)
+		if err != nil {
+			crudError = err
+			return
+		}
+
+		if operation == INSERT {
+			_, err = database.AddPolicy(p)
+		} else {
+			err = database.UpdatePolicy(p)
+		}
+		if err != nil {
+			crudError = err
+			return
+		}
+
+		stored, err := database.GetPolicy(p.Name)
+		if err != nil {
+			crudError = err
+			return
+		}
+		result.Policies = []Policy{fromDataPolicy(stored)}
+	case DELETE:
+		err := database.RemovePolicy(request.Name)
+		if err != nil {
+			crudError = err
+			return
+		}
+		result.Policies = []Policy{{Name: request.Name}}
+	default:
+		log.Println("Unsupported operation")
+		return Policies{}, errors.New("unhandled default case")
+	}
+
+	return result, nil
+}
+
+// toDataPolicy converts a policy request to the policy stored in the database
+func toDataPolicy(request Policy) (data.Policy, error) {
+	mask, err := maskOverride(request.Mask)
+	if err != nil {
+		return data.Policy{}, err
+	}
+
+	result := data.Policy{
+		Name:     request.Name,
+		Base:     request.Base,
+		Mask:     mask,
+		Excluded: request.Removed,
+		Phrases:  request.Phrases,
+	}
+
+	for _, group := range request.Words {
+		words, err := toDataWords(group)
+		if err != nil {
+			return data.Policy{}, err
+		}
+		result.Words = append(result.Words, words...)
+	}
+
+	return result, nil
+}
+
+// fromDataPolicy converts a stored policy to its response, consecutive words with the same options are grouped
+// together
+func fromDataPolicy(p data.Policy) Policy {
+	result := Policy{Name: p.Name, Base: p.Base, Removed: p.Excluded, Phrases: p.Phrases}
+
+	if !reflect.DeepEqual(p.Mask, logic.MaskOptions{}) {
+		result.Mask = &Mask{
+			Strategy:    string(p.Mask.Strategy),
+			Token:       p.Mask.Token,
+			RevealFirst: p.Mask.RevealFirst,
+			RevealLast:  p.Mask.RevealLast,
+			Label:       p.Mask.Label,
+		}
+		if p.Mask.Character != 0 {
+			result.Mask.Character = string(p.Mask.Character)
+		}
+	}
+
+	for _, word := range p.Words {
+		group := wordOptions(word)
+
+		if last := len(result.Words) - 1; last >= 0 {
+			previous := result.Words[last]
+			previous.Words = nil
+			if reflect.DeepEqual(previous, group) {
+				result.Words[last].Words = append(result.Words[last].Words, word.Sensitive)
+				continue
+			}
+		}
+		group.Words = []string{word.Sensitive}
+		result.Words = append(result.Words, group)
+	}
+
+	return result
+}
@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"sanitize/data"
+	"sanitize/logic"
+)
+
+// ProblemCode is a stable machine readable code identifying why a request failed
+type ProblemCode string
+
+const (
+	// CodeInvalidBody is reported when the body of the request is not valid JSON of the expected shape
+	CodeInvalidBody ProblemCode = "INVALID_BODY"
+	// CodeMissingField is reported when a required field of the request is empty
+	CodeMissingField ProblemCode = "MISSING_FIELD"
+	// CodeInvalidField is reported when a field of the request has an unsupported value
+	CodeInvalidField ProblemCode = "INVALID_FIELD"
+	// CodeInvalidPattern is reported when a word is not a valid pattern of its type
+	CodeInvalidPattern ProblemCode = "INVALID_PATTERN"
+	// CodeUnknownPolicy is reported when the request selects a policy that does not exist
+	CodeUnknownPolicy ProblemCode = "UNKNOWN_POLICY"
+	// CodeUnknownVersion is reported when the request pins a version of the word list that was not recorded
+	CodeUnknownVersion ProblemCode = "UNKNOWN_VERSION"
+	// CodeInvalidTenant is reported when the tenant header is not a valid tenant name
+	CodeInvalidTenant ProblemCode = "INVALID_TENANT"
+	// CodeUnauthorized is reported when API keys are configured and the request has no known key
+	CodeUnauthorized ProblemCode = "UNAUTHORIZED"
+	// CodeNotFound is reported when the value that should be read or changed is not stored
+	CodeNotFound ProblemCode = "NOT_FOUND"
+	// CodeDuplicate is reported when the value that should be stored already exists
+	CodeDuplicate ProblemCode = "DUPLICATE"
+	// CodeInternal is reported for every other failure, the details are only logged
+	CodeInternal ProblemCode = "INTERNAL_ERROR"
+)
+
+const (
+	// RequestIDHeader is the header that identifies a request in the logs and in problem responses
+	RequestIDHeader = "X-Request-ID"
+
+	//requestIDKey is the key the request ID is stored under in the gin context
+	requestIDKey = "requestId"
+)
+
+// requestIDFormat restricts the request IDs accepted from clients, so that they can be logged safely
+var requestIDFormat = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,128}$`)
+
+// Problem is an RFC 7807 problem details response, which is returned with the content type application/problem+json
+type Problem struct {
+	Type   string `json:"type" example:"about:blank"`
+	Title  string `json:"title" example:"Bad Request"`
+	Status int    `json:"status" example:"400"`
+	Detail string `json:"detail,omitempty"`
+	// Code is the stable machine readable code of the problem, the documentation lists the ProblemCode constants
+	Code ProblemCode `json:"code"`
+	// Field is the JSON name of the field of the request that caused the problem, when there is one
+	Field     string `json:"field,omitempty"`
+	RequestID string `json:"requestId"`
+}
+
+// fieldError is an error caused by a single field of the request
+type fieldError struct {
+	field string
+	err   error
+}
+
+func (e fieldError) Error() string {
+	return e.field + ": " + e.err.Error()
+}
+
+func (e fieldError) Unwrap() error {
+	return e.err
+}
+
+// invalidField attributes the error to the field of the request, a nil error stays nil
+func invalidField(field string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fieldError{field: field, err: err}
+}
+
+// RequestID is the middleware that assigns every request an ID, which is returned in the X-Request-ID header and in
+// problem responses. A valid ID provided by the client is kept, so that a request can be traced across services.
+func RequestID(ctx *gin.Context) {
+	id := ctx.GetHeader(RequestIDHeader)
+	if !requestIDFormat.MatchString(id) {
+		random := make([]byte, 16)
+		_, _ = rand.Read(random)
+		id = hex.EncodeToString(random)
+	}
+
+	ctx.Set(requestIDKey, id)
+	ctx.Header(RequestIDHeader, id)
+	ctx.Next()
+}
+
+// Recover is the recovery handler that turns a panic while serving a request into an internal error problem
+func Recover(ctx *gin.Context, recovered any) {
+	log.Printf("Request %s panicked: %v\n%s", ctx.GetString(requestIDKey), recovered, debug.Stack())
+	problem(ctx, http.StatusInternalServerError, CodeInternal, "", "an internal error occurred")
+}
+
+// problem writes the problem response and aborts the request
+func problem(ctx *gin.Context, status int, code ProblemCode, field string, detail string) {
+	ctx.Header("Content-Type", "application/problem+json")
+	ctx.AbortWithStatusJSON(status, Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Code:      code,
+		Field:     field,
+		RequestID: ctx.GetString(requestIDKey),
+	})
+}
+
+// invalidBody refuses a request whose body can not be read
+func invalidBody(ctx *gin.Context, err error) {
+	problem(ctx, http.StatusBadRequest, CodeInvalidBody, "", err.Error())
+}
+
+// missingField refuses a request without a required field
+func missingField(ctx *gin.Context, field string) {
+	problem(ctx, http.StatusBadRequest, CodeMissingField, field, "the field "+field+" is required")
+}
+
+// failed responds with the problem matching the error. Errors caused by the request are reported with their message,
+// all other errors are logged and reported as an internal error without any details.
+func failed(ctx *gin.Context, operation string, err error) {
+	var field string
+	var fieldErr fieldError
+	if errors.As(err, &fieldErr) {
+		field = fieldErr.field
+	}
+
+	switch {
+	case errors.Is(err, logic.ErrInvalidPattern):
+		problem(ctx, http.StatusBadRequest, CodeInvalidPattern, field, err.Error())
+	case errors.Is(err, errUnknownPolicy):
+		problem(ctx, http.StatusBadRequest, CodeUnknownPolicy, "policy", err.Error())
+	case errors.Is(err, errUnknownVersion):
+		problem(ctx, http.StatusBadRequest, CodeUnknownVersion, field, err.Error())
+	case field != "":
+		problem(ctx, http.StatusBadRequest, CodeInvalidField, field, err.Error())
+	case errors.Is(err, data.ErrNotFound):
+		problem(ctx, http.StatusNotFound, CodeNotFound, field, err.Error())
+	case errors.Is(err, data.ErrDuplicate):
+		problem(ctx, http.StatusConflict, CodeDuplicate, field, err.Error())
+	default:
+		log.Printf("Request %s, error in %s: %v", ctx.GetString(requestIDKey), operation, err)
+		problem(ctx, http.StatusInternalServerError, CodeInternal, "", "an internal error occurred")
+	}
+}
+
+// statusOf returns the status of an item that could not be stored because of the error
+func statusOf(err error) ItemStatus {
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		return StatusNotFound
+	case errors.Is(err, data.ErrDuplicate):
+		return StatusDuplicate
+	case errors.Is(err, logic.ErrInvalidPattern):
+		return StatusInvalid
+	default:
+		return StatusError
+	}
+}
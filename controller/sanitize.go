@@ -2,32 +2,205 @@ package controller
 
 import (
 	"errors"
-	"maps"
-	"runtime/debug"
-	"sanitize/data"
+	"fmt"
 	"sanitize/logic"
-	"slices"
+	"unicode/utf8"
 )
 
-func doSanitize(request Sanitize, database *data.SanitizeDB) (result Sanitize, returnError error) {
-	defer func() {
-		if r := recover(); r != nil {
-			debug.PrintStack()
-			returnError = errors.New("an error occurred, while sanitizing text")
-		}
-	}()
+// doSanitize sanitizes the sentences of the request. The indexes of the sentences that exceed the block policy are
+// returned as well, the request should be refused when there are any.
+func doSanitize(request Sanitize, matcher *logic.Matcher, defaults logic.Options) (result Sanitize, blocked []int, returnError error) {
+	options, err := matchOptions(request.Matching, defaults)
+	if err != nil {
+		returnError = err
+		return
+	}
+
+	options.Mask, err = maskOptions(request.Mask, defaults.Mask)
+	if err != nil {
+		returnError = err
+		return
+	}
 
-	currentWords, err := database.ListRecords()
+	options.Block, err = blockPolicy(request.Block, defaults.Block)
 	if err != nil {
 		returnError = err
 		return
 	}
 
-	result.Sentences, err = logic.SanitizeText(request.Sentences, slices.Collect(maps.Values(currentWords)))
+	sanitized, err := matcher.SanitizeMatches(request.Sentences, options)
 	if err != nil {
 		returnError = err
 		return
 	}
 
+	for index, sentence := range sanitized {
+		if sentence.Blocked {
+			blocked = append(blocked, index)
+		}
+		result.Sentences = append(result.Sentences, sentence.Text)
+		for _, match := range sentence.Matches {
+			if len(match.Decodings) > 0 {
+				result.Decoded = append(result.Decoded, DecodedMatch{
+					Sentence:  index,
+					ID:        match.Pattern.ID,
+					Word:      match.Pattern.Value,
+					Decodings: decodingNames(match.Decodings),
+				})
+			}
+		}
+	}
+
 	return
 }
+
+// matchOptions applies the match options of the request on top of the defaults of the deployment
+func matchOptions(matching Matching, defaults logic.Options) (logic.Options, error) {
+	if matching.Obfuscation != nil {
+		defaults.Obfuscation = *matching.Obfuscation
+	}
+
+	if matching.Canonicalize != nil {
+		stages, err := parseStages(*matching.Canonicalize)
+		if err != nil {
+			return logic.Options{}, invalidField("canonicalize", err)
+		}
+		defaults.Canonicalize = stages
+	}
+
+	if matching.Decode != nil {
+		decodings, err := parseDecodings(*matching.Decode)
+		if err != nil {
+			return logic.Options{}, invalidField("decode", err)
+		}
+		defaults.Decode = decodings
+	}
+
+	if matching.DecodeDepth != nil {
+		if *matching.DecodeDepth < 0 || *matching.DecodeDepth > logic.MaxDecodeDepth {
+			return logic.Options{}, invalidField("decodeDepth", fmt.Errorf("the decode depth must be between 0 and %d", logic.MaxDecodeDepth))
+		}
+		defaults.DecodeDepth = *matching.DecodeDepth
+	}
+
+	if matching.IncludeCategories != nil {
+		categories, err := logic.ParseCategories(*matching.IncludeCategories)
+		if err != nil {
+			return logic.Options{}, invalidField("includeCategories", err)
+		}
+		defaults.Categories.Include = categories
+	}
+
+	if matching.ExcludeCategories != nil {
+		categories, err := logic.ParseCategories(*matching.ExcludeCategories)
+		if err != nil {
+			return logic.Options{}, invalidField("excludeCategories", err)
+		}
+		defaults.Categories.Exclude = categories
+	}
+
+	return defaults, nil
+}
+
+// parseDecodings converts the names of decodings
+func parseDecodings(names []string) ([]logic.Decoding, error) {
+	decodings := make([]logic.Decoding, 0, len(names))
+	for _, name := range names {
+		decoding, err := logic.ParseDecoding(name)
+		if err != nil {
+			return nil, err
+		}
+		decodings = append(decodings, decoding)
+	}
+
+	return decodings, nil
+}
+
+// decodingNames converts decodings to their names
+func decodingNames(decodings []logic.Decoding) []string {
+	names := make([]string, 0, len(decodings))
+	for _, decoding := range decodings {
+		names = append(names, string(decoding))
+	}
+
+	return names
+}
+
+// parseStages converts the names of canonicalization stages
+func parseStages(names []string) ([]logic.Stage, error) {
+	stages := make([]logic.Stage, 0, len(names))
+	for _, name := range names {
+		stage, err := logic.ParseStage(name)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, stage)
+	}
+
+	return stages, nil
+}
+
+// blockPolicy applies the block options of the request on top of the policy of the deployment
+func blockPolicy(block *Block, defaults logic.BlockPolicy) (logic.BlockPolicy, error) {
+	if block == nil {
+		return defaults, nil
+	}
+
+	if block.Severity != "" {
+		severity, err := logic.ParseSeverity(block.Severity)
+		if err != nil {
+			return logic.BlockPolicy{}, invalidField("block", err)
+		}
+		defaults.Severity = severity
+	}
+
+	if block.Matches != 0 {
+		defaults.Matches = block.Matches
+	}
+
+	return defaults, invalidField("block", defaults.Validate())
+}
+
+// maskOptions applies the mask options of the request on top of the defaults of the deployment. The hash key can
+// only be configured by the deployment.
+func maskOptions(mask *Mask, defaults logic.MaskOptions) (logic.MaskOptions, error) {
+	override, err := maskOverride(mask)
+	if err != nil {
+		return logic.MaskOptions{}, invalidField("mask", err)
+	}
+
+	options := defaults.Merge(override)
+	return options, invalidField("mask", options.Validate())
+}
+
+// maskOverride converts the mask options of a request, the options that are not set are left empty
+func maskOverride(mask *Mask) (logic.MaskOptions, error) {
+	if mask == nil {
+		return logic.MaskOptions{}, nil
+	}
+
+	strategy, err := logic.ParseMaskStrategy(mask.Strategy)
+	if err != nil {
+		return logic.MaskOptions{}, err
+	}
+	if mask.Strategy == "" {
+		strategy = ""
+	}
+
+	var character rune
+	if mask.Character != "" {
+		if utf8.RuneCountInString(mask.Character) != 1 {
+			return logic.MaskOptions{}, errors.New("the mask character must be a single character")
+		}
+		character, _ = utf8.DecodeRuneInString(mask.Character)
+	}
+
+	return logic.MaskOptions{
+		Strategy:    strategy,
+		Character:   character,
+		Token:       mask.Token,
+		RevealFirst: mask.RevealFirst,
+		RevealLast:  mask.RevealLast,
+		Label:       mask.Label,
+	}, nil
+}
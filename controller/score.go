@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"sanitize/logic"
+)
+
+func doScore(request Score, matcher *logic.Matcher, defaults logic.Options) (result Scoring, returnError error) {
+	options, err := matchOptions(request.Matching, defaults)
+	if err != nil {
+		returnError = err
+		return
+	}
+
+	risks, err := matcher.ScoreWith(request.Sentences, options)
+	if err != nil {
+		returnError = err
+		return
+	}
+
+	result.Sentences = make([]ScoredSentence, 0, len(risks))
+	for _, risk := range risks {
+		sentence := ScoredSentence{
+			Score:   risk.Score,
+			Level:   string(risk.Level),
+			Reasons: make([]string, 0, len(risk.Reasons)),
+			Words:   []string{},
+		}
+		for _, reason := range risk.Reasons {
+			sentence.Reasons = append(sentence.Reasons, string(reason))
+		}
+		for _, match := range risk.Matches {
+			sentence.Words = append(sentence.Words, match.Pattern.Value)
+		}
+		result.Sentences = append(result.Sentences, sentence)
+	}
+
+	return
+}
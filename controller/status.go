@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"net/http"
+	"slices"
+)
+
+// ItemStatus is the outcome of a single item of a request that changes stored values
+type ItemStatus string
+
+const (
+	StatusAdded   ItemStatus = "added"
+	StatusUpdated ItemStatus = "updated"
+	StatusRemoved ItemStatus = "removed"
+	// StatusDuplicate is reported when the value is already stored
+	StatusDuplicate ItemStatus = "duplicate"
+	// StatusNotFound is reported when the value that should be changed is not stored
+	StatusNotFound ItemStatus = "not_found"
+	// StatusInvalid is reported when the value is not valid, such as a regular expression that does not compile
+	StatusInvalid ItemStatus = "invalid"
+	// StatusSkipped is reported for a valid item that was not applied, because the request is applied as a whole and
+	// another item failed
+	StatusSkipped ItemStatus = "skipped"
+	// StatusError is reported when the value could not be stored for any other reason
+	StatusError ItemStatus = "error"
+)
+
+// ItemResult is the outcome of a single item of a request, the message explains why the item was not applied
+type ItemResult struct {
+	Item    string     `json:"item"`
+	Status  ItemStatus `json:"status" enums:"added,updated,removed,duplicate,not_found,invalid,skipped,error"`
+	Message string     `json:"message,omitempty"`
+}
+
+// succeeded reports whether the item was applied
+func (r ItemResult) succeeded() bool {
+	return r.Status == StatusAdded || r.Status == StatusUpdated || r.Status == StatusRemoved
+}
+
+// itemsStatus returns the HTTP status of a request from the outcome of its items. It is 200 when every item was
+// applied, 207 when only some of them were, 422 when none of them were and 500 when none of them could be stored
+// because of an error.
+func itemsStatus(results []ItemResult) int {
+	applied := 0
+	for _, result := range results {
+		if result.succeeded() {
+			applied++
+		}
+	}
+
+	switch {
+	case applied == len(results):
+		return http.StatusOK
+	case applied > 0:
+		return http.StatusMultiStatus
+	case slices.ContainsFunc(results, func(result ItemResult) bool {
+		return result.Status != StatusError && result.Status != StatusSkipped
+	}):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// results returns the outcome of every update as the outcome of an item, keyed by the word that was updated
+func (u WordUpdates) results() []ItemResult {
+	results := make([]ItemResult, 0, len(u.Updates))
+	for _, update := range u.Updates {
+		results = append(results, ItemResult{Item: update.From, Status: update.Status, Message: update.Message})
+	}
+	return results
+}
@@ -1,9 +1,251 @@
 package controller
 
+import "time"
+
 type SanitizeWord struct {
 	Words []string `json:"words"`
+	// Type is the pattern type of the words being added or updated, one of literal (default), glob or regex
+	Type string `json:"type,omitempty" enums:"literal,glob,regex"`
+	// Severity is the severity of the words being added or updated, one of low (default), medium, high or critical
+	Severity string `json:"severity,omitempty" enums:"low,medium,high,critical"`
+	// Categories are the categories of the words being added or updated, such as sql-keyword or profanity
+	Categories []string `json:"categories,omitempty" example:"sql-keyword"`
+	// Replacement replaces the words instead of the mask, when it is set
+	Replacement string `json:"replacement,omitempty"`
+	// CaseSensitive only matches the words with the exact case they are added with
+	CaseSensitive bool `json:"caseSensitive,omitempty"`
+	// Boundary defines whether a match must be a whole word, word is the default for literal and glob words and none for
+	// regex words
+	Boundary string `json:"boundary,omitempty" enums:"word,none"`
+	// Enabled defines whether the words are matched, words are enabled by default
+	Enabled     *bool  `json:"enabled,omitempty"`
+	Description string `json:"description,omitempty"`
+	Author      string `json:"author,omitempty"`
+	// Details lists every word with its metadata, it is only part of the response when words are listed
+	Details []WordDetail `json:"details,omitempty"`
+	// Results holds the outcome of every word, it is only part of the response when words are changed
+	Results []ItemResult `json:"results,omitempty"`
+}
+
+// WordDetail is a stored word with its metadata
+type WordDetail struct {
+	ID            uint      `json:"id"`
+	Word          string    `json:"word"`
+	Type          string    `json:"type"`
+	Severity      string    `json:"severity"`
+	Categories    []string  `json:"categories,omitempty"`
+	Replacement   string    `json:"replacement,omitempty"`
+	CaseSensitive bool      `json:"caseSensitive"`
+	Boundary      string    `json:"boundary,omitempty"`
+	Enabled       bool      `json:"enabled"`
+	Description   string    `json:"description,omitempty"`
+	Author        string    `json:"author,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// WordUpdates changes the values of stored words, version 2 of the update request
+type WordUpdates struct {
+	Updates []WordUpdate `json:"updates"`
+}
+
+// WordUpdate changes the stored word From to To, the word keeps its id and other options
+type WordUpdate struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Status and Message report the outcome of the update, they are only part of the response
+	Status  ItemStatus `json:"status,omitempty" enums:"updated,not_found,invalid,duplicate,skipped,error"`
+	Message string     `json:"message,omitempty"`
+}
+
+// WordState enables or disables stored words
+type WordState struct {
+	Words   []string `json:"words"`
+	Enabled bool     `json:"enabled"`
+	// Results holds the outcome of every word, it is only part of the response
+	Results []ItemResult `json:"results,omitempty"`
+}
+
+// WordCategories adds or removes categories of stored words
+type WordCategories struct {
+	Words      []string `json:"words,omitempty"`
+	Categories []string `json:"categories"`
+	// Results holds the outcome of every word, it is only part of the response when categories are changed
+	Results []ItemResult `json:"results,omitempty"`
 }
 
 type Sanitize struct {
 	Sentences []string `json:"sentences"`
+	// Mask optionally overrides the masking options of the deployment for this request
+	Mask *Mask `json:"mask,omitempty"`
+	// Block optionally overrides when the request is refused instead of sanitized
+	Block *Block `json:"block,omitempty"`
+	Matching
+	// Decoded lists the matches that were only found once the sentence was decoded, it is only part of the response
+	Decoded []DecodedMatch `json:"decoded,omitempty"`
+}
+
+type DecodedMatch struct {
+	// Sentence is the index of the sentence in the request
+	Sentence  int      `json:"sentence"`
+	ID        uint     `json:"id"`
+	Word      string   `json:"word"`
+	Decodings []string `json:"decodings"`
+}
+
+// Matching optionally overrides the match options of the deployment for a request
+type Matching struct {
+	// Policy is the name of the policy whose words, allowlist and mask options are used, the global list when not set
+	Policy string `json:"policy,omitempty" example:"support"`
+	// Version pins the version of the word list that is used, the current version when not set. The response of a
+	// sanitize request reports the version that was used.
+	Version *uint `json:"version,omitempty" example:"3"`
+	// Obfuscation defines whether obfuscated words are matched
+	Obfuscation *bool `json:"obfuscation,omitempty"`
+	// Canonicalize lists the canonicalization stages applied before matching, an empty list disables them
+	Canonicalize *[]string `json:"canonicalize,omitempty" enums:"nfkc,casefold,invisible,homoglyph,whitespace"`
+	// Decode lists the encodings decoded before matching, an empty list disables them
+	Decode *[]string `json:"decode,omitempty" enums:"url,html,hex,base64,char"`
+	// DecodeDepth limits how many times nested encodings are decoded
+	DecodeDepth *int `json:"decodeDepth,omitempty"`
+	// IncludeCategories only matches words in at least one of the categories, an empty list matches every word
+	IncludeCategories *[]string `json:"includeCategories,omitempty" example:"profanity"`
+	// ExcludeCategories never matches words in any of the categories
+	ExcludeCategories *[]string `json:"excludeCategories,omitempty" example:"sql-keyword"`
+}
+
+// Block defines when a request is refused instead of sanitized, when a sentence contains a word of at least the severity,
+// or at least the number of matches
+type Block struct {
+	Severity string `json:"severity,omitempty" enums:"low,medium,high,critical"`
+	Matches  int    `json:"matches,omitempty"`
+}
+
+// Rejection is returned instead of the sanitized sentences when the request is blocked
+type Rejection struct {
+	Message string `json:"message"`
+	// Sentences are the indexes of the sentences that were blocked
+	Sentences []int `json:"sentences"`
+}
+
+type Mask struct {
+	Strategy    string `json:"strategy,omitempty" enums:"character,token,partial,category,hash"`
+	Character   string `json:"character,omitempty" example:"#"`
+	Token       string `json:"token,omitempty" example:"[REDACTED]"`
+	RevealFirst *int   `json:"revealFirst,omitempty"`
+	RevealLast  *int   `json:"revealLast,omitempty"`
+	Label       string `json:"label,omitempty" example:"SQL_KEYWORD"`
+}
+
+type Detect struct {
+	Sentences []string `json:"sentences"`
+	Matching
+}
+
+type Detection struct {
+	Sentences []DetectedSentence `json:"sentences"`
+	// Version is the version of the word list that was used
+	Version uint `json:"version"`
+}
+
+type DetectedSentence struct {
+	Matches []DetectedMatch `json:"matches"`
+}
+
+type DetectedMatch struct {
+	// ID and Word identify the stored word that matched
+	ID       uint   `json:"id"`
+	Word     string `json:"word"`
+	Severity string `json:"severity" enums:"low,medium,high,critical"`
+	// Categories are the categories of the stored word
+	Categories []string `json:"categories,omitempty"`
+	// Text is the matched text as it appears in the sentence
+	Text  string  `json:"text"`
+	Bytes Offsets `json:"bytes"`
+	Runes Offsets `json:"runes"`
+	UTF16 Offsets `json:"utf16"`
+	// Decodings lists the decodings that exposed the match, when it was only found once the sentence was decoded
+	Decodings []string `json:"decodings,omitempty"`
+}
+
+type Score struct {
+	Sentences []string `json:"sentences"`
+	Matching
+}
+
+type Scoring struct {
+	Sentences []ScoredSentence `json:"sentences"`
+	// Version is the version of the word list that was used
+	Version uint `json:"version"`
+}
+
+type ScoredSentence struct {
+	// Score is the injection risk of the sentence, between 0 and 100
+	Score int    `json:"score"`
+	Level string `json:"level" enums:"none,low,medium,high"`
+	// Reasons are the codes of the signals that contributed to the score
+	Reasons []string `json:"reasons" enums:"KEYWORD,COMMENT,STACKED_STATEMENT,TAUTOLOGY,UNION_SELECT,QUOTE_IMBALANCE"`
+	// Words are the stored words found in the sentence
+	Words []string `json:"words"`
+}
+
+// Offsets is a range in a sentence, the start is inclusive and the end exclusive
+type Offsets struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+type AllowList struct {
+	Phrases []string `json:"phrases"`
+	// Results holds the outcome of every phrase, it is only part of the response when phrases are changed
+	Results []ItemResult `json:"results,omitempty"`
+}
+
+// Policy is a named word list with its own allowlist and mask options, which inherits from its base policy
+type Policy struct {
+	Name string `json:"name" example:"support"`
+	// Base is the name of the policy this policy inherits from, default for the global list, or empty for none
+	Base string `json:"base,omitempty" example:"default"`
+	// Mask overrides the mask options of the base policy
+	Mask *Mask `json:"mask,omitempty"`
+	// Words are added to the words of the base policy, grouped by their type, severity and categories
+	Words []SanitizeWord `json:"words,omitempty"`
+	// Removed words of the base policy are not matched by this policy
+	Removed []string `json:"removed,omitempty"`
+	// Phrases are added to the allowlist of the base policy
+	Phrases []string `json:"phrases,omitempty"`
+}
+
+type Policies struct {
+	Policies []Policy `json:"policies"`
+}
+
+// Version is a recorded version of the word list, every change to the words, the allowlist or the policies records a
+// new version
+type Version struct {
+	Version uint `json:"version" example:"3"`
+	// SharedVersion is the version of the shared word list that is part of the version of a tenant
+	SharedVersion uint `json:"sharedVersion,omitempty"`
+	// Reason is the request that changed the word list, startup and reload for changes made outside the service
+	Reason    string    `json:"reason" example:"PUT /api/v1/words"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Versions struct {
+	Versions []Version `json:"versions"`
+}
+
+// VersionDiff lists the changes that lead from one version of the word list to another. Words are compared by their
+// value, a word whose other options differ is changed and listed as it is in the to version.
+type VersionDiff struct {
+	From            Version      `json:"from"`
+	To              Version      `json:"to"`
+	AddedWords      []WordDetail `json:"addedWords,omitempty"`
+	RemovedWords    []WordDetail `json:"removedWords,omitempty"`
+	ChangedWords    []WordDetail `json:"changedWords,omitempty"`
+	AddedPhrases    []string     `json:"addedPhrases,omitempty"`
+	RemovedPhrases  []string     `json:"removedPhrases,omitempty"`
+	AddedPolicies   []string     `json:"addedPolicies,omitempty"`
+	RemovedPolicies []string     `json:"removedPolicies,omitempty"`
+	ChangedPolicies []string     `json:"changedPolicies,omitempty"`
 }
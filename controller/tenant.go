@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// TenantHeader is the header a request names its tenant with, when no API keys are configured
+	TenantHeader = "X-Tenant-ID"
+	// APIKeyHeader is the header a request authenticates with, when API keys are configured
+	APIKeyHeader = "X-API-Key"
+
+	//tenantKey is the key the resolved tenant is stored under in the gin context
+	tenantKey = "tenant"
+)
+
+// tenantName is the format of a tenant name, after it was converted to lowercase
+var tenantName = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+var (
+	errUnknownAPIKey = errors.New("unknown API key")
+	errUnknownTenant = errors.New("unknown tenant")
+)
+
+// tenantState holds the compiled word lists of a single tenant
+type tenantState struct {
+	//list holds the compiled current version of the word list used by the sanitize requests, it is replaced as a whole
+	//whenever the word list changes so that a request always sees a consistent snapshot without touching the database
+	list atomic.Pointer[compiledList]
+
+	//pinned caches the compiled older versions that requests pinned, a recorded version never changes
+	pinnedMutex sync.Mutex
+	pinned      map[uint]*compiledList
+}
+
+// ParseTenant normalizes the name of a tenant, an empty name refers to the shared tenant
+func ParseTenant(name string) (string, error) {
+	tenant := strings.ToLower(strings.TrimSpace(name))
+	if tenant == "" {
+		return "", nil
+	}
+	if !tenantName.MatchString(tenant) {
+		return "", fmt.Errorf("invalid tenant %q", name)
+	}
+	return tenant, nil
+}
+
+// SetAPIKeys configures the API keys of the deployment, mapping every key to the tenant it belongs to. Once keys are
+// configured every request must provide a known key, and the tenant is taken from the key instead of the tenant header.
+// A key of the empty tenant manages the shared word list.
+func (c *Controller) SetAPIKeys(keys map[string]string) error {
+	parsed := make(map[string]string, len(keys))
+	for key, name := range keys {
+		if key == "" {
+			return errors.New("an API key can not be empty")
+		}
+		tenant, err := ParseTenant(name)
+		if err != nil {
+			return err
+		}
+		parsed[key] = tenant
+	}
+
+	c.apiKeys = parsed
+	return nil
+}
+
+// SetTenants configures the tenants that requests may name with the tenant header when no API keys are configured.
+// Without configured tenants the deployment only serves the shared tenant and a request naming a tenant is refused.
+// Once tenants are configured every request must name one of them, so that the shared word list can not be changed by
+// leaving out the header, it is then managed with seed sets or the store file. Only the compiled word lists of known
+// tenants are kept in memory.
+func (c *Controller) SetTenants(names []string) error {
+	parsed := make(map[string]bool, len(names))
+	for _, name := range names {
+		tenant, err := ParseTenant(name)
+		if err != nil {
+			return err
+		}
+		if tenant == "" {
+			return errors.New("a tenant can not be empty")
+		}
+		parsed[tenant] = true
+	}
+
+	c.tenantNames = parsed
+	return nil
+}
+
+// ResolveTenant is the middleware that determines the tenant of a request. Requests with an unknown API key are refused
+// with 401, and requests naming an invalid or unknown tenant with 400.
+func (c *Controller) ResolveTenant(ctx *gin.Context) {
+	tenant, err := c.resolveTenant(ctx.GetHeader(APIKeyHeader), ctx.GetHeader(TenantHeader))
+	if errors.Is(err, errUnknownAPIKey) {
+		problem(ctx, http.StatusUnauthorized, CodeUnauthorized, "", "a known API key is required in the "+APIKeyHeader+" header")
+		return
+	}
+	if err != nil {
+		problem(ctx, http.StatusBadRequest, CodeInvalidTenant, "", err.Error())
+		return
+	}
+
+	ctx.Set(tenantKey, tenant)
+	ctx.Next()
+}
+
+// resolveTenant returns the tenant of the API key when API keys are configured, and the tenant of the header otherwise.
+// The tenant of the header must be configured, see SetTenants.
+func (c *Controller) resolveTenant(apiKey string, header string) (string, error) {
+	if len(c.apiKeys) == 0 {
+		tenant, err := ParseTenant(header)
+		if err != nil {
+			return "", err
+		}
+		if len(c.tenantNames) == 0 && tenant != "" {
+			return "", fmt.Errorf("%w %q, no tenants are configured", errUnknownTenant, tenant)
+		}
+		if len(c.tenantNames) > 0 && !c.tenantNames[tenant] {
+			return "", fmt.Errorf("%w %q, the %s header must name a configured tenant", errUnknownTenant, tenant,
+				TenantHeader)
+		}
+		return tenant, nil
+	}
+
+	//Every key is compared, so that the time taken does not reveal how much of a key matched
+	tenant, found := "", false
+	for key, keyTenant := range c.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) == 1 {
+			tenant, found = keyTenant, true
+		}
+	}
+	if !found {
+		return "", errUnknownAPIKey
+	}
+	return tenant, nil
+}
+
+// tenantOf returns the tenant resolved for the request
+func tenantOf(ctx *gin.Context) string {
+	return ctx.GetString(tenantKey)
+}
+
+// tenant returns the compiled word lists of the tenant, compiling them on first use
+func (c *Controller) tenant(tenant string) (*tenantState, error) {
+	if tenant == "" {
+		return &c.tenantState, nil
+	}
+	if state, ok := c.tenants.Load(tenant); ok {
+		return state.(*tenantState), nil
+	}
+
+	//Compiling under the reload mutex means a concurrent change can not be missed by the new tenant
+	c.reloadMutex.Lock()
+	defer c.reloadMutex.Unlock()
+	if state, ok := c.tenants.Load(tenant); ok {
+		return state.(*tenantState), nil
+	}
+
+	state := &tenantState{}
+	if err := c.reloadTenant(tenant, state, "reload"); err != nil {
+		return nil, err
+	}
+	c.tenants.Store(tenant, state)
+	return state, nil
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"sanitize/data"
+	"sanitize/logic"
+	"strconv"
+)
+
+// maxPinnedVersions limits the number of older versions of a tenant that are kept compiled for pinned requests
+const maxPinnedVersions = 8
+
+var errUnknownVersion = errors.New("unknown version")
+
+// compileList compiles the word list, the allowlist and every policy of the version
+func compileList(list data.VersionedList) (*compiledList, error) {
+	matcher, err := logic.CompilePatterns(patterns(list.EffectiveWords()), list.EffectivePhrases())
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make(map[string]compiledPolicy, len(list.Policies))
+	for _, p := range list.Policies {
+		effective, err := list.EffectivePolicy(p.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		compiled, err := logic.CompilePatterns(patterns(effective.Words), effective.Phrases)
+		if err != nil {
+			return nil, err
+		}
+		policies[p.Name] = compiledPolicy{matcher: compiled, mask: effective.Mask}
+	}
+
+	return &compiledList{version: list.Number, matcher: matcher, policies: policies}, nil
+}
+
+// pinnedList returns the compiled older version of the tenant's word list, compiling it on first use
+func (c *Controller) pinnedList(tenant string, state *tenantState, version uint) (*compiledList, error) {
+	state.pinnedMutex.Lock()
+	defer state.pinnedMutex.Unlock()
+
+	if list, ok := state.pinned[version]; ok {
+		return list, nil
+	}
+
+	loaded, err := c.Database.ForTenant(tenant).LoadVersion(version)
+	if errors.Is(err, data.ErrNotFound) {
+		return nil, invalidField("version", fmt.Errorf("%w %d", errUnknownVersion, version))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := compileList(loaded)
+	if err != nil {
+		return nil, err
+	}
+
+	//Pinned versions are rarely mixed, so the cache is simply emptied once it is full
+	if len(state.pinned) >= maxPinnedVersions || state.pinned == nil {
+		state.pinned = make(map[uint]*compiledList)
+	}
+	state.pinned[version] = list
+	return list, nil
+}
+
+// parseVersion converts the version number of a request
+func parseVersion(field string, value string) (uint, error) {
+	version, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || version == 0 {
+		return 0, invalidField(field, fmt.Errorf("invalid version %q", value))
+	}
+	return uint(version), nil
+}
+
+// fromDataVersion converts a recorded version to its response
+func fromDataVersion(version data.Version) Version {
+	return Version{
+		Version:       version.Number,
+		SharedVersion: version.SharedNumber,
+		Reason:        version.Reason,
+		CreatedAt:     version.CreatedAt,
+	}
+}
+
+// fromDataDiff converts the differences between two versions to their response
+func fromDataDiff(diff data.VersionDiff) VersionDiff {
+	return VersionDiff{
+		From:            fromDataVersion(diff.From),
+		To:              fromDataVersion(diff.To),
+		AddedWords:      wordDetails(diff.AddedWords),
+		RemovedWords:    wordDetails(diff.RemovedWords),
+		ChangedWords:    wordDetails(diff.ChangedWords),
+		AddedPhrases:    diff.AddedPhrases,
+		RemovedPhrases:  diff.RemovedPhrases,
+		AddedPolicies:   diff.AddedPolicies,
+		RemovedPolicies: diff.RemovedPolicies,
+		ChangedPolicies: diff.ChangedPolicies,
+	}
+}
+
+// wordDetails converts stored words to their details
+func wordDetails(words []data.Word) []WordDetail {
+	var details []WordDetail
+	for _, word := range words {
+		details = append(details, wordDetail(word))
+	}
+	return details
+}
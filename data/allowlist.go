@@ -0,0 +1,139 @@
+package data
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// this is a private object definition used in the database by Gorm to build the allowlist table. A phrase that
+// covers a match of a sensitive word prevents the match from being sanitized. Phrases of the global allowlist have no
+// policy, the other phrases are added by a named policy
+type allowedPhrase struct {
+	ID       uint   `gorm:"primaryKey; autoIncrement:true;"`
+	Tenant   string `gorm:"index:idx_policy_phrase,unique,priority:1"`
+	PolicyID uint   `gorm:"index:idx_policy_phrase,unique,priority:2"`
+	Phrase   string `gorm:"index:idx_policy_phrase,unique,priority:3"`
+}
+
+// ListAllowedPhrases returns a map with the current phrases of the global allowlist of the tenant and their unique id.
+// In the case where it cannot connect or an error occurred the method will return an error
+func (sanitize *SanitizeDB) ListAllowedPhrases() (map[uint]string, error) {
+	return sanitize.listAllowedPhrases(0)
+}
+
+// EffectiveAllowedPhrases returns the phrases of the shared global allowlist and of the global allowlist of the
+// tenant, keyed by their unique id
+func (sanitize *SanitizeDB) EffectiveAllowedPhrases() (map[uint]string, error) {
+	phrases, err := sanitize.ListAllowedPhrases()
+	if err != nil || sanitize.tenant == "" {
+		return phrases, err
+	}
+
+	shared, err := sanitize.forTenant("").ListAllowedPhrases()
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(phrases, shared)
+	return phrases, nil
+}
+
+// listAllowedPhrases returns the phrases added by the policy, or the phrases of the global allowlist for policy zero
+func (sanitize *SanitizeDB) listAllowedPhrases(policyID uint) (map[uint]string, error) {
+	var phrases []allowedPhrase
+
+	err := sanitize.scoped().Where("policy_id = ?", policyID).Find(&phrases).Error
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[uint]string)
+	for _, phrase := range phrases {
+		records[phrase.ID] = strings.ToUpper(phrase.Phrase)
+	}
+
+	return records, nil
+}
+
+// AddAllowedPhrase provides the ability to add a phrase to the allowlist
+// There is an unique key index on the phrase, and should it already be present ErrDuplicate is returned
+func (sanitize *SanitizeDB) AddAllowedPhrase(phrase string) (uint, error) {
+	phrase, err := normalizePhrase(phrase)
+	if err != nil {
+		return 0, err
+	}
+
+	insert := allowedPhrase{Tenant: sanitize.tenant, Phrase: phrase}
+
+	err = sanitize.change("add phrase", func(tx *SanitizeDB) error {
+		duplicate, err := exists(tx.scoped().Where("policy_id = ? AND phrase = ?", 0, insert.Phrase), &allowedPhrase{})
+		if err != nil {
+			return err
+		}
+		if duplicate {
+			return ErrDuplicate
+		}
+
+		insert.ID = 0
+		if err := tx.db.Create(&insert).Error; err != nil {
+			return fmt.Errorf("entry not added: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return insert.ID, nil
+}
+
+// UpdateAllowedPhrase provides the ability to change a phrase of the allowlist in place, the phrase keeps its id. Should
+// the phrase not exist ErrNotFound is returned, and should the new phrase already be present ErrDuplicate
+func (sanitize *SanitizeDB) UpdateAllowedPhrase(id uint, phrase string) error {
+	phrase, err := normalizePhrase(phrase)
+	if err != nil {
+		return err
+	}
+
+	return sanitize.change("update phrase", func(tx *SanitizeDB) error {
+		var row allowedPhrase
+		if err := tx.scoped().Where("id = ? AND policy_id = ?", id, 0).Limit(1).Find(&row).Error; err != nil {
+			return err
+		}
+		if row.ID == 0 {
+			return ErrNotFound
+		}
+		if row.Phrase == phrase {
+			return errUnchanged
+		}
+
+		duplicate, err := exists(tx.scoped().Where("policy_id = ? AND phrase = ?", 0, phrase), &allowedPhrase{})
+		if err != nil {
+			return err
+		}
+		if duplicate {
+			return ErrDuplicate
+		}
+
+		if err := tx.db.Model(&row).Update("phrase", phrase).Error; err != nil {
+			return fmt.Errorf("entry not updated: %w", err)
+		}
+		return nil
+	})
+}
+
+// RemoveAllowedPhrase provides the ability to remove a phrase from the allowlist
+// The unique ID is required to complete the operation. Should no delete occur an "entry not found" error will
+// be returned
+func (sanitize *SanitizeDB) RemoveAllowedPhrase(id uint) error {
+	return sanitize.change("remove phrase", func(tx *SanitizeDB) error {
+		result := *tx.scoped().Delete(&allowedPhrase{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		} else {
+			return ErrNotFound
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package data
+
+import (
+	"sanitize/logic"
+	"slices"
+)
+
+// this is a private object definition used in the database by Gorm to build the category table. A word can be part
+// of any number of categories, every category of a word is a separate row
+type wordCategory struct {
+	ID       uint   `gorm:"primaryKey; autoIncrement:true;"`
+	Tenant   string `gorm:"index"`
+	WordID   uint   `gorm:"index:idx_word_category,unique"`
+	Category string `gorm:"index:idx_word_category,unique"`
+}
+
+// ListCategories returns the names of all the categories that are in use by the tenant, in alphabetical order.
+// In the case where it cannot connect or an error occurred the method will return an error
+func (sanitize *SanitizeDB) ListCategories() ([]string, error) {
+	var categories []string
+
+	err := sanitize.scoped().Model(&wordCategory{}).Distinct().Order("category").Pluck("category", &categories).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+// AddCategories provides the ability to add categories to a word, the names are normalized with logic.ParseCategory.
+// Categories the word is already part of are ignored. Should the word not exist an "entry not found" error will be
+// returned
+func (sanitize *SanitizeDB) AddCategories(id uint, categories []string) error {
+	parsed, err := logic.ParseCategories(categories)
+	if err != nil {
+		return err
+	}
+
+	return sanitize.change("add categories", func(tx *SanitizeDB) error {
+		var count int64
+		if err := tx.scoped().Model(&sensitiveWord{}).Where("id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrNotFound
+		}
+
+		current, err := tx.wordCategories(id)
+		if err != nil {
+			return err
+		}
+
+		for _, category := range parsed {
+			if slices.Contains(current[id], category) {
+				continue
+			}
+			if err := tx.db.Create(&wordCategory{Tenant: tx.tenant, WordID: id, Category: category}).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// RemoveCategories provides the ability to remove categories from a word. Should the word not be part of any of the
+// categories an "entry not found" error will be returned
+func (sanitize *SanitizeDB) RemoveCategories(id uint, categories []string) error {
+	parsed, err := logic.ParseCategories(categories)
+	if err != nil {
+		return err
+	}
+
+	return sanitize.change("remove categories", func(tx *SanitizeDB) error {
+		result := tx.scoped().Where("word_id = ? AND category IN ?", id, parsed).Delete(&wordCategory{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// wordCategories returns the categories of the words with the provided ids, or of all the words when no ids are
+// provided, keyed by the id of the word
+func (sanitize *SanitizeDB) wordCategories(ids ...uint) (map[uint][]string, error) {
+	var rows []wordCategory
+
+	query := sanitize.scoped().Order("category")
+	if len(ids) > 0 {
+		query = query.Where("word_id IN ?", ids)
+	}
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	categories := make(map[uint][]string)
+	for _, row := range rows {
+		categories[row.WordID] = append(categories[row.WordID], row.Category)
+	}
+
+	return categories, nil
+}
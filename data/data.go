@@ -1,14 +1,14 @@
 package data
 
 import (
-	"encoding/json"
 	"errors"
-	"gorm.io/driver/sqlite"
-	"gorm.io/driver/sqlserver"
+	"fmt"
 	"gorm.io/gorm"
-	"io"
+	"log"
 	"os"
+	"sanitize/logic"
 	"strings"
+	"time"
 )
 
 /*
@@ -16,126 +16,468 @@ import (
 * database specific logic behind a obfuscation level. This also assist in testing
 */
 
-// this is a private object definition used in the database by Gorm to build the table */
+// this is a private object definition used in the database by Gorm to build the table. Words of the global list
+// have no policy, the other words are added by a named policy, see Policy. Every table is scoped to a tenant, the
+// shared tenant has an empty name */
 type sensitiveWord struct {
-	ID        uint   `gorm:"primaryKey; autoIncrement:true;"`
-	Sensitive string `gorm:"index:idx_sensitive,unique"`
+	ID          uint   `gorm:"primaryKey; autoIncrement:true;"`
+	Tenant      string `gorm:"index:idx_policy_sensitive,unique,priority:1"`
+	PolicyID    uint   `gorm:"index:idx_policy_sensitive,unique,priority:2"`
+	Sensitive   string `gorm:"index:idx_policy_sensitive,unique,priority:3"`
+	PatternType string
+	Severity    string
+	// Replacement, CaseSensitive and Boundary change how the word is matched and masked, see logic.Pattern
+	Replacement   string
+	CaseSensitive bool
+	Boundary      string
+	// Disabled words are kept, but not matched until they are enabled again
+	Disabled    bool
+	Description string
+	Author      string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
 }
 
+// Word is a stored sensitive word together with the way it should be matched. PolicyID is zero for the words of the
+// global list.
+type Word struct {
+	ID          uint              `json:"id" yaml:"id"`
+	PolicyID    uint              `json:"policyId,omitempty" yaml:"policyId,omitempty"`
+	Sensitive   string            `json:"sensitive" yaml:"sensitive"`
+	PatternType logic.PatternType `json:"patternType" yaml:"patternType"`
+	Severity    logic.Severity    `json:"severity" yaml:"severity"`
+	Categories  []string          `json:"categories,omitempty" yaml:"categories,omitempty"`
+
+	Replacement   string         `json:"replacement,omitempty" yaml:"replacement,omitempty"`
+	CaseSensitive bool           `json:"caseSensitive,omitempty" yaml:"caseSensitive,omitempty"`
+	Boundary      logic.Boundary `json:"boundary,omitempty" yaml:"boundary,omitempty"`
+	Disabled      bool           `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	Description   string         `json:"description,omitempty" yaml:"description,omitempty"`
+	Author        string         `json:"author,omitempty" yaml:"author,omitempty"`
+	// CreatedAt and UpdatedAt are maintained by the database, they are ignored when a word is added
+	CreatedAt time.Time `json:"createdAt" yaml:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" yaml:"updatedAt"`
+}
+
+// Equals reports whether the value refers to this word. Regular expressions and case sensitive words are compared
+// exactly, as changing their case changes their meaning, all other words are compared case insensitively.
+func (w Word) Equals(value string) bool {
+	if w.PatternType == logic.Regex || w.CaseSensitive {
+		return w.Sensitive == value
+	}
+	return strings.EqualFold(w.Sensitive, value)
+}
+
+// SanitizeDB performs the CRUD operations of a single tenant, see ForTenant. The SanitizeDB returned by Initialize
+// manages the shared tenant, whose global list and allowlist are merged into the effective list of every tenant.
 type SanitizeDB struct {
-	db *gorm.DB
+	db     *gorm.DB
+	tenant string
+	reason string
+
+	//batch collects the actions of the changes of a batch, which record no version of their own
+	batch *batchActions
 }
 
+// ForTenant returns a SanitizeDB on the same connection that only reads and changes the records of the tenant. An
+// empty tenant is the shared tenant.
+func (sanitize *SanitizeDB) ForTenant(tenant string) WordStore {
+	return sanitize.forTenant(tenant)
+}
+
+func (sanitize *SanitizeDB) forTenant(tenant string) *SanitizeDB {
+	return &SanitizeDB{db: sanitize.db, tenant: tenant}
+}
+
+// Tenant returns the name of the tenant this SanitizeDB is scoped to
+func (sanitize *SanitizeDB) Tenant() string {
+	return sanitize.tenant
+}
+
+// WithReason returns a SanitizeDB on the same connection and tenant that records the reason in the versions of its
+// changes
+func (sanitize *SanitizeDB) WithReason(reason string) WordStore {
+	return &SanitizeDB{db: sanitize.db, tenant: sanitize.tenant, reason: reason}
+}
+
+// scoped starts a query that is restricted to the records of the tenant
+func (sanitize *SanitizeDB) scoped() *gorm.DB {
+	return sanitize.db.Where("tenant = ?", sanitize.tenant)
+}
+
+var (
+	// ErrNotFound is returned when the entry that should be read or changed does not exist for the tenant
+	ErrNotFound = errors.New("entry not found")
+	// ErrDuplicate is returned when the entry that should be stored already exists for the tenant
+	ErrDuplicate = errors.New("entry already exists")
+)
+
 const dataFileName = "sql_sensitive_list.json"
 
 // Initialize initializes the database connection and provides the SanitizeDB object to perform CRUD operations
-// on the database for management sensitive words. Both SQL Lite and MS SQL is supported through GORM, it is recommended that
-// SQL Lite is only used for testing purposes. For database initialization and testing the function supports reading a file in
-// a json format, representing an string list. The expect filename is sql_sensitive_list.json. This file will be pushed
-// to the database and should be removed after the first successful startup.
+// on the database for management sensitive words. The connection is a URL whose scheme names the driver, see Driver.
+// SQL Lite, MS SQL, PostgreSQL and MySQL are supported through GORM, it is recommended that SQL Lite is only used for
+// testing purposes. Pending schema migrations are applied, see MigrateUp, and a database that was migrated by a newer
+// version of the service is refused with ErrUnknownSchema. For database initialization and testing the function
+// supports reading a file in a json format, representing an string list. The expect filename is
+// sql_sensitive_list.json. This file is imported as a seed set the first time it is found, see SeedFile, and ignored
+// after that until it changes.
 func Initialize(connectionString string) (SanitizeDB, error) {
-	//Reading a data intialize file, this will be used for testing and the initial launch to setup the database with correct data
-	var words []string
-	var result SanitizeDB
-
-	if _, err := os.Stat(dataFileName); err == nil {
-		jsonFile, err := os.Open(dataFileName)
-		// if we os.Open returns an error then handle it
-		if err != nil {
-			return SanitizeDB{}, err
-		}
-
-		// defer the closing of our jsonFile so that we can parse it later on
-		defer jsonFile.Close()
+	result, err := Open(connectionString)
+	if err != nil {
+		return SanitizeDB{}, err
+	}
 
-		byteValue, err := io.ReadAll(jsonFile)
-		if err != nil {
-			return SanitizeDB{}, err
-		}
+	// Migrate the schema, a database migrated by a newer version of the service is refused
+	_, err = result.MigrateUp(0)
+	if err != nil {
+		return SanitizeDB{}, err
+	}
 
-		//Converting the object into a object, so that it can be parsed
-		err = json.Unmarshal(byteValue, &words)
+	//The sample data is imported once per database, the file can remain in place
+	if _, err := os.Stat(dataFileName); err == nil {
+		report, err := result.SeedFile(dataFileName)
 		if err != nil {
 			return SanitizeDB{}, err
 		}
+		log.Printf("Sample data: %v", report)
 	}
 
-	var err error
-	if strings.Split(connectionString, ";")[0] == "sqlite" {
-		result.db, err = gorm.Open(sqlite.Open(strings.Split(connectionString, ";")[1]), &gorm.Config{})
-	} else if strings.Split(connectionString, ";")[0] == "sqlserver" {
-		result.db, err = gorm.Open(sqlserver.Open(strings.Split(connectionString, ";")[1]), &gorm.Config{})
-	}
+	return result, nil
+}
 
+// Open connects to the database without migrating its schema or loading the sample data, see Initialize. It is used to
+// manage the migrations of the database.
+func Open(connectionString string) (SanitizeDB, error) {
+	dialector, err := openDialector(connectionString)
 	if err != nil {
 		return SanitizeDB{}, err
 	}
 
-	// Migrate the schema
-	err = result.db.AutoMigrate(&sensitiveWord{})
+	//Translated errors let a version number that is recorded twice be told apart, see errVersionConflict
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
 	if err != nil {
 		return SanitizeDB{}, err
 	}
+	return SanitizeDB{db: db}, nil
+}
+
+// ListRecords returns a map with the current loaded sanitized keywords of the global list of the tenant and their
+// unique id. In the case where it cannot connect or an error occurred the method will return an error
+func (sanitize *SanitizeDB) ListRecords() (map[uint]string, error) {
+	var words []sensitiveWord
+
+	err := sanitize.scoped().Where("policy_id = ?", 0).Find(&words).Error
+	if err != nil {
+		return nil, err
+	}
 
+	records := make(map[uint]string)
 	for _, word := range words {
-		result.db.Create(&sensitiveWord{Sensitive: word})
+		records[word.ID] = toWord(word).Sensitive
 	}
 
-	return result, nil
+	return records, nil
 }
 
-// ListRecords returns a map with the current loaded sanitized keywords and their unique id.
-// In the case where it cannot connect or an error occurred the method will return an error
-func (sanitize *SanitizeDB) ListRecords() (map[uint]string, error) {
+// ListWords returns all the words of the global list of the tenant including their pattern type, severity and
+// categories. In the case where it cannot connect or an error occurred the method will return an error
+func (sanitize *SanitizeDB) ListWords() ([]Word, error) {
+	return sanitize.listWords(0)
+}
+
+// EffectiveWords returns the words of the shared global list followed by the words of the global list of the tenant
+func (sanitize *SanitizeDB) EffectiveWords() ([]Word, error) {
+	words, err := sanitize.ListWords()
+	if err != nil || sanitize.tenant == "" {
+		return words, err
+	}
+
+	shared, err := sanitize.forTenant("").ListWords()
+	if err != nil {
+		return nil, err
+	}
+	return append(shared, words...), nil
+}
+
+// listWords returns all the words added by the policy, or the words of the global list for policy zero
+func (sanitize *SanitizeDB) listWords(policyID uint) ([]Word, error) {
 	var words []sensitiveWord
 
-	_, err := sanitize.db.Find(&words).Rows()
+	err := sanitize.scoped().Where("policy_id = ?", policyID).Order("id").Find(&words).Error
 	if err != nil {
 		return nil, err
 	}
 
-	records := make(map[uint]string)
+	categories, err := sanitize.wordCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Word, 0, len(words))
 	for _, word := range words {
-		records[word.ID] = strings.ToUpper(word.Sensitive)
+		converted := toWord(word)
+		converted.Categories = categories[word.ID]
+		result = append(result, converted)
 	}
 
-	return records, nil
+	return result, nil
 }
 
-// RemoveEntry provides the ability to remove an entry from the database
-// The unique ID is required to complete the operation. Should no delete occur an "entry not found" error will
-// be returned
+// toWord converts the database record to a Word. Records created before pattern types and severities were
+// introduced, or loaded from the initialization file, have neither and are treated as literals of low severity.
+func toWord(word sensitiveWord) Word {
+	patternType := logic.PatternType(word.PatternType)
+	if patternType == "" {
+		patternType = logic.Literal
+	}
+
+	value := storedValue(Word{PatternType: patternType, CaseSensitive: word.CaseSensitive}, word.Sensitive)
+
+	severity, err := logic.ParseSeverity(word.Severity)
+	if err != nil {
+		severity = logic.SeverityLow
+	}
+
+	boundary, err := logic.ParseBoundary(word.Boundary)
+	if err != nil {
+		boundary = logic.BoundaryDefault
+	}
+
+	return Word{
+		ID:            word.ID,
+		PolicyID:      word.PolicyID,
+		Sensitive:     value,
+		PatternType:   patternType,
+		Severity:      severity,
+		Replacement:   word.Replacement,
+		CaseSensitive: word.CaseSensitive,
+		Boundary:      boundary,
+		Disabled:      word.Disabled,
+		Description:   word.Description,
+		Author:        word.Author,
+		CreatedAt:     word.CreatedAt,
+		UpdatedAt:     word.UpdatedAt,
+	}
+}
+
+// RemoveEntry provides the ability to remove an entry of the tenant from the database
+// The unique ID is required to complete the operation. Should no delete occur, also when the entry belongs to
+// another tenant, an "entry not found" error will be returned
 func (sanitize *SanitizeDB) RemoveEntry(id uint) error {
-	result := *sanitize.db.Delete(&sensitiveWord{}, id)
-	if result.RowsAffected > 0 {
+	return sanitize.change("remove word", func(tx *SanitizeDB) error {
+		result := *tx.scoped().Delete(&sensitiveWord{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return tx.scoped().Where("word_id = ?", id).Delete(&wordCategory{}).Error
+		} else {
+			return ErrNotFound
+		}
+	})
+}
+
+// EntryUpdate changes the value of the stored word with the ID
+type EntryUpdate struct {
+	ID    uint
+	Value string
+}
+
+// errNotApplied is the error of an update that was valid, but rolled back because another update of the batch failed
+var errNotApplied = errors.New("entry not updated, another update failed")
+
+// UpdateEntry provides the ability to change the value of an entry of the tenant in place. The entry keeps its ID,
+// pattern type, categories and other metadata, and the new value is validated against its pattern type. Should the
+// entry not exist ErrNotFound is returned, and should the new value already be present ErrDuplicate
+func (sanitize *SanitizeDB) UpdateEntry(id uint, newValue string) error {
+	errs, err := sanitize.UpdateEntries([]EntryUpdate{{ID: id, Value: newValue}})
+	if err != nil {
+		return errs[0]
+	}
+	return nil
+}
+
+// UpdateEntries changes the values of the entries of the tenant in a single transaction, in the same way as
+// UpdateEntry. Either every entry is changed or none, the outcome of every update is returned in the same order, and
+// the error is not nil when the batch was rolled back.
+func (sanitize *SanitizeDB) UpdateEntries(updates []EntryUpdate) ([]error, error) {
+	errs := make([]error, len(updates))
+
+	err := sanitize.change("update words", func(tx *SanitizeDB) error {
+		rows, err := prepareUpdates(updates, errs, tx.prepareUpdate)
+		if err != nil {
+			return err
+		}
+
+		for i := range rows {
+			if err := tx.db.Model(&rows[i]).Update("sensitive", rows[i].Sensitive).Error; err != nil {
+				errs[i] = fmt.Errorf("entry not updated: %w", err)
+				return err
+			}
+		}
 		return nil
-	} else {
-		return errors.New("entry not found")
+	})
+	return updateOutcome(errs, err)
+}
+
+// prepareUpdate finds the entry of the tenant and validates its new value, it returns the entry with the value as it
+// is stored
+func (sanitize *SanitizeDB) prepareUpdate(update EntryUpdate) (sensitiveWord, error) {
+	var row sensitiveWord
+	result := sanitize.scoped().Where("id = ?", update.ID).Limit(1).Find(&row)
+	if result.Error != nil {
+		return row, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return row, ErrNotFound
+	}
+
+	value, err := updatedValue(toWord(row), update.Value)
+	if err != nil {
+		return row, err
+	}
+
+	duplicate, err := exists(sanitize.scoped().Where("policy_id = ? AND sensitive = ? AND id <> ?",
+		row.PolicyID, value, row.ID), &sensitiveWord{})
+	if err != nil {
+		return row, err
 	}
+	if duplicate {
+		return row, ErrDuplicate
+	}
+
+	row.Sensitive = value
+	return row, nil
+}
+
+// SetEnabled provides the ability to enable or disable a word of the tenant, a disabled word is kept but not matched.
+// Should no word be changed, also when the word belongs to another tenant, an "entry not found" error will be returned
+func (sanitize *SanitizeDB) SetEnabled(id uint, enabled bool) error {
+	return sanitize.change(enableAction(enabled), func(tx *SanitizeDB) error {
+		result := tx.scoped().Model(&sensitiveWord{}).Where("id = ?", id).Update("disabled", !enabled)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
 }
 
-// AddEntry provides the ability to add an entry to the database
-// There is an unique key index on the name, and should it already be present, or an error occurred
-// the function will return "entry not added" error
+// AddEntry provides the ability to add a literal entry of the tenant to the database
+// There is an unique key index on the name, and should it already be present ErrDuplicate is returned
 func (sanitize *SanitizeDB) AddEntry(entry string) (uint, error) {
-	insert := sensitiveWord{Sensitive: strings.ToUpper(entry)}
+	return sanitize.AddPattern(entry, logic.Literal)
+}
+
+// AddPattern provides the ability to add an entry of the provided pattern type to the database. The entry is
+// validated before it is stored, and an invalid pattern is never added. Regular expressions are stored as is, all
+// other entries are stored as uppercase.
+func (sanitize *SanitizeDB) AddPattern(entry string, patternType logic.PatternType) (uint, error) {
+	return sanitize.AddWord(Word{Sensitive: entry, PatternType: patternType})
+}
+
+// AddWord provides the ability to add a word, with its pattern type, severity, categories and other metadata, to the
+// database. The word is validated the same way as AddPattern, the ID and timestamps of the word are ignored and a word
+// without a severity is of low severity. Case sensitive words are stored as is. The word is added to the global list,
+// unless it has a PolicyID, a PolicyID of no policy of the tenant is not found.
+func (sanitize *SanitizeDB) AddWord(word Word) (uint, error) {
+	var id uint
+
+	//The word and its categories are added together, or not at all
+	err := sanitize.change("add word", func(tx *SanitizeDB) error {
+		if word.PolicyID != 0 {
+			found, err := exists(tx.scoped().Where("id = ?", word.PolicyID), &policy{})
+			if err != nil {
+				return err
+			}
+			if !found {
+				return ErrNotFound
+			}
+		}
+
+		var err error
+		id, err = insertWord(tx.db, sanitize.tenant, word)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// insertWord validates and adds the word and its categories of the tenant in the transaction
+func insertWord(tx *gorm.DB, tenant string, word Word) (uint, error) {
+	word, err := normalizeWord(word)
+	if err != nil {
+		return 0, err
+	}
+
+	insert := toSensitiveWord(tenant, word)
+
+	duplicate, err := exists(tx.Where("tenant = ? AND policy_id = ? AND sensitive = ?", tenant, word.PolicyID, word.Sensitive),
+		&sensitiveWord{})
+	if err != nil {
+		return 0, err
+	}
+	if duplicate {
+		return 0, ErrDuplicate
+	}
 
-	sanitize.db.Create(&insert)
-	if insert.ID == 0 {
-		return 0, errors.New("entry not added")
+	if err := tx.Create(&insert).Error; err != nil {
+		return 0, fmt.Errorf("entry not added: %w", err)
+	}
+
+	for _, category := range word.Categories {
+		if err := tx.Create(&wordCategory{Tenant: tenant, WordID: insert.ID, Category: category}).Error; err != nil {
+			return 0, err
+		}
 	}
 	return insert.ID, nil
 }
 
+// toSensitiveWord converts the word of the tenant to its database record, without its ID, timestamps and categories
+func toSensitiveWord(tenant string, word Word) sensitiveWord {
+	return sensitiveWord{
+		Tenant:        tenant,
+		PolicyID:      word.PolicyID,
+		Sensitive:     word.Sensitive,
+		PatternType:   string(word.PatternType),
+		Severity:      word.Severity.String(),
+		Replacement:   word.Replacement,
+		CaseSensitive: word.CaseSensitive,
+		Boundary:      string(word.Boundary),
+		Disabled:      word.Disabled,
+		Description:   word.Description,
+		Author:        word.Author,
+	}
+}
+
+// exists reports whether the query finds any record of the model
+func exists(query *gorm.DB, model any) (bool, error) {
+	var count int64
+	err := query.Model(model).Count(&count).Error
+	return count > 0, err
+}
+
 // removeDatabase is an internal method, and should not be called. But in the case of testing
-// this function is used to clean up once the testing has been completed
+// this function is used to clean up once the testing has been completed. Both the URL and the older form of the
+// connection string remove the SQL Lite database file and its lock file.
 func (sanitize *SanitizeDB) removeDatabaseFile(connectionString string) error {
-	if strings.Split(connectionString, ";")[0] == "sqlite" {
-		err := os.Remove(strings.Split(connectionString, ";")[1])
-		if err != nil {
-			return err
-		}
+	dialector, err := openDialector(connectionString)
+	if err != nil {
+		return err
 	}
 
+	path := sqliteFilePath(dialector)
+	if path == "" {
+		return nil
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	os.Remove(sqliteLockPath(dialector))
 	return nil
 }
@@ -2,15 +2,20 @@ package data
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 
+	"gorm.io/gorm"
+	"sanitize/logic"
 	td "sanitize/testdata"
 )
 
 const databaseName = "test.db"
-const sampleDatabase = "sqlite;test.db"
+const sampleDatabase = "sqlite:test.db"
 
 var entry uint
 
@@ -129,3 +134,646 @@ func TestAddRemoveEntry(t *testing.T) {
 		t.Fatalf("Expected entry to exist")
 	}
 }
+
+func TestAddPattern(t *testing.T) {
+	db, err := Initialize(sampleDatabase)
+	defer func() {
+		err := db.removeDatabaseFile(sampleDatabase)
+		if err != nil {
+			log.Fatal("Unable to remove test database")
+		}
+	}()
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = db.AddPattern(`drop(`, logic.Regex)
+	if err == nil {
+		t.Fatalf("invalid regular expression was added")
+	}
+
+	entry, err = db.AddPattern(`\bdrop\s+table\b`, logic.Regex)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	words, err := db.ListWords()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	for _, word := range words {
+		if word.ID == entry {
+			if word.Sensitive != `\bdrop\s+table\b` || word.PatternType != logic.Regex {
+				t.Fatalf("Expected the regular expression to be stored as is, got %v", word)
+			}
+			return
+		}
+	}
+
+	t.Fatalf("Expected entry to exist")
+}
+
+func TestAddWordSeverity(t *testing.T) {
+	db, err := Initialize(sampleDatabase)
+	defer func() {
+		err := db.removeDatabaseFile(sampleDatabase)
+		if err != nil {
+			log.Fatal("Unable to remove test database")
+		}
+	}()
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	critical, err := db.AddWord(Word{Sensitive: "shutdown", PatternType: logic.Literal, Severity: logic.SeverityCritical})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	low, err := db.AddEntry("severity default")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	words, err := db.ListWords()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	for _, word := range words {
+		if word.ID == critical && (word.Sensitive != "SHUTDOWN" || word.Severity != logic.SeverityCritical) {
+			t.Errorf("Expected a critical word, got %v", word)
+		}
+		if word.ID == low && word.Severity != logic.SeverityLow {
+			t.Errorf("Expected a low severity word, got %v", word)
+		}
+	}
+}
+
+func TestCategories(t *testing.T) {
+	db, err := Initialize(sampleDatabase)
+	defer func() {
+		err := db.removeDatabaseFile(sampleDatabase)
+		if err != nil {
+			log.Fatal("Unable to remove test database")
+		}
+	}()
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	id, err := db.AddWord(Word{Sensitive: "darn", PatternType: logic.Literal, Categories: []string{"Profanity"}})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if _, err := db.AddWord(Word{Sensitive: "heck", PatternType: logic.Literal, Categories: []string{"bad/category"}}); err == nil {
+		t.Fatalf("Expected an invalid category to be rejected")
+	}
+
+	err = db.AddCategories(id, []string{"mild", "profanity"})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if err := db.AddCategories(0, []string{"mild"}); err == nil {
+		t.Fatalf("Expected categories of an unknown word to be rejected")
+	}
+
+	categories, err := db.ListCategories()
+	if err != nil || !slices.Equal(categories, []string{"mild", "profanity"}) {
+		t.Fatalf("Unexpected categories %v %v", categories, err)
+	}
+
+	err = db.RemoveCategories(id, []string{"profanity"})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	words, err := db.ListWords()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	for _, word := range words {
+		if word.ID == id && !slices.Equal(word.Categories, []string{"mild"}) {
+			t.Fatalf("Unexpected categories of the word %v", word)
+		}
+	}
+
+	err = db.RemoveEntry(id)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	categories, err = db.ListCategories()
+	if err != nil || len(categories) != 0 {
+		t.Fatalf("Expected the categories to be removed with the word %v %v", categories, err)
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	db, err := Initialize(sampleDatabase)
+	defer func() {
+		err := db.removeDatabaseFile(sampleDatabase)
+		if err != nil {
+			log.Fatal("Unable to remove test database")
+		}
+	}()
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if _, err = db.AddEntry("select"); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if _, err = db.AddEntry("order"); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	zero := 0
+	_, err = db.AddPolicy(Policy{
+		Name:     "Support",
+		Base:     DefaultPolicy,
+		Mask:     logic.MaskOptions{Strategy: logic.MaskToken, RevealFirst: &zero},
+		Words:    []Word{{Sensitive: "darn", PatternType: logic.Literal}},
+		Excluded: []string{"Order"},
+		Phrases:  []string{"select a colour"},
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = db.AddPolicy(Policy{Name: "support-emea", Base: "support", Words: []Word{{Sensitive: "heck", PatternType: logic.Literal}}})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if _, err = db.AddPolicy(Policy{Name: "missing-base", Base: "unknown"}); err == nil {
+		t.Fatalf("Expected an unknown base policy to be rejected")
+	}
+	if _, err = db.AddPolicy(Policy{Name: "invalid", Words: []Word{{Sensitive: "drop(", PatternType: logic.Regex}}}); err == nil {
+		t.Fatalf("Expected an invalid word to be rejected")
+	}
+	if _, err = db.GetPolicy("invalid"); err == nil {
+		t.Fatalf("Expected the invalid policy not to be added")
+	}
+
+	effective, err := db.EffectivePolicy("support-emea")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if !slices.Equal(sensitiveValues(effective.Words), []string{"SELECT", "DARN", "HECK"}) ||
+		!slices.Equal(effective.Phrases, []string{"SELECT A COLOUR"}) || effective.Mask.Strategy != logic.MaskToken {
+		t.Fatalf("Unexpected effective policy %v", effective)
+	}
+	if effective.Mask.RevealFirst == nil || *effective.Mask.RevealFirst != 0 || effective.Mask.RevealLast != nil {
+		t.Fatalf("Expected an explicit 0 to be kept apart from a count that is not set %v", effective.Mask)
+	}
+
+	words, err := db.ListWords()
+	if err != nil || !slices.Equal(sensitiveValues(words), []string{"SELECT", "ORDER"}) {
+		t.Fatalf("Expected the words of policies not to be part of the global list %v %v", words, err)
+	}
+
+	if err = db.UpdatePolicy(Policy{Name: "support", Base: "support-emea"}); err == nil {
+		t.Fatalf("Expected a policy inheriting from itself to be rejected")
+	}
+	if err = db.RemovePolicy("support"); err == nil {
+		t.Fatalf("Expected a base policy not to be removed")
+	}
+
+	err = db.UpdatePolicy(Policy{Name: "support-emea", Words: []Word{{Sensitive: "blimey", PatternType: logic.Literal}}})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	effective, err = db.EffectivePolicy("support-emea")
+	if err != nil || !slices.Equal(sensitiveValues(effective.Words), []string{"BLIMEY"}) {
+		t.Fatalf("Unexpected effective policy %v %v", effective, err)
+	}
+
+	if err = db.RemovePolicy("support-emea"); err != nil {
+		t.Fatalf(err.Error())
+	}
+	policies, err := db.ListPolicies()
+	if err != nil || len(policies) != 1 || policies[0].Name != "support" || !slices.Equal(policies[0].Excluded, []string{"Order"}) {
+		t.Fatalf("Unexpected policies %v %v", policies, err)
+	}
+}
+
+func TestTenants(t *testing.T) {
+	db, err := Initialize(sampleDatabase)
+	defer func() {
+		err := db.removeDatabaseFile(sampleDatabase)
+		if err != nil {
+			log.Fatal("Unable to remove test database")
+		}
+	}()
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if _, err = db.AddEntry("shared"); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	first, second := db.forTenant("first"), db.forTenant("second")
+	id, err := first.AddEntry("private")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if _, err = second.AddEntry("private"); err != nil {
+		t.Fatalf("Expected tenants to be able to add the same word %v", err)
+	}
+
+	records, err := second.ListRecords()
+	if err != nil || len(records) != 1 || records[id] != "" {
+		t.Fatalf("Expected the words of another tenant to be hidden %v %v", records, err)
+	}
+
+	records, err = db.ListRecords()
+	if err != nil || len(records) != 1 {
+		t.Fatalf("Expected the shared list to only contain the shared word %v %v", records, err)
+	}
+
+	if err = second.RemoveEntry(id); err == nil {
+		t.Fatalf("Expected the word of another tenant not to be removed")
+	}
+
+	words, err := first.EffectiveWords()
+	if err != nil || !slices.Equal(sensitiveValues(words), []string{"SHARED", "PRIVATE"}) {
+		t.Fatalf("Expected the shared list to be merged %v %v", words, err)
+	}
+
+	if err = first.RemoveEntry(id); err != nil {
+		t.Fatalf(err.Error())
+	}
+}
+
+func TestAddRemoveAllowedPhrase(t *testing.T) {
+	db, err := Initialize(sampleDatabase)
+	defer func() {
+		err := db.removeDatabaseFile(sampleDatabase)
+		if err != nil {
+			log.Fatal("Unable to remove test database")
+		}
+	}()
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	entry, err = db.AddAllowedPhrase("your order")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = db.AddAllowedPhrase("YOUR ORDER")
+	if err == nil {
+		t.Fatalf("duplicate test not valid")
+	}
+
+	loaded, err := db.ListAllowedPhrases()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if loaded[entry] != "YOUR ORDER" {
+		t.Fatalf("Expected entry to exist")
+	}
+
+	err = db.RemoveAllowedPhrase(entry)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	loaded, err = db.ListAllowedPhrases()
+	if _, ok := loaded[entry]; ok {
+		t.Fatalf("Expected entry to be removed")
+	}
+}
+
+func TestWordMetadata(t *testing.T) {
+	db, err := Initialize(sampleDatabase)
+	defer func() {
+		err := db.removeDatabaseFile(sampleDatabase)
+		if err != nil {
+			log.Fatal("Unable to remove test database")
+		}
+	}()
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	id, err := db.AddWord(Word{
+		Sensitive:     "Bob",
+		PatternType:   logic.Literal,
+		CaseSensitive: true,
+		Boundary:      logic.BoundaryNone,
+		Replacement:   "[NAME]",
+		Description:   "A name that should not be shared",
+		Author:        "jane",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.AddWord(Word{Sensitive: "DROP", PatternType: logic.Literal, Boundary: "line"}); err == nil {
+		t.Error("Expected an unsupported boundary to be rejected")
+	}
+
+	words, err := db.ListWords()
+	if err != nil || len(words) != 1 {
+		t.Fatal("Expected a single word ", words, err)
+	}
+	word := words[0]
+	if word.Sensitive != "Bob" || !word.CaseSensitive || word.Boundary != logic.BoundaryNone ||
+		word.Replacement != "[NAME]" || word.Author != "jane" || word.Disabled || word.CreatedAt.IsZero() {
+		t.Error("Unexpected word metadata ", word)
+	}
+	if !word.Equals("Bob") || word.Equals("BOB") {
+		t.Error("Expected a case sensitive word to be compared exactly")
+	}
+
+	if err := db.SetEnabled(id, false); err != nil {
+		t.Fatal(err)
+	}
+	words, _ = db.ListWords()
+	if !words[0].Disabled || words[0].UpdatedAt.Before(word.UpdatedAt) {
+		t.Error("Expected the word to be disabled ", words[0])
+	}
+
+	if err := db.ForTenant("acme").SetEnabled(id, true); err == nil {
+		t.Error("Expected the word of another tenant to be left unchanged")
+	}
+}
+
+func TestUpdateEntries(t *testing.T) {
+	db, err := Initialize(sampleDatabase)
+	defer func() {
+		err := db.removeDatabaseFile(sampleDatabase)
+		if err != nil {
+			log.Fatal("Unable to remove test database")
+		}
+	}()
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	selectID, _ := db.AddWord(Word{Sensitive: "select", PatternType: logic.Literal, Categories: []string{"sql"}})
+	dropID, _ := db.AddEntry("drop")
+	regexID, _ := db.AddPattern(`un.on`, logic.Regex)
+
+	if err := db.UpdateEntry(selectID, "selected"); err != nil {
+		t.Fatal(err)
+	}
+	words, _ := db.ListWords()
+	if words[0].ID != selectID || words[0].Sensitive != "SELECTED" || !slices.Equal(words[0].Categories, []string{"sql"}) {
+		t.Error("Expected the word to keep its id and categories ", words[0])
+	}
+
+	if err := db.UpdateEntry(999, "missing"); err == nil || err.Error() != "entry not found" {
+		t.Error("Expected an unknown entry not to be found ", err)
+	}
+
+	//A duplicate value rolls back the whole batch
+	errs, err := db.UpdateEntries([]EntryUpdate{{ID: regexID, Value: `union`}, {ID: dropID, Value: "selected"}})
+	if err == nil || errs[0] == nil || errs[1] == nil {
+		t.Fatal("Expected the batch to be rolled back ", errs, err)
+	}
+	words, _ = db.ListWords()
+	if words[2].Sensitive != `un.on` {
+		t.Error("Expected the first update to be rolled back ", words[2])
+	}
+
+	errs, err = db.UpdateEntries([]EntryUpdate{{ID: regexID, Value: `(`}, {ID: dropID, Value: "delete"}})
+	if err == nil || errs[0] == nil || !strings.Contains(errs[1].Error(), "another update failed") {
+		t.Error("Expected an invalid pattern to reject the batch ", errs, err)
+	}
+
+	errs, err = db.UpdateEntries([]EntryUpdate{{ID: regexID, Value: `Union`}, {ID: dropID, Value: "delete"}})
+	if err != nil || errs[0] != nil || errs[1] != nil {
+		t.Fatal("Expected the batch to be applied ", errs, err)
+	}
+	words, _ = db.ListWords()
+	if words[1].ID != dropID || words[1].Sensitive != "DELETE" || words[2].Sensitive != `Union` {
+		t.Error("Expected the words to be updated in place ", words)
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	db, err := Initialize(sampleDatabase)
+	defer func() {
+		err := db.removeDatabaseFile(sampleDatabase)
+		if err != nil {
+			log.Fatal("Unable to remove test database")
+		}
+	}()
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	id, err := db.AddEntry("select")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.AddEntry("SELECT"); !errors.Is(err, ErrDuplicate) {
+		t.Error("Expected a duplicate word, got ", err)
+	}
+	if _, err := db.forTenant("acme").AddEntry("SELECT"); err != nil {
+		t.Error("Expected the word to be added for another tenant, got ", err)
+	}
+	if _, err := db.AddPattern("(", logic.Regex); !errors.Is(err, logic.ErrInvalidPattern) {
+		t.Error("Expected an invalid pattern, got ", err)
+	}
+	if err := db.RemoveEntry(id + 100); !errors.Is(err, ErrNotFound) {
+		t.Error("Expected the word not to be found, got ", err)
+	}
+
+	if _, err := db.AddAllowedPhrase("your order"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.AddAllowedPhrase("YOUR ORDER"); !errors.Is(err, ErrDuplicate) {
+		t.Error("Expected a duplicate phrase, got ", err)
+	}
+
+	if _, err := db.AddPolicy(Policy{Name: "support", Base: DefaultPolicy}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.AddPolicy(Policy{Name: "support", Base: DefaultPolicy}); !errors.Is(err, ErrDuplicate) {
+		t.Error("Expected a duplicate policy, got ", err)
+	}
+	if _, err := db.GetPolicy("missing"); !errors.Is(err, ErrNotFound) {
+		t.Error("Expected the policy not to be found, got ", err)
+	}
+
+	errs, err := db.UpdateEntries([]EntryUpdate{{ID: id, Value: "drop"}, {ID: id + 100, Value: "delete"}})
+	if err == nil || !errors.Is(errs[1], ErrNotFound) {
+		t.Error("Expected the update of a missing word to fail, got ", errs, err)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	db, err := Initialize(sampleDatabase)
+	defer func() {
+		err := db.removeDatabaseFile(sampleDatabase)
+		if err != nil {
+			log.Fatal("Unable to remove test database")
+		}
+	}()
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	list, err := db.RecordVersion("empty")
+	if err != nil || list.Number != 0 {
+		t.Fatalf("Expected no version to be recorded for an empty list %v %v", list.Version, err)
+	}
+
+	first, err := db.AddEntry("first")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if list, err = db.RecordVersion("nothing"); err != nil || list.Number != 1 || list.Reason != "add word" {
+		t.Fatalf("Expected the change to record the first version %v %v", list.Version, err)
+	}
+
+	id, err := db.AddWord(Word{Sensitive: "second", PatternType: logic.Literal, Categories: []string{"test"}})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if _, err = db.WithReason("allow first place").AddAllowedPhrase("first place"); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if list, err = db.RecordVersion("nothing"); err != nil || list.Number != 3 || list.Reason != "allow first place" {
+		t.Fatalf("Expected every change to record its own version %v %v", list.Version, err)
+	}
+	recorded, err := db.ListWords()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tenant := db.forTenant("acme")
+	if _, err = tenant.AddEntry("private"); err != nil {
+		t.Fatalf(err.Error())
+	}
+	list, err = tenant.RecordVersion("add private")
+	if err != nil || list.Number != 1 || list.SharedNumber != 3 {
+		t.Fatalf("Expected the tenant version to record the shared version %v %v", list.Version, err)
+	}
+	if !slices.Equal(sensitiveValues(list.EffectiveWords()), []string{"FIRST", "SECOND", "PRIVATE"}) {
+		t.Fatalf("Expected the shared words to be part of the tenant version %v", list.EffectiveWords())
+	}
+
+	if err = db.RemoveEntry(id); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if err = db.SetEnabled(first, false); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if list, err = tenant.RecordVersion("remove second"); err != nil || list.Number != 2 || list.SharedNumber != 5 {
+		t.Fatalf("Expected a shared change to record a tenant version %v %v", list.Version, err)
+	}
+
+	diff, err := db.DiffVersions(3, 5)
+	if err != nil || len(diff.AddedWords) != 0 || len(diff.RemovedWords) != 1 || diff.RemovedWords[0].Sensitive != "SECOND" ||
+		len(diff.ChangedWords) != 1 || !diff.ChangedWords[0].Disabled {
+		t.Fatalf("Expected the removed and disabled words in the diff %+v %v", diff, err)
+	}
+
+	pinned, err := tenant.LoadVersion(1)
+	if err != nil || !slices.Equal(sensitiveValues(pinned.EffectiveWords()), []string{"FIRST", "SECOND", "PRIVATE"}) {
+		t.Fatalf("Expected the pinned version to hold the shared words it was recorded with %v %v", pinned.Version, err)
+	}
+
+	if err = db.RollbackVersion(3); err != nil {
+		t.Fatalf(err.Error())
+	}
+	list, err = db.RecordVersion("nothing")
+	if err != nil || list.Number != 6 || list.Reason != "rollback to version 3" {
+		t.Fatalf("Expected the rollback to be recorded %v %v", list.Version, err)
+	}
+
+	words, err := db.ListWords()
+	if err != nil || !slices.Equal(sensitiveValues(words), []string{"FIRST", "SECOND"}) || words[0].Disabled ||
+		!slices.Equal(words[1].Categories, []string{"test"}) {
+		t.Fatalf("Expected the words of version 3 to be restored %v %v", words, err)
+	}
+	for i, word := range words {
+		if word.ID != recorded[i].ID || !word.CreatedAt.Equal(recorded[i].CreatedAt) {
+			t.Fatalf("Expected the restored words to keep their id and creation time %v %v", word, recorded[i])
+		}
+	}
+
+	diff, err = db.DiffVersions(3, 6)
+	if err != nil || len(diff.AddedWords)+len(diff.RemovedWords)+len(diff.ChangedWords)+len(diff.AddedPhrases) > 0 {
+		t.Fatalf("Expected no differences after the rollback %+v %v", diff, err)
+	}
+
+	//Changes made directly on the database are recorded by the next call
+	if err = db.db.Create(&sensitiveWord{Sensitive: "DIRECT", PatternType: string(logic.Literal)}).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	if list, err = db.RecordVersion("startup"); err != nil || list.Number != 7 || list.Reason != "startup" {
+		t.Fatalf("Expected the direct change to be recorded %v %v", list.Version, err)
+	}
+
+	versions, err := db.ListVersions()
+	if err != nil || len(versions) != 7 || versions[1].Reason != "rollback to version 3" {
+		t.Fatalf("Expected the versions to be listed newest first %v %v", versions, err)
+	}
+
+	if err = db.RollbackVersion(9); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected an unknown version not to be found %v", err)
+	}
+}
+
+func TestVersionConflict(t *testing.T) {
+	db, err := Initialize(sampleDatabase)
+	defer func() {
+		err := db.removeDatabaseFile(sampleDatabase)
+		if err != nil {
+			log.Fatal("Unable to remove test database")
+		}
+	}()
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	//Another instance takes the next version number of the tenant once, while the change is being applied
+	tenant := db.forTenant("acme")
+	var conflicts int
+	err = db.db.Callback().Create().Before("gorm:create").Register("test:conflict", func(tx *gorm.DB) {
+		version, ok := tx.Statement.Dest.(*listVersion)
+		if !ok || conflicts > 0 {
+			return
+		}
+		conflicts++
+		other := listVersion{Tenant: version.Tenant, Number: version.Number, Reason: "other", Content: version.Content}
+		tx.Session(&gorm.Session{NewDB: true}).Create(&other)
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if _, err = tenant.AddEntry("conflict"); err != nil || conflicts != 1 {
+		t.Fatalf("Expected the change to be applied again after the conflict %v %d", err, conflicts)
+	}
+	versions, err := tenant.ListVersions()
+	if err != nil || len(versions) != 1 || versions[0].Reason != "add word" {
+		t.Fatalf("Expected a single version of the change %v %v", versions, err)
+	}
+	words, err := tenant.ListWords()
+	if err != nil || !slices.Equal(sensitiveValues(words), []string{"CONFLICT"}) {
+		t.Fatalf("Expected the word to be added once %v %v", words, err)
+	}
+}
@@ -0,0 +1,233 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sanitize/logic"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileStore is a WordStore that keeps the word lists, allowlists, categories, policies and versions of every tenant in
+// a JSON or YAML file, depending on the extension of the file. The content is kept in memory like a MemoryStore, and the
+// file is rewritten after every change, which suits deployments without a database server. Changes that other processes
+// make to the file are picked up by Watch.
+type FileStore struct {
+	*MemoryStore
+	file *storeFile
+}
+
+// storeFile is the file of a FileStore, shared by the stores of every tenant. It is only accessed while the memory
+// state of the store is locked.
+type storeFile struct {
+	path   string
+	format string
+
+	//modified and size identify the version of the file that was last read or written
+	modified time.Time
+	size     int64
+}
+
+// OpenFileStore opens the file store with the path, which ends in .json, .yaml or .yml. The file is created with the
+// first change when it does not exist yet. Words, phrases and policies that were added to the file by hand without an id
+// receive one, and the words are validated the same way as when they are added.
+func OpenFileStore(path string) (*FileStore, error) {
+	var format string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		format = "json"
+	case ".yaml", ".yml":
+		format = "yaml"
+	default:
+		return nil, fmt.Errorf("unsupported file store %q, the file must end in .json, .yaml or .yml", path)
+	}
+
+	store := &FileStore{MemoryStore: NewMemoryStore(), file: &storeFile{path: path, format: format}}
+	if _, err := store.reload(); err != nil {
+		return nil, err
+	}
+	store.state.save = store.file.write
+	return store, nil
+}
+
+// ForTenant returns a store on the same file that only reads and changes the content of the tenant. An empty tenant
+// is the shared tenant.
+func (store *FileStore) ForTenant(tenant string) WordStore {
+	return &FileStore{MemoryStore: store.forTenant(tenant), file: store.file}
+}
+
+// WithReason returns a store on the same file and tenant that records the reason in the versions of its changes
+func (store *FileStore) WithReason(reason string) WordStore {
+	return &FileStore{MemoryStore: store.withReason(reason), file: store.file}
+}
+
+// Watch reports the changes that other processes make to the file, by checking whether it was modified every interval.
+// The changed file replaces the content of the store, a file that can not be read is logged and ignored.
+func (store *FileStore) Watch(ctx context.Context, interval time.Duration) (<-chan struct{}, error) {
+	return poll(ctx, interval, store.reload), nil
+}
+
+// reload reads the file when it was modified since it was last read or written, and reports whether it was
+func (store *FileStore) reload() (bool, error) {
+	store.state.mutex.Lock()
+	defer store.state.mutex.Unlock()
+
+	info, err := os.Stat(store.file.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(store.file.modified) && info.Size() == store.file.size {
+		return false, nil
+	}
+
+	content, err := store.file.read()
+	if err != nil {
+		return false, fmt.Errorf("file store %q: %w", store.file.path, err)
+	}
+	store.state.content = content
+	store.file.modified, store.file.size = info.ModTime(), info.Size()
+	return true, nil
+}
+
+// read reads and prepares the content of the file
+func (file *storeFile) read() (memoryContent, error) {
+	raw, err := os.ReadFile(file.path)
+	if err != nil {
+		return memoryContent{}, err
+	}
+
+	var content memoryContent
+	if file.format == "json" {
+		err = json.Unmarshal(raw, &content)
+	} else {
+		err = yaml.Unmarshal(raw, &content)
+	}
+	if err != nil {
+		return memoryContent{}, err
+	}
+
+	return prepareContent(content)
+}
+
+// write replaces the file with the content, the content is written to a temporary file first so that the file is
+// never left half written
+func (file *storeFile) write(content memoryContent) error {
+	var raw []byte
+	var err error
+	if file.format == "json" {
+		raw, err = json.MarshalIndent(content, "", "  ")
+	} else {
+		raw, err = yaml.Marshal(content)
+	}
+	if err != nil {
+		return err
+	}
+
+	temporary, err := os.CreateTemp(filepath.Dir(file.path), "."+filepath.Base(file.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+
+	if _, err := temporary.Write(raw); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temporary.Name(), file.path); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(file.path)
+	if err != nil {
+		return err
+	}
+	file.modified, file.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// prepareContent validates the words of a file that may have been edited by hand, and assigns an id to every word,
+// phrase and policy that has none
+func prepareContent(content memoryContent) (memoryContent, error) {
+	if content.Tenants == nil {
+		content.Tenants = map[string]*memoryTenant{}
+	}
+
+	//New ids are assigned after the highest id that is in use
+	for name, tenant := range content.Tenants {
+		if tenant == nil {
+			content.Tenants[name] = &memoryTenant{}
+			continue
+		}
+		for _, words := range tenant.wordLists() {
+			for _, word := range *words {
+				content.NextID = max(content.NextID, word.ID)
+			}
+		}
+		for _, phrase := range tenant.Phrases {
+			content.NextID = max(content.NextID, phrase.ID)
+		}
+		for _, p := range tenant.Policies {
+			content.NextID = max(content.NextID, p.ID)
+		}
+	}
+	nextID := func(id uint) uint {
+		if id == 0 {
+			content.NextID++
+			id = content.NextID
+		}
+		return id
+	}
+
+	for name, tenant := range content.Tenants {
+		for i := range tenant.Policies {
+			p := &tenant.Policies[i]
+			p.ID = nextID(p.ID)
+
+			var err error
+			if p.Name, err = parsePolicyName(p.Name); err != nil {
+				return memoryContent{}, fmt.Errorf("tenant %q: %w", name, err)
+			}
+			for j := range p.Words {
+				p.Words[j].PolicyID = p.ID
+			}
+		}
+		tenant.Policies = sortedPolicies(tenant.Policies)
+
+		for _, words := range tenant.wordLists() {
+			for i, word := range *words {
+				if word.PatternType == "" {
+					word.PatternType = logic.Literal
+				}
+				normalized, err := normalizeWord(word)
+				if err != nil {
+					return memoryContent{}, fmt.Errorf("tenant %q, word %q: %w", name, word.Sensitive, err)
+				}
+				normalized.ID = nextID(word.ID)
+				(*words)[i] = normalized
+			}
+		}
+
+		for i := range tenant.Phrases {
+			tenant.Phrases[i].ID = nextID(tenant.Phrases[i].ID)
+			tenant.Phrases[i].Phrase = strings.ToUpper(tenant.Phrases[i].Phrase)
+		}
+
+		for i := range tenant.Versions {
+			tenant.Versions[i].Tenant = name
+		}
+	}
+
+	return content, nil
+}
@@ -0,0 +1,137 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/driver/sqlite"
+)
+
+// lockName is the name of the database lock that serializes the migrations and seeding of every instance
+const lockName = "sanitize_setup"
+
+// lockTimeout is how long an instance waits for another instance to finish its migrations and seeding
+const lockTimeout = 10 * time.Minute
+
+var errLockTimeout = errors.New("timed out waiting for another instance")
+
+// exclusive runs fn while holding a lock on the database, so that instances of the service that start against the
+// same database at the same time migrate and seed it one after the other. SQL Server uses an application lock,
+// PostgreSQL an advisory lock, MySQL a named lock and SQL Lite a lock on a file next to the database. Drivers without
+// a lock, such as those that are registered with RegisterDriver, run fn without one.
+func (sanitize *SanitizeDB) exclusive(fn func() error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), lockTimeout)
+	defer cancel()
+
+	var unlock func()
+	var err error
+	switch sanitize.db.Dialector.Name() {
+	case "sqlserver":
+		unlock, err = sanitize.sessionLock(ctx, `DECLARE @result int
+EXEC @result = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = @p2
+SELECT CASE WHEN @result >= 0 THEN 1 ELSE 0 END`,
+			`EXEC sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'`,
+			lockName, lockTimeout.Milliseconds())
+	case "postgres":
+		key := postgresLockKey()
+		unlock, err = sanitize.sessionLock(ctx, `SELECT 1 FROM pg_advisory_lock($1)`,
+			`SELECT pg_advisory_unlock($1)`, key)
+	case "mysql":
+		unlock, err = sanitize.sessionLock(ctx, `SELECT GET_LOCK(?, ?)`, `SELECT RELEASE_LOCK(?)`,
+			lockName, int(lockTimeout.Seconds()))
+	case "sqlite":
+		unlock, err = lockFile(ctx, sqliteLockPath(sanitize.db.Dialector))
+	default:
+		unlock = func() {}
+	}
+	if err != nil {
+		return fmt.Errorf("unable to lock the database: %w", err)
+	}
+	defer unlock()
+
+	return fn()
+}
+
+// sessionLock acquires a lock that is owned by a database session on a connection of its own, the acquire query
+// returns 1 once the lock is held. The lock is released with the release query, and should that fail the connection is
+// closed, which ends the session and with it the lock.
+func (sanitize *SanitizeDB) sessionLock(ctx context.Context, acquire string, release string, args ...any) (func(), error) {
+	db, err := sanitize.db.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, acquire, args...).Scan(&locked); err != nil {
+		discard(conn)
+		if ctx.Err() != nil {
+			return nil, errLockTimeout
+		}
+		return nil, err
+	}
+	if locked.Int64 != 1 {
+		conn.Close()
+		return nil, errLockTimeout
+	}
+
+	return func() {
+		//Only the lock name is passed to the release query
+		if _, err := conn.ExecContext(context.Background(), release, args[0]); err != nil {
+			log.Printf("Unable to release the database lock, closing its connection: %v", err)
+			discard(conn)
+			return
+		}
+		conn.Close()
+	}, nil
+}
+
+// discard closes the connection instead of returning it to the pool, which ends its session
+func discard(conn *sql.Conn) {
+	conn.Raw(func(any) error {
+		return driver.ErrBadConn
+	})
+	conn.Close()
+}
+
+// postgresLockKey is the key of the advisory lock, PostgreSQL identifies advisory locks by a number
+func postgresLockKey() int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(lockName))
+	return int64(hash.Sum64())
+}
+
+// sqliteLockPath returns the path of the lock file next to the SQL Lite database file, or an empty path for an in
+// memory database, which no other process can open
+func sqliteLockPath(dialector any) string {
+	path := sqliteFilePath(dialector)
+	if path == "" {
+		return ""
+	}
+	return path + ".lock"
+}
+
+// sqliteFilePath returns the path of the SQL Lite database file, or an empty path for an in memory database and for
+// the other drivers
+func sqliteFilePath(dialector any) string {
+	sqliteDialector, ok := dialector.(*sqlite.Dialector)
+	if !ok {
+		return ""
+	}
+
+	path, query, _ := strings.Cut(strings.TrimPrefix(sqliteDialector.DSN, "file:"), "?")
+	if path == "" || path == ":memory:" || strings.Contains(query, "mode=memory") {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,40 @@
+//go:build !unix
+
+package data
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+// lockFile acquires a lock by creating the file, and waits until the context is done for another process to release it
+// by removing the file. Unlike on Unix systems the lock is not released when the process stops, a lock file that was
+// left behind has to be removed by hand. An empty path locks nothing.
+func lockFile(ctx context.Context, path string) (func(), error) {
+	if path == "" {
+		return func() {}, nil
+	}
+
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+		if err == nil {
+			file.Close()
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errLockTimeout
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	return func() {
+		os.Remove(path)
+	}, nil
+}
@@ -0,0 +1,103 @@
+package data
+
+import (
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"sanitize/logic"
+)
+
+func TestConcurrentSetup(t *testing.T) {
+	connection := "sqlite://" + filepath.Join(t.TempDir(), "replicas.db")
+	words := []Word{{Sensitive: "select", PatternType: logic.Literal}, {Sensitive: "drop", PatternType: logic.Literal},
+		{Sensitive: "delete", PatternType: logic.Literal}}
+
+	//Every replica opens its own connections, as separate processes would
+	const replicas = 4
+	reports := make([]SeedReport, replicas)
+	errs := make([]error, replicas)
+	var wait sync.WaitGroup
+	for i := range replicas {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			db, err := Initialize(connection)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			reports[i], errs[i] = db.Seed("replicas", "1", words)
+		}()
+	}
+	wait.Wait()
+
+	var applied int
+	for i := range replicas {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if reports[i].Applied {
+			applied++
+			if len(reports[i].Inserted) != len(words) {
+				t.Fatalf("Expected every word to be inserted once %v", reports[i])
+			}
+		}
+	}
+	if applied != 1 {
+		t.Fatalf("Expected exactly one replica to import the seed set, got %d", applied)
+	}
+
+	db, err := Open(connection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, err := db.SchemaVersion(); err != nil || version != latestSchema() {
+		t.Fatalf("Expected the database to be migrated once %v %v", version, err)
+	}
+	loaded, err := db.ListWords()
+	if err != nil || !slices.Equal(sensitiveValues(loaded), []string{"SELECT", "DROP", "DELETE"}) {
+		t.Fatalf("Expected the seeded words once %v %v", loaded, err)
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.db")
+	if lock := sqliteLockPath(sqlite.Open(path)); lock != path+".lock" {
+		t.Fatalf("Expected the lock file next to the database %q", lock)
+	}
+	if lock := sqliteLockPath(sqlite.Open("file::memory:?cache=shared")); lock != "" {
+		t.Fatalf("Expected no lock file for an in memory database %q", lock)
+	}
+
+	db, err := Open("sqlite://" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//The second holder waits until the first releases the lock
+	held := make(chan struct{})
+	release := make(chan struct{})
+	go db.exclusive(func() error {
+		close(held)
+		<-release
+		return nil
+	})
+	<-held
+
+	acquired := make(chan time.Time)
+	go db.exclusive(func() error {
+		acquired <- time.Now()
+		return nil
+	})
+
+	time.Sleep(100 * time.Millisecond)
+	released := time.Now()
+	close(release)
+	if at := <-acquired; at.Before(released) {
+		t.Fatal("Expected the lock to be held by one holder at a time")
+	}
+}
@@ -0,0 +1,48 @@
+//go:build unix
+
+package data
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// lockFile acquires an exclusive lock on the file, creating it when needed, and waits until the context is done for
+// another process to release it. The lock is released when the file is closed, also when the process stops. An empty
+// path locks nothing.
+func lockFile(ctx context.Context, path string) (func(), error) {
+	if path == "" {
+		return func() {}, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) && !errors.Is(err, syscall.EINTR) {
+			file.Close()
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			file.Close()
+			return nil, errLockTimeout
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
@@ -0,0 +1,790 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sanitize/logic"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a WordStore that keeps the word lists, allowlists, categories, policies and versions of every tenant
+// in memory. It has no dependencies, which makes it suited as a fake in tests, and its content is lost once the process
+// stops, see FileStore to keep it. Every change is applied to a copy of the tenant's content, so a change is applied as
+// a whole or not at all.
+type MemoryStore struct {
+	state  *memoryState
+	tenant string
+	reason string
+
+	//batch is the content the changes of a batch are applied to, they record no version of their own
+	batch *memoryBatch
+}
+
+// memoryBatch is the copy of the content of a tenant that the changes of a batch are applied to, before it replaces the
+// content of the tenant
+type memoryBatch struct {
+	tenant  *memoryTenant
+	nextID  func() uint
+	actions batchActions
+}
+
+// memoryState is the content of a memory store, shared by the stores of every tenant
+type memoryState struct {
+	mutex   sync.RWMutex
+	content memoryContent
+
+	//save is called with the changed content before it replaces the current content, the change is discarded when
+	//saving fails
+	save func(memoryContent) error
+}
+
+// memoryContent is the content of every tenant of a memory store, keyed by the tenant name. It is also the format of
+// the file of a FileStore.
+type memoryContent struct {
+	// NextID is the last id that was assigned to a word, phrase or policy
+	NextID  uint                     `json:"nextId" yaml:"nextId"`
+	Tenants map[string]*memoryTenant `json:"tenants" yaml:"tenants"`
+}
+
+// memoryTenant is the content of a single tenant of a memory store. The content is never changed once it is part of
+// the store, changes are applied to a copy which then replaces it.
+type memoryTenant struct {
+	Words    []Word         `json:"words,omitempty" yaml:"words,omitempty"`
+	Phrases  []memoryPhrase `json:"phrases,omitempty" yaml:"phrases,omitempty"`
+	Policies []Policy       `json:"policies,omitempty" yaml:"policies,omitempty"`
+	Versions []listVersion  `json:"versions,omitempty" yaml:"versions,omitempty"`
+}
+
+// memoryPhrase is a phrase of the global allowlist of a memory store
+type memoryPhrase struct {
+	ID     uint   `json:"id" yaml:"id"`
+	Phrase string `json:"phrase" yaml:"phrase"`
+}
+
+// NewMemoryStore creates an empty memory store, the store manages the shared tenant
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{state: &memoryState{content: memoryContent{Tenants: map[string]*memoryTenant{}}}}
+}
+
+// ForTenant returns a store on the same memory that only reads and changes the content of the tenant. An empty tenant
+// is the shared tenant.
+func (store *MemoryStore) ForTenant(tenant string) WordStore {
+	return store.forTenant(tenant)
+}
+
+func (store *MemoryStore) forTenant(tenant string) *MemoryStore {
+	return &MemoryStore{state: store.state, tenant: tenant}
+}
+
+// Tenant returns the name of the tenant this store is scoped to
+func (store *MemoryStore) Tenant() string {
+	return store.tenant
+}
+
+// WithReason returns a store on the same memory and tenant that records the reason in the versions of its changes
+func (store *MemoryStore) WithReason(reason string) WordStore {
+	return store.withReason(reason)
+}
+
+func (store *MemoryStore) withReason(reason string) *MemoryStore {
+	return &MemoryStore{state: store.state, tenant: store.tenant, reason: reason}
+}
+
+// Batch applies the changes that fn makes through the provided store to a single copy of the content of the tenant,
+// which replaces the content once fn returns and records a single version named after the actions of the changes,
+// unless the store has a reason. Every change of the batch is applied to a copy of its own, so a failed change is
+// discarded on its own. The store is locked until fn returns.
+func (store *MemoryStore) Batch(fn func(store WordStore) error) error {
+	return store.update(func(tenant *memoryTenant, nextID func() uint) error {
+		batch := &memoryBatch{tenant: tenant, nextID: nextID}
+		scoped := &MemoryStore{state: store.state, tenant: store.tenant, reason: store.reason, batch: batch}
+		if err := fn(scoped); err != nil {
+			return err
+		}
+		if len(batch.actions) == 0 {
+			return errUnchanged
+		}
+
+		*tenant = *batch.tenant
+		reason := cmp.Or(store.reason, batch.actions.reason())
+		_, _, err := tenant.recordVersion(store.tenant, store.sharedNumber(), reason)
+		return err
+	})
+}
+
+// ListWords returns all the words of the global list of the tenant, ordered by their id
+func (store *MemoryStore) ListWords() ([]Word, error) {
+	return cloneWords(store.current().Words), nil
+}
+
+// AddWord validates and adds a word to the global list of the tenant, see normalizeWord. A word with a PolicyID is
+// added to the policy, should the policy not exist ErrNotFound is returned
+func (store *MemoryStore) AddWord(word Word) (uint, error) {
+	var id uint
+	err := store.change("add word", func(tenant *memoryTenant, nextID func() uint) error {
+		var err error
+		id, err = tenant.addWord(word, nextID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// UpdateEntries changes the values of the words of the tenant, either every word is changed or none. The outcome of
+// every update is returned in the same order.
+func (store *MemoryStore) UpdateEntries(updates []EntryUpdate) ([]error, error) {
+	errs := make([]error, len(updates))
+
+	err := store.change("update words", func(tenant *memoryTenant, _ func() uint) error {
+		values, err := prepareUpdates(updates, errs, tenant.prepareUpdate)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for i, update := range updates {
+			word := tenant.word(update.ID)
+			word.Sensitive, word.UpdatedAt = values[i], now
+		}
+		return nil
+	})
+	return updateOutcome(errs, err)
+}
+
+// SetEnabled enables or disables a word of the tenant, should the word not exist ErrNotFound is returned
+func (store *MemoryStore) SetEnabled(id uint, enabled bool) error {
+	return store.change(enableAction(enabled), func(tenant *memoryTenant, _ func() uint) error {
+		word := tenant.word(id)
+		if word == nil {
+			return ErrNotFound
+		}
+		word.Disabled, word.UpdatedAt = !enabled, time.Now()
+		return nil
+	})
+}
+
+// RemoveEntry removes a word of the tenant together with its categories, should the word not exist ErrNotFound is
+// returned
+func (store *MemoryStore) RemoveEntry(id uint) error {
+	return store.change("remove word", func(tenant *memoryTenant, _ func() uint) error {
+		for _, words := range tenant.wordLists() {
+			if index := slices.IndexFunc(*words, func(word Word) bool { return word.ID == id }); index >= 0 {
+				*words = slices.Delete(*words, index, index+1)
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+// ListCategories returns the names of all the categories that are in use by the tenant, in alphabetical order
+func (store *MemoryStore) ListCategories() ([]string, error) {
+	tenant := store.current()
+
+	var categories []string
+	for _, words := range tenant.wordLists() {
+		for _, word := range *words {
+			categories = append(categories, word.Categories...)
+		}
+	}
+
+	slices.Sort(categories)
+	return slices.Compact(categories), nil
+}
+
+// AddCategories adds categories to a word, categories the word is already part of are ignored. Should the word not
+// exist ErrNotFound is returned
+func (store *MemoryStore) AddCategories(id uint, categories []string) error {
+	parsed, err := logic.ParseCategories(categories)
+	if err != nil {
+		return err
+	}
+
+	return store.change("add categories", func(tenant *memoryTenant, _ func() uint) error {
+		word := tenant.word(id)
+		if word == nil {
+			return ErrNotFound
+		}
+
+		word.Categories = append(word.Categories, parsed...)
+		slices.Sort(word.Categories)
+		word.Categories = slices.Compact(word.Categories)
+		return nil
+	})
+}
+
+// RemoveCategories removes categories from a word. Should the word not be part of any of the categories ErrNotFound is
+// returned
+func (store *MemoryStore) RemoveCategories(id uint, categories []string) error {
+	parsed, err := logic.ParseCategories(categories)
+	if err != nil {
+		return err
+	}
+
+	return store.change("remove categories", func(tenant *memoryTenant, _ func() uint) error {
+		word := tenant.word(id)
+		if word == nil {
+			return ErrNotFound
+		}
+
+		remaining := slices.DeleteFunc(word.Categories, func(category string) bool {
+			return slices.Contains(parsed, category)
+		})
+		if len(remaining) == len(word.Categories) {
+			return ErrNotFound
+		}
+		word.Categories = nil
+		if len(remaining) > 0 {
+			word.Categories = remaining
+		}
+		return nil
+	})
+}
+
+// ListAllowedPhrases returns the phrases of the global allowlist of the tenant keyed by their id
+func (store *MemoryStore) ListAllowedPhrases() (map[uint]string, error) {
+	records := make(map[uint]string)
+	for _, phrase := range store.current().Phrases {
+		records[phrase.ID] = phrase.Phrase
+	}
+	return records, nil
+}
+
+// AddAllowedPhrase adds a phrase to the global allowlist of the tenant, should it already be present ErrDuplicate is
+// returned
+func (store *MemoryStore) AddAllowedPhrase(phrase string) (uint, error) {
+	phrase, err := normalizePhrase(phrase)
+	if err != nil {
+		return 0, err
+	}
+
+	var id uint
+	err = store.change("add phrase", func(tenant *memoryTenant, nextID func() uint) error {
+		if slices.ContainsFunc(tenant.Phrases, func(current memoryPhrase) bool { return current.Phrase == phrase }) {
+			return ErrDuplicate
+		}
+
+		id = nextID()
+		tenant.Phrases = append(tenant.Phrases, memoryPhrase{ID: id, Phrase: phrase})
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// UpdateAllowedPhrase changes a phrase of the global allowlist of the tenant in place, should the phrase not exist
+// ErrNotFound is returned and should the new phrase already be present ErrDuplicate
+func (store *MemoryStore) UpdateAllowedPhrase(id uint, phrase string) error {
+	phrase, err := normalizePhrase(phrase)
+	if err != nil {
+		return err
+	}
+
+	return store.change("update phrase", func(tenant *memoryTenant, _ func() uint) error {
+		index := slices.IndexFunc(tenant.Phrases, func(current memoryPhrase) bool { return current.ID == id })
+		if index < 0 {
+			return ErrNotFound
+		}
+		if tenant.Phrases[index].Phrase == phrase {
+			return errUnchanged
+		}
+		if slices.ContainsFunc(tenant.Phrases, func(current memoryPhrase) bool { return current.Phrase == phrase }) {
+			return ErrDuplicate
+		}
+
+		tenant.Phrases[index].Phrase = phrase
+		return nil
+	})
+}
+
+// RemoveAllowedPhrase removes a phrase from the global allowlist of the tenant, should the phrase not exist
+// ErrNotFound is returned
+func (store *MemoryStore) RemoveAllowedPhrase(id uint) error {
+	return store.change("remove phrase", func(tenant *memoryTenant, _ func() uint) error {
+		index := slices.IndexFunc(tenant.Phrases, func(phrase memoryPhrase) bool { return phrase.ID == id })
+		if index < 0 {
+			return ErrNotFound
+		}
+		tenant.Phrases = slices.Delete(tenant.Phrases, index, index+1)
+		return nil
+	})
+}
+
+// ListPolicies returns all the policies of the tenant in alphabetical order, with their own words, exclusions and
+// phrases
+func (store *MemoryStore) ListPolicies() ([]Policy, error) {
+	return clonePolicies(store.current().Policies), nil
+}
+
+// GetPolicy returns the policy with the provided name, should the policy not exist ErrNotFound is returned
+func (store *MemoryStore) GetPolicy(name string) (Policy, error) {
+	p, found := findPolicy(store.current().Policies, strings.ToLower(strings.TrimSpace(name)))
+	if !found {
+		return Policy{}, ErrNotFound
+	}
+	return clonePolicies([]Policy{p})[0], nil
+}
+
+// AddPolicy validates and adds a policy together with its words, exclusions and phrases, see normalizePolicy. Should
+// the base policy not exist ErrNotFound is returned
+func (store *MemoryStore) AddPolicy(p Policy) (uint, error) {
+	var err error
+	p.Name, err = parsePolicyName(p.Name)
+	if err != nil {
+		return 0, err
+	}
+	p, err = normalizePolicy(p)
+	if err != nil {
+		return 0, err
+	}
+
+	var id uint
+	err = store.change("add policy", func(tenant *memoryTenant, nextID func() uint) error {
+		if err := checkBase(p, tenant.findPolicy); err != nil {
+			return err
+		}
+		if _, found := findPolicy(tenant.Policies, p.Name); found {
+			return ErrDuplicate
+		}
+
+		stored, err := newPolicy(p, nextID(), nextID)
+		if err != nil {
+			return err
+		}
+		id = stored.ID
+		tenant.Policies = sortedPolicies(append(tenant.Policies, stored))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// UpdatePolicy replaces the policy with the same name as a whole, should the policy not exist ErrNotFound is returned
+func (store *MemoryStore) UpdatePolicy(p Policy) error {
+	p, err := normalizePolicy(p)
+	if err != nil {
+		return err
+	}
+
+	return store.change("update policy", func(tenant *memoryTenant, nextID func() uint) error {
+		index := slices.IndexFunc(tenant.Policies, func(current Policy) bool {
+			return current.Name == strings.ToLower(strings.TrimSpace(p.Name))
+		})
+		if index < 0 {
+			return ErrNotFound
+		}
+
+		p.Name = tenant.Policies[index].Name
+		if err := checkBase(p, tenant.findPolicy); err != nil {
+			return err
+		}
+
+		stored, err := newPolicy(p, tenant.Policies[index].ID, nextID)
+		if err != nil {
+			return err
+		}
+		tenant.Policies[index] = stored
+		return nil
+	})
+}
+
+// RemovePolicy removes a policy together with its words, exclusions and phrases. A policy that is the base of another
+// policy can not be removed, should the policy not exist ErrNotFound is returned
+func (store *MemoryStore) RemovePolicy(name string) error {
+	return store.change("remove policy", func(tenant *memoryTenant, _ func() uint) error {
+		name = strings.ToLower(strings.TrimSpace(name))
+		index := slices.IndexFunc(tenant.Policies, func(p Policy) bool { return p.Name == name })
+		if index < 0 {
+			return ErrNotFound
+		}
+
+		if slices.ContainsFunc(tenant.Policies, func(p Policy) bool { return p.Base == name }) {
+			return fmt.Errorf("policy %q is the base of another policy", name)
+		}
+		tenant.Policies = slices.Delete(tenant.Policies, index, index+1)
+		return nil
+	})
+}
+
+// RecordVersion records the current content of the tenant as a new version when it changed since its latest version,
+// such as when the file of a FileStore was edited, or when the shared tenant changed since. The version of the shared
+// tenant is recorded first.
+func (store *MemoryStore) RecordVersion(reason string) (VersionedList, error) {
+	var result VersionedList
+	if store.tenant != "" {
+		shared, err := store.forTenant("").RecordVersion(reason)
+		if err != nil {
+			return VersionedList{}, err
+		}
+		result.SharedNumber, result.Shared = shared.Number, shared.Snapshot
+	}
+
+	err := store.update(func(tenant *memoryTenant, _ func() uint) error {
+		version, recorded, err := tenant.recordVersion(store.tenant, result.SharedNumber, reason)
+		if err != nil {
+			return err
+		}
+		result.Version, result.Snapshot = toVersion(version), tenant.snapshot()
+		if !recorded {
+			return errUnchanged
+		}
+		return nil
+	})
+	if err != nil {
+		return VersionedList{}, err
+	}
+	return result, nil
+}
+
+// ListVersions returns all the recorded versions of the tenant, the newest version first
+func (store *MemoryStore) ListVersions() ([]Version, error) {
+	versions := store.current().Versions
+
+	result := make([]Version, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		result = append(result, toVersion(versions[i]))
+	}
+	return result, nil
+}
+
+// LoadVersion returns the recorded version of the tenant with its content, and the content of the version of the
+// shared tenant it was recorded with. Should the version not exist ErrNotFound is returned
+func (store *MemoryStore) LoadVersion(number uint) (VersionedList, error) {
+	version, snapshot, err := store.findVersion(number)
+	if err != nil {
+		return VersionedList{}, err
+	}
+
+	result := VersionedList{Version: version, Snapshot: snapshot}
+	if store.tenant != "" && version.SharedNumber != 0 {
+		_, result.Shared, err = store.forTenant("").findVersion(version.SharedNumber)
+		if err != nil {
+			return VersionedList{}, fmt.Errorf("shared version %d: %w", version.SharedNumber, err)
+		}
+	}
+	return result, nil
+}
+
+// DiffVersions returns the changes that lead from the first recorded version of the tenant to the second. Should
+// either version not exist ErrNotFound is returned
+func (store *MemoryStore) DiffVersions(from uint, to uint) (VersionDiff, error) {
+	older, fromSnapshot, err := store.findVersion(from)
+	if err != nil {
+		return VersionDiff{}, err
+	}
+	newer, toSnapshot, err := store.findVersion(to)
+	if err != nil {
+		return VersionDiff{}, err
+	}
+
+	return diffSnapshots(older, fromSnapshot, newer, toSnapshot), nil
+}
+
+// RollbackVersion replaces the content of the tenant with the content of the recorded version. The words and policies
+// are restored with the ids and timestamps they had in the version, the phrases receive new ids. Should the version not
+// exist ErrNotFound is returned
+func (store *MemoryStore) RollbackVersion(number uint) error {
+	_, snapshot, err := store.findVersion(number)
+	if err != nil {
+		return err
+	}
+
+	action := fmt.Sprintf("rollback to version %d", number)
+	return store.change(action, func(tenant *memoryTenant, nextID func() uint) error {
+		//The ids of a memory store are never assigned twice, so the ids of the version are still free
+		tenant.Words = cloneWords(snapshot.Words)
+		tenant.Policies = sortedPolicies(clonePolicies(snapshot.Policies))
+
+		tenant.Phrases = nil
+		for _, phrase := range snapshot.Phrases {
+			tenant.Phrases = append(tenant.Phrases, memoryPhrase{ID: nextID(), Phrase: phrase})
+		}
+		return nil
+	})
+}
+
+// Watch reports changes made outside of the store, the memory of a store can only be changed through the store so no
+// change is ever reported. The channel is closed once the context is done.
+func (store *MemoryStore) Watch(ctx context.Context, _ time.Duration) (<-chan struct{}, error) {
+	changes := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		close(changes)
+	}()
+	return changes, nil
+}
+
+// current returns the content of the tenant, which must not be changed. Within a batch it is the content as changed by
+// the batch so far.
+func (store *MemoryStore) current() *memoryTenant {
+	if store.batch != nil {
+		return store.batch.tenant
+	}
+
+	store.state.mutex.RLock()
+	defer store.state.mutex.RUnlock()
+
+	if tenant, ok := store.state.content.Tenants[store.tenant]; ok {
+		return tenant
+	}
+	return &memoryTenant{}
+}
+
+// update applies the change to a copy of the content of the tenant, which replaces the content once the change
+// succeeded and was saved. The change receives the function that assigns a new id.
+func (store *MemoryStore) update(change func(tenant *memoryTenant, nextID func() uint) error) error {
+	store.state.mutex.Lock()
+	defer store.state.mutex.Unlock()
+
+	content := memoryContent{NextID: store.state.content.NextID, Tenants: maps.Clone(store.state.content.Tenants)}
+	if content.Tenants == nil {
+		content.Tenants = map[string]*memoryTenant{}
+	}
+
+	tenant := &memoryTenant{}
+	if current, ok := content.Tenants[store.tenant]; ok {
+		tenant = current.clone()
+	}
+
+	nextID := func() uint {
+		content.NextID++
+		return content.NextID
+	}
+	if err := change(tenant, nextID); err != nil {
+		if errors.Is(err, errUnchanged) {
+			return nil
+		}
+		return err
+	}
+
+	content.Tenants[store.tenant] = tenant
+	if store.state.save != nil {
+		if err := store.state.save(content); err != nil {
+			return err
+		}
+	}
+	store.state.content = content
+	return nil
+}
+
+// change applies the change to the content of the tenant in the same way as update, and records the changed content
+// as a new version with the reason of the store, or the action when the store has none. Within a batch the change is
+// applied to a copy of the content of the batch instead, and the batch records the version.
+func (store *MemoryStore) change(action string, change func(tenant *memoryTenant, nextID func() uint) error) error {
+	if store.batch != nil {
+		tenant := store.batch.tenant.clone()
+		if err := change(tenant, store.batch.nextID); err != nil {
+			if errors.Is(err, errUnchanged) {
+				return nil
+			}
+			return err
+		}
+		store.batch.tenant = tenant
+		store.batch.actions.add(action)
+		return nil
+	}
+
+	return store.update(func(tenant *memoryTenant, nextID func() uint) error {
+		if err := change(tenant, nextID); err != nil {
+			return err
+		}
+		_, _, err := tenant.recordVersion(store.tenant, store.sharedNumber(), cmp.Or(store.reason, action))
+		return err
+	})
+}
+
+// sharedNumber returns the number of the latest version of the shared tenant, or zero for the shared tenant itself.
+// The caller holds the lock of the state.
+func (store *MemoryStore) sharedNumber() uint {
+	shared, ok := store.state.content.Tenants[""]
+	if store.tenant == "" || !ok || len(shared.Versions) == 0 {
+		return 0
+	}
+	return shared.Versions[len(shared.Versions)-1].Number
+}
+
+// findVersion returns the recorded version of the tenant with its content
+func (store *MemoryStore) findVersion(number uint) (Version, Snapshot, error) {
+	for _, version := range store.current().Versions {
+		if version.Number != number {
+			continue
+		}
+
+		snapshot, err := readSnapshot(version)
+		if err != nil {
+			return Version{}, Snapshot{}, fmt.Errorf("version %d can not be read: %w", number, err)
+		}
+		return toVersion(version), snapshot, nil
+	}
+	return Version{}, Snapshot{}, ErrNotFound
+}
+
+// clone returns a copy of the content that can be changed
+func (tenant *memoryTenant) clone() *memoryTenant {
+	return &memoryTenant{
+		Words:    cloneWords(tenant.Words),
+		Phrases:  slices.Clone(tenant.Phrases),
+		Policies: clonePolicies(tenant.Policies),
+		Versions: slices.Clone(tenant.Versions),
+	}
+}
+
+// recordVersion records the content of the tenant as a new version when it changed since its latest version, it
+// returns the latest version and whether it was recorded
+func (tenant *memoryTenant) recordVersion(name string, sharedNumber uint, reason string) (listVersion, bool, error) {
+	latest := listVersion{Tenant: name}
+	if count := len(tenant.Versions); count > 0 {
+		latest = tenant.Versions[count-1]
+	}
+
+	version, record, err := nextVersion(latest, tenant.snapshot(), sharedNumber, reason)
+	if err != nil || !record {
+		return version, false, err
+	}
+
+	version.CreatedAt = time.Now()
+	tenant.Versions = append(tenant.Versions, version)
+	return version, true, nil
+}
+
+// snapshot returns the content of the tenant as it is recorded in a version
+func (tenant *memoryTenant) snapshot() Snapshot {
+	var phrases []string
+	for _, phrase := range tenant.Phrases {
+		phrases = append(phrases, phrase.Phrase)
+	}
+	return Snapshot{Words: cloneWords(tenant.Words), Phrases: phrases, Policies: clonePolicies(tenant.Policies)}
+}
+
+// wordLists returns the global list and the word list of every policy of the tenant
+func (tenant *memoryTenant) wordLists() []*[]Word {
+	lists := []*[]Word{&tenant.Words}
+	for i := range tenant.Policies {
+		lists = append(lists, &tenant.Policies[i].Words)
+	}
+	return lists
+}
+
+// word returns the word with the id from any word list of the tenant, or nil when there is none
+func (tenant *memoryTenant) word(id uint) *Word {
+	for _, words := range tenant.wordLists() {
+		for i := range *words {
+			if (*words)[i].ID == id {
+				return &(*words)[i]
+			}
+		}
+	}
+	return nil
+}
+
+// addWord validates the word and adds it to the global list, or to the policy with its PolicyID
+func (tenant *memoryTenant) addWord(word Word, nextID func() uint) (uint, error) {
+	word, err := normalizeWord(word)
+	if err != nil {
+		return 0, err
+	}
+
+	words := &tenant.Words
+	if word.PolicyID != 0 {
+		index := slices.IndexFunc(tenant.Policies, func(p Policy) bool { return p.ID == word.PolicyID })
+		if index < 0 {
+			return 0, ErrNotFound
+		}
+		words = &tenant.Policies[index].Words
+	}
+
+	if _, found := findWord(*words, word.Sensitive); found {
+		return 0, ErrDuplicate
+	}
+
+	word.ID = nextID()
+	word.CreatedAt = time.Now()
+	word.UpdatedAt = word.CreatedAt
+	*words = append(*words, word)
+	return word.ID, nil
+}
+
+// prepareUpdate finds the word of the update and validates its new value, it returns the value as it is stored
+func (tenant *memoryTenant) prepareUpdate(update EntryUpdate) (string, error) {
+	word := tenant.word(update.ID)
+	if word == nil {
+		return "", ErrNotFound
+	}
+
+	value, err := updatedValue(*word, update.Value)
+	if err != nil {
+		return "", err
+	}
+
+	for _, words := range tenant.wordLists() {
+		if !slices.ContainsFunc(*words, func(current Word) bool { return current.ID == word.ID }) {
+			continue
+		}
+		if slices.ContainsFunc(*words, func(current Word) bool { return current.ID != word.ID && current.Sensitive == value }) {
+			return "", ErrDuplicate
+		}
+	}
+	return value, nil
+}
+
+// findPolicy returns the policy with the provided name, or ErrNotFound
+func (tenant *memoryTenant) findPolicy(name string) (Policy, error) {
+	if p, found := findPolicy(tenant.Policies, name); found {
+		return p, nil
+	}
+	return Policy{}, ErrNotFound
+}
+
+// newPolicy returns the normalized policy as it is stored with the id, its words are validated and receive new ids
+func newPolicy(p Policy, id uint, nextID func() uint) (Policy, error) {
+	result := p
+	result.ID, result.Words = id, nil
+
+	policyTenant := &memoryTenant{Policies: []Policy{result}}
+	for _, word := range p.Words {
+		word.PolicyID = id
+		if _, err := policyTenant.addWord(word, nextID); err != nil {
+			return Policy{}, fmt.Errorf("word %q: %w", word.Sensitive, err)
+		}
+	}
+	result.Words = policyTenant.Policies[0].Words
+	return result, nil
+}
+
+// cloneWords returns a copy of the words that can be changed
+func cloneWords(words []Word) []Word {
+	result := slices.Clone(words)
+	for i := range result {
+		result[i].Categories = slices.Clone(result[i].Categories)
+	}
+	return result
+}
+
+// clonePolicies returns a copy of the policies that can be changed
+func clonePolicies(policies []Policy) []Policy {
+	result := slices.Clone(policies)
+	for i := range result {
+		result[i].Words = cloneWords(result[i].Words)
+		result[i].Excluded = slices.Clone(result[i].Excluded)
+		result[i].Phrases = slices.Clone(result[i].Phrases)
+	}
+	return result
+}
+
+// sortedPolicies orders the policies by their name
+func sortedPolicies(policies []Policy) []Policy {
+	slices.SortFunc(policies, func(a Policy, b Policy) int { return strings.Compare(a.Name, b.Name) })
+	return policies
+}
@@ -0,0 +1,481 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// this is a private object definition used in the database by Gorm to build the schema history table. Every applied
+// migration is a row, the highest version is the version of the schema
+type schemaHistory struct {
+	Version   uint `gorm:"primaryKey; autoIncrement:false;"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// TableName keeps the name of the schema history table independent of the naming strategy of GORM
+func (schemaHistory) TableName() string {
+	return "schema_history"
+}
+
+// Migration is a versioned change to the schema of the database
+type Migration struct {
+	Version uint
+	Name    string
+	// AppliedAt is when the migration was applied, it is zero for a pending migration
+	AppliedAt time.Time
+}
+
+// migration is a versioned change to the schema together with the change that reverts it. A migration never refers to
+// the models of the package, which keep changing, but to its own definition of the tables as they were at its version.
+type migration struct {
+	version uint
+	name    string
+	up      func(tx *gorm.DB) error
+	down    func(tx *gorm.DB) error
+}
+
+// ErrUnknownSchema is returned when the database was migrated to a newer schema than this version of the service knows
+var ErrUnknownSchema = errors.New("unknown schema version")
+
+// migrations are all the changes to the schema in the order they are applied, new migrations are added at the end with
+// the next version and released migrations are never changed. A field that is added to a model needs a migration that
+// adds its column, the models are not migrated automatically.
+var migrations = []migration{
+	{version: 1, name: "create tables", up: createTables, down: dropTables},
+	{version: 2, name: "drop single tenant unique indexes", up: dropSingleTenantIndexes, down: createSingleTenantIndexes},
+	{version: 3, name: "create seed sets", up: createSeedSets, down: dropSeedSets},
+	{version: 4, name: "unset zero reveal counts", up: unsetZeroReveals, down: zeroUnsetReveals},
+	{version: 5, name: "compare words case sensitively", up: collateWordsBinary, down: collateWordsDefault},
+}
+
+// createTables creates the tables of the words, allowlists, categories, policies and versions of every tenant.
+// Databases that were set up before migrations were recorded already hold these tables, which are completed instead.
+func createTables(tx *gorm.DB) error {
+	type sensitiveWord struct {
+		ID            uint   `gorm:"primaryKey; autoIncrement:true;"`
+		Tenant        string `gorm:"index:idx_policy_sensitive,unique,priority:1"`
+		PolicyID      uint   `gorm:"index:idx_policy_sensitive,unique,priority:2"`
+		Sensitive     string `gorm:"index:idx_policy_sensitive,unique,priority:3"`
+		PatternType   string
+		Severity      string
+		Replacement   string
+		CaseSensitive bool
+		Boundary      string
+		Disabled      bool
+		Description   string
+		Author        string
+		CreatedAt     time.Time
+		UpdatedAt     time.Time
+	}
+	type allowedPhrase struct {
+		ID       uint   `gorm:"primaryKey; autoIncrement:true;"`
+		Tenant   string `gorm:"index:idx_policy_phrase,unique,priority:1"`
+		PolicyID uint   `gorm:"index:idx_policy_phrase,unique,priority:2"`
+		Phrase   string `gorm:"index:idx_policy_phrase,unique,priority:3"`
+	}
+	type wordCategory struct {
+		ID       uint   `gorm:"primaryKey; autoIncrement:true;"`
+		Tenant   string `gorm:"index"`
+		WordID   uint   `gorm:"index:idx_word_category,unique"`
+		Category string `gorm:"index:idx_word_category,unique"`
+	}
+	type policy struct {
+		ID              uint   `gorm:"primaryKey; autoIncrement:true;"`
+		Tenant          string `gorm:"index:idx_policy_name,unique,priority:1"`
+		Name            string `gorm:"index:idx_policy_name,unique,priority:2"`
+		Base            string
+		MaskStrategy    string
+		MaskCharacter   string
+		MaskToken       string
+		MaskRevealFirst int
+		MaskRevealLast  int
+		MaskLabel       string
+	}
+	type policyExclusion struct {
+		ID        uint   `gorm:"primaryKey; autoIncrement:true;"`
+		Tenant    string `gorm:"index"`
+		PolicyID  uint   `gorm:"index:idx_policy_exclusion,unique,priority:1"`
+		Sensitive string `gorm:"index:idx_policy_exclusion,unique,priority:2"`
+	}
+	type listVersion struct {
+		ID           uint   `gorm:"primaryKey; autoIncrement:true;"`
+		Tenant       string `gorm:"index:idx_tenant_version,unique,priority:1"`
+		Number       uint   `gorm:"index:idx_tenant_version,unique,priority:2"`
+		SharedNumber uint
+		Reason       string
+		Content      string
+		CreatedAt    time.Time
+	}
+
+	tables := map[string]any{
+		"sensitive_words":   &sensitiveWord{},
+		"allowed_phrases":   &allowedPhrase{},
+		"word_categories":   &wordCategory{},
+		"policies":          &policy{},
+		"policy_exclusions": &policyExclusion{},
+		"list_versions":     &listVersion{},
+	}
+	for _, table := range tableNames {
+		if err := tx.Table(table).AutoMigrate(tables[table]); err != nil {
+			return fmt.Errorf("table %s: %w", table, err)
+		}
+	}
+
+	//Rows from before tenants and policies have neither, they are part of the global list of the shared tenant
+	backfill := map[string][]string{
+		"sensitive_words":   {"tenant", "policy_id"},
+		"allowed_phrases":   {"tenant", "policy_id"},
+		"word_categories":   {"tenant"},
+		"policies":          {"tenant"},
+		"policy_exclusions": {"tenant"},
+	}
+	for table, columns := range backfill {
+		for _, column := range columns {
+			var value any = ""
+			if column == "policy_id" {
+				value = 0
+			}
+			if err := tx.Table(table).Where(column+" IS NULL").Update(column, value).Error; err != nil {
+				return fmt.Errorf("table %s: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// tableNames are the tables created by the first migration, in the order they are created
+var tableNames = []string{"sensitive_words", "allowed_phrases", "word_categories", "policies", "policy_exclusions",
+	"list_versions"}
+
+// dropTables drops the tables created by the first migration together with their content
+func dropTables(tx *gorm.DB) error {
+	for i := len(tableNames) - 1; i >= 0; i-- {
+		if err := tx.Migrator().DropTable(tableNames[i]); err != nil {
+			return fmt.Errorf("table %s: %w", tableNames[i], err)
+		}
+	}
+	return nil
+}
+
+// singleTenantIndexes are the unique indexes of the word and phrase tables from before policies and tenants, they were
+// never removed by AutoMigrate and refuse the same word in a second policy or tenant
+var singleTenantIndexes = []struct{ table, index, column string }{
+	{"sensitive_words", "idx_sensitive", "sensitive"},
+	{"allowed_phrases", "idx_phrase", "phrase"},
+}
+
+// dropSingleTenantIndexes drops the single tenant unique indexes of databases that were set up before policies
+func dropSingleTenantIndexes(tx *gorm.DB) error {
+	for _, index := range singleTenantIndexes {
+		if !tx.Migrator().HasIndex(index.table, index.index) {
+			continue
+		}
+		if err := tx.Migrator().DropIndex(index.table, index.index); err != nil {
+			return fmt.Errorf("index %s: %w", index.index, err)
+		}
+	}
+	return nil
+}
+
+// createSingleTenantIndexes restores the single tenant unique indexes, which fails once the same word or phrase is
+// stored more than once
+func createSingleTenantIndexes(tx *gorm.DB) error {
+	for _, index := range singleTenantIndexes {
+		statement := fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s)", tx.Statement.Quote(index.index),
+			tx.Statement.Quote(index.table), tx.Statement.Quote(index.column))
+		if err := tx.Exec(statement).Error; err != nil {
+			return fmt.Errorf("index %s: %w", index.index, err)
+		}
+	}
+	return nil
+}
+
+// createSeedSets creates the table of the imported seed sets
+func createSeedSets(tx *gorm.DB) error {
+	type seedSet struct {
+		ID        uint   `gorm:"primaryKey; autoIncrement:true;"`
+		Name      string `gorm:"index:idx_seed_name,unique"`
+		Checksum  string
+		Inserted  int
+		Skipped   int
+		AppliedAt time.Time
+	}
+	return tx.Table("seed_sets").AutoMigrate(&seedSet{})
+}
+
+// dropSeedSets drops the table of the imported seed sets, the seed sets are imported again on the next startup
+func dropSeedSets(tx *gorm.DB) error {
+	return tx.Migrator().DropTable("seed_sets")
+}
+
+// revealColumns are the policy columns with the number of characters revealed by the partial mask strategy
+var revealColumns = []string{"mask_reveal_first", "mask_reveal_last"}
+
+// unsetZeroReveals clears the reveal counts of 0, which meant not set before an explicit 0 could override the default
+func unsetZeroReveals(tx *gorm.DB) error {
+	for _, column := range revealColumns {
+		if err := tx.Table("policies").Where(column+" = ?", 0).Update(column, nil).Error; err != nil {
+			return fmt.Errorf("column %s: %w", column, err)
+		}
+	}
+	return nil
+}
+
+// zeroUnsetReveals stores the reveal counts that are not set as 0 again
+func zeroUnsetReveals(tx *gorm.DB) error {
+	for _, column := range revealColumns {
+		if err := tx.Table("policies").Where(column+" IS NULL").Update(column, 0).Error; err != nil {
+			return fmt.Errorf("column %s: %w", column, err)
+		}
+	}
+	return nil
+}
+
+// binaryCollations are the case sensitive collations of the databases whose default collation compares text regardless
+// of its case. SQLite and PostgreSQL compare text case sensitively by default.
+var binaryCollations = map[string]string{
+	"sqlserver": "Latin1_General_100_BIN2",
+	"mysql":     "utf8mb4_bin",
+}
+
+// wordColumn is a column that holds words as they are stored, together with the unique index it is part of. The model
+// defines the table as it was created by the first migration.
+type wordColumn struct {
+	table string
+	index string
+	model any
+}
+
+// wordColumns are the columns that hold words as they are stored, case sensitive words and regular expressions keep
+// their case and are only the same word when their case is the same
+func wordColumns() []wordColumn {
+	type sensitiveWord struct {
+		Tenant    string `gorm:"index:idx_policy_sensitive,unique,priority:1"`
+		PolicyID  uint   `gorm:"index:idx_policy_sensitive,unique,priority:2"`
+		Sensitive string `gorm:"index:idx_policy_sensitive,unique,priority:3"`
+	}
+	type policyExclusion struct {
+		PolicyID  uint   `gorm:"index:idx_policy_exclusion,unique,priority:1"`
+		Sensitive string `gorm:"index:idx_policy_exclusion,unique,priority:2"`
+	}
+	return []wordColumn{
+		{table: "sensitive_words", index: "idx_policy_sensitive", model: &sensitiveWord{}},
+		{table: "policy_exclusions", index: "idx_policy_exclusion", model: &policyExclusion{}},
+	}
+}
+
+// collateWordsBinary compares the words case sensitively on databases whose default collation does not, both in
+// queries and in the unique indexes
+func collateWordsBinary(tx *gorm.DB) error {
+	return collateWords(tx, true)
+}
+
+// collateWordsDefault compares the words with the default collation of the database again, which fails once words
+// that only differ in case are stored
+func collateWordsDefault(tx *gorm.DB) error {
+	return collateWords(tx, false)
+}
+
+// collateWords changes the collation of the word columns to the binary collation of the database, or back to its
+// default collation. The columns keep the type that GORM gave them.
+func collateWords(tx *gorm.DB, binary bool) error {
+	dialect := tx.Dialector.Name()
+	collation, ok := binaryCollations[dialect]
+	if !ok {
+		return nil
+	}
+
+	for _, column := range wordColumns() {
+		statement := &gorm.Statement{DB: tx}
+		if err := statement.Parse(column.model); err != nil {
+			return fmt.Errorf("table %s: %w", column.table, err)
+		}
+		dataType := tx.Dialector.DataTypeOf(statement.Schema.LookUpField("Sensitive"))
+		table, name := tx.Statement.Quote(column.table), tx.Statement.Quote("sensitive")
+
+		var err error
+		switch dialect {
+		case "sqlserver":
+			if !binary {
+				collation = "DATABASE_DEFAULT"
+			}
+
+			//SQL Server does not change a column that is part of an index
+			err = tx.Migrator().DropIndex(column.table, column.index)
+			if err == nil {
+				err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s COLLATE %s", table, name, dataType,
+					collation)).Error
+			}
+			if err == nil {
+				err = tx.Table(column.table).Migrator().CreateIndex(column.model, column.index)
+			}
+		case "mysql":
+			definition := dataType
+			if binary {
+				definition += " CHARACTER SET utf8mb4 COLLATE " + collation
+			}
+			err = tx.Exec(fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s", table, name, definition)).Error
+		}
+		if err != nil {
+			return fmt.Errorf("table %s: %w", column.table, err)
+		}
+	}
+	return nil
+}
+
+// latestSchema is the version of the schema after every migration was applied
+func latestSchema() uint {
+	return migrations[len(migrations)-1].version
+}
+
+// SchemaVersion returns the version of the schema of the database, zero when no migration was applied yet
+func (sanitize *SanitizeDB) SchemaVersion() (uint, error) {
+	if !sanitize.db.Migrator().HasTable(&schemaHistory{}) {
+		return 0, nil
+	}
+
+	var version uint
+	err := sanitize.db.Model(&schemaHistory{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// checkSchema refuses a database whose schema was migrated by a newer version of the service, as this version does
+// not know how to read or change it
+func (sanitize *SanitizeDB) checkSchema() error {
+	version, err := sanitize.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version > latestSchema() {
+		return fmt.Errorf("%w %d, the latest version known to this service is %d", ErrUnknownSchema, version,
+			latestSchema())
+	}
+	return nil
+}
+
+// Migrations returns every migration known to the service in the order they are applied, with the time the applied
+// migrations were applied
+func (sanitize *SanitizeDB) Migrations() ([]Migration, error) {
+	applied := make(map[uint]time.Time)
+	if sanitize.db.Migrator().HasTable(&schemaHistory{}) {
+		var rows []schemaHistory
+		if err := sanitize.db.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			applied[row.Version] = row.AppliedAt
+		}
+	}
+
+	result := make([]Migration, 0, len(migrations))
+	for _, m := range migrations {
+		result = append(result, Migration{Version: m.version, Name: m.name, AppliedAt: applied[m.version]})
+	}
+	return result, nil
+}
+
+// MigrateUp applies the pending migrations up to and including the target version in order, a target of zero applies
+// every pending migration. Every migration is applied in its own transaction together with its schema history row,
+// the applied migrations are returned even when a later migration fails. Instances that migrate the same database at
+// the same time wait for each other, see exclusive.
+func (sanitize *SanitizeDB) MigrateUp(target uint) (applied []Migration, err error) {
+	err = sanitize.exclusive(func() error {
+		applied, err = sanitize.migrateUp(target)
+		return err
+	})
+	return applied, err
+}
+
+// migrateUp applies the pending migrations while the caller holds the database lock
+func (sanitize *SanitizeDB) migrateUp(target uint) ([]Migration, error) {
+	if target == 0 {
+		target = latestSchema()
+	}
+	if err := sanitize.prepareMigration(target); err != nil {
+		return nil, err
+	}
+
+	current, err := sanitize.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range migrations {
+		if m.version <= current || m.version > target {
+			continue
+		}
+
+		row := schemaHistory{Version: m.version, Name: m.name, AppliedAt: time.Now()}
+		err := sanitize.db.Transaction(func(tx *gorm.DB) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&row).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %d %s: %w", m.version, m.name, err)
+		}
+		applied = append(applied, Migration{Version: row.Version, Name: row.Name, AppliedAt: row.AppliedAt})
+	}
+	return applied, nil
+}
+
+// MigrateDown reverts the applied migrations after the target version, the latest first. A target of zero reverts
+// every migration, which drops every table and its content. The reverted migrations are returned even when a later
+// migration fails. Instances that migrate the same database at the same time wait for each other, see exclusive.
+func (sanitize *SanitizeDB) MigrateDown(target uint) (reverted []Migration, err error) {
+	err = sanitize.exclusive(func() error {
+		reverted, err = sanitize.migrateDown(target)
+		return err
+	})
+	return reverted, err
+}
+
+// migrateDown reverts the applied migrations while the caller holds the database lock
+func (sanitize *SanitizeDB) migrateDown(target uint) ([]Migration, error) {
+	if err := sanitize.prepareMigration(target); err != nil {
+		return nil, err
+	}
+
+	current, err := sanitize.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.version > current || m.version <= target {
+			continue
+		}
+
+		err := sanitize.db.Transaction(func(tx *gorm.DB) error {
+			if err := m.down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaHistory{Version: m.version}).Error
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("migration %d %s: %w", m.version, m.name, err)
+		}
+		reverted = append(reverted, Migration{Version: m.version, Name: m.name})
+	}
+	return reverted, nil
+}
+
+// prepareMigration validates the target version and creates the schema history table when it does not exist yet
+func (sanitize *SanitizeDB) prepareMigration(target uint) error {
+	if target > latestSchema() {
+		return fmt.Errorf("%w %d, the latest version known to this service is %d", ErrUnknownSchema, target,
+			latestSchema())
+	}
+	if err := sanitize.checkSchema(); err != nil {
+		return err
+	}
+	return sanitize.db.AutoMigrate(&schemaHistory{})
+}
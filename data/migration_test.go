@@ -0,0 +1,115 @@
+package data
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	connection := "sqlite://" + filepath.Join(t.TempDir(), "migrations.db")
+	db, err := Open(connection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version, err := db.SchemaVersion(); err != nil || version != 0 {
+		t.Fatalf("Expected an empty database to have no schema %v %v", version, err)
+	}
+	applied, err := db.MigrateUp(1)
+	if err != nil || len(applied) != 1 || applied[0].Version != 1 {
+		t.Fatalf("Expected the first migration to be applied %v %v", applied, err)
+	}
+
+	known, err := db.Migrations()
+	if err != nil || len(known) != len(migrations) || known[0].AppliedAt.IsZero() || !known[1].AppliedAt.IsZero() {
+		t.Fatalf("Expected the first migration to be applied and the second pending %v %v", known, err)
+	}
+
+	if applied, err = db.MigrateUp(0); err != nil || len(applied) != len(migrations)-1 {
+		t.Fatalf("Expected the pending migrations to be applied %v %v", applied, err)
+	}
+	if applied, err = db.MigrateUp(0); err != nil || len(applied) != 0 {
+		t.Fatalf("Expected no migration to be applied twice %v %v", applied, err)
+	}
+	if _, err = db.MigrateUp(latestSchema() + 1); !errors.Is(err, ErrUnknownSchema) {
+		t.Fatalf("Expected an unknown target to be refused %v", err)
+	}
+
+	if _, err = db.AddEntry("migrated"); err != nil {
+		t.Fatal(err)
+	}
+
+	reverted, err := db.MigrateDown(0)
+	if err != nil || len(reverted) != len(migrations) || reverted[0].Version != latestSchema() {
+		t.Fatalf("Expected every migration to be reverted, the latest first %v %v", reverted, err)
+	}
+	if db.db.Migrator().HasTable("sensitive_words") {
+		t.Fatal("Expected the tables to be dropped")
+	}
+
+	//A newer version of the service migrated the database
+	if err = db.db.Create(&schemaHistory{Version: latestSchema() + 1, Name: "future"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Initialize(connection); !errors.Is(err, ErrUnknownSchema) {
+		t.Fatalf("Expected a newer schema to be refused %v", err)
+	}
+	if _, err = db.MigrateDown(0); !errors.Is(err, ErrUnknownSchema) {
+		t.Fatalf("Expected a newer schema not to be reverted %v", err)
+	}
+}
+
+func TestMigrateExistingDatabase(t *testing.T) {
+	connection := "sqlite://" + filepath.Join(t.TempDir(), "existing.db")
+	db, err := Open(connection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//The tables of the first release, created by AutoMigrate without a schema history
+	type sensitiveWord struct {
+		ID        uint   `gorm:"primaryKey; autoIncrement:true;"`
+		Sensitive string `gorm:"index:idx_sensitive,unique"`
+	}
+	type allowedPhrase struct {
+		ID     uint   `gorm:"primaryKey; autoIncrement:true;"`
+		Phrase string `gorm:"index:idx_phrase,unique"`
+	}
+	if err = db.db.AutoMigrate(&sensitiveWord{}, &allowedPhrase{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.db.Create(&sensitiveWord{Sensitive: "EXISTING"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = Initialize(connection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, err := db.SchemaVersion(); err != nil || version != latestSchema() {
+		t.Fatalf("Expected the existing database to be migrated %v %v", version, err)
+	}
+	if db.db.Migrator().HasIndex("sensitive_words", "idx_sensitive") || db.db.Migrator().HasIndex("allowed_phrases", "idx_phrase") {
+		t.Fatal("Expected the single tenant indexes to be dropped")
+	}
+
+	words, err := db.ListWords()
+	if err != nil || len(words) != 1 || words[0].Sensitive != "EXISTING" {
+		t.Fatalf("Expected the existing words to be kept %v %v", words, err)
+	}
+	if _, err = db.forTenant("acme").AddEntry("existing"); err != nil {
+		t.Fatalf("Expected the same word to be added for another tenant %v", err)
+	}
+	if _, err = db.forTenant("acme").AddAllowedPhrase("existing phrase"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.AddAllowedPhrase("existing phrase"); err != nil {
+		t.Fatalf("Expected the same phrase to be added for another tenant %v", err)
+	}
+
+	//The indexes can not be restored once the same word is stored twice
+	if _, err = db.MigrateDown(1); err == nil {
+		t.Fatal("Expected the single tenant indexes not to be restored")
+	}
+}
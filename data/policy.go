@@ -0,0 +1,438 @@
+package data
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"sanitize/logic"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// DefaultPolicy is the reserved name of the base policy that refers to the global word list and allowlist
+const DefaultPolicy = "default"
+
+// maxPolicyDepth limits the number of base policies a policy can inherit from
+const maxPolicyDepth = 16
+
+var policyName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// this is a private object definition used in the database by Gorm to build the policy table. The words, allowlist
+// phrases and exclusions of a policy are stored in their own tables, referring to the policy by its ID
+type policy struct {
+	ID              uint   `gorm:"primaryKey; autoIncrement:true;"`
+	Tenant          string `gorm:"index:idx_policy_name,unique,priority:1"`
+	Name            string `gorm:"index:idx_policy_name,unique,priority:2"`
+	Base            string
+	MaskStrategy    string
+	MaskCharacter   string
+	MaskToken       string
+	MaskRevealFirst *int
+	MaskRevealLast  *int
+	MaskLabel       string
+}
+
+// this is a private object definition used in the database by Gorm to build the table of words a policy removes from
+// its base policy
+type policyExclusion struct {
+	ID        uint   `gorm:"primaryKey; autoIncrement:true;"`
+	Tenant    string `gorm:"index"`
+	PolicyID  uint   `gorm:"index:idx_policy_exclusion,unique,priority:1"`
+	Sensitive string `gorm:"index:idx_policy_exclusion,unique,priority:2"`
+}
+
+// Policy is a named word list with its own allowlist and mask options. A policy inherits the words, allowlist phrases
+// and mask options of its base policy, adds its own words and phrases, and removes the excluded words.
+type Policy struct {
+	ID   uint   `json:"id" yaml:"id"`
+	Name string `json:"name" yaml:"name"`
+	// Base is the name of the policy this policy inherits from, DefaultPolicy for the global list, or empty for none
+	Base string `json:"base,omitempty" yaml:"base,omitempty"`
+	// Mask overrides the mask options of the base policy, options that are not set are inherited
+	Mask     logic.MaskOptions `json:"mask" yaml:"mask"`
+	Words    []Word            `json:"words,omitempty" yaml:"words,omitempty"`
+	Excluded []string          `json:"excluded,omitempty" yaml:"excluded,omitempty"`
+	Phrases  []string          `json:"phrases,omitempty" yaml:"phrases,omitempty"`
+}
+
+// ListPolicies returns all the policies of the tenant in alphabetical order, with their own words, exclusions and phrases.
+// In the case where it cannot connect or an error occurred the method will return an error
+func (sanitize *SanitizeDB) ListPolicies() ([]Policy, error) {
+	var rows []policy
+
+	err := sanitize.scoped().Order("name").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Policy, 0, len(rows))
+	for _, row := range rows {
+		p, err := sanitize.toPolicy(row)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+
+	return result, nil
+}
+
+// GetPolicy returns the policy with the provided name, with its own words, exclusions and phrases. Should the policy
+// not exist an "entry not found" error will be returned
+func (sanitize *SanitizeDB) GetPolicy(name string) (Policy, error) {
+	row, err := sanitize.findPolicy(name)
+	if err != nil {
+		return Policy{}, err
+	}
+
+	return sanitize.toPolicy(row)
+}
+
+// AddPolicy provides the ability to add a policy. The name is stored in lowercase, and may only contain letters,
+// digits, dashes and underscores. The base policy must exist, and all the words of the policy must be valid patterns.
+// The policy is added together with its words, exclusions and phrases, or not at all.
+func (sanitize *SanitizeDB) AddPolicy(p Policy) (uint, error) {
+	var err error
+	p.Name, err = parsePolicyName(p.Name)
+	if err != nil {
+		return 0, err
+	}
+	p, err = normalizePolicy(p)
+	if err != nil {
+		return 0, err
+	}
+
+	var row policy
+	err = sanitize.change("add policy", func(tx *SanitizeDB) error {
+		if err := tx.validateBase(p); err != nil {
+			return err
+		}
+
+		row = fromPolicy(p)
+		row.Tenant = sanitize.tenant
+		duplicate, err := exists(tx.db.Where("tenant = ? AND name = ?", row.Tenant, row.Name), &policy{})
+		if err != nil {
+			return err
+		}
+		if duplicate {
+			return ErrDuplicate
+		}
+
+		if err := tx.db.Create(&row).Error; err != nil {
+			return fmt.Errorf("entry not added: %w", err)
+		}
+		return insertPolicyContent(tx.db, sanitize.tenant, row.ID, p)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return row.ID, nil
+}
+
+// UpdatePolicy provides the ability to replace the base, mask options, words, exclusions and phrases of the policy
+// with the same name. The policy keeps its ID, its words are replaced as a whole. Should the policy not exist an "entry
+// not found" error will be returned
+func (sanitize *SanitizeDB) UpdatePolicy(p Policy) error {
+	p, err := normalizePolicy(p)
+	if err != nil {
+		return err
+	}
+
+	return sanitize.change("update policy", func(tx *SanitizeDB) error {
+		current, err := tx.findPolicy(p.Name)
+		if err != nil {
+			return err
+		}
+
+		p.Name = current.Name
+		if err := tx.validateBase(p); err != nil {
+			return err
+		}
+
+		row := fromPolicy(p)
+		row.ID, row.Tenant = current.ID, current.Tenant
+		if err := tx.db.Save(&row).Error; err != nil {
+			return err
+		}
+		if err := deletePolicyContent(tx.db, row.Tenant, row.ID); err != nil {
+			return err
+		}
+		return insertPolicyContent(tx.db, row.Tenant, row.ID, p)
+	})
+}
+
+// RemovePolicy provides the ability to remove a policy together with its words, exclusions and phrases. A policy that
+// is the base of another policy can not be removed. Should the policy not exist an "entry not found" error will be
+// returned
+func (sanitize *SanitizeDB) RemovePolicy(name string) error {
+	return sanitize.change("remove policy", func(tx *SanitizeDB) error {
+		row, err := tx.findPolicy(name)
+		if err != nil {
+			return err
+		}
+
+		var dependents int64
+		if err := tx.scoped().Model(&policy{}).Where("base = ?", row.Name).Count(&dependents).Error; err != nil {
+			return err
+		}
+		if dependents > 0 {
+			return fmt.Errorf("policy %q is the base of another policy", row.Name)
+		}
+
+		if err := deletePolicyContent(tx.db, row.Tenant, row.ID); err != nil {
+			return err
+		}
+		return tx.db.Delete(&policy{}, row.ID).Error
+	})
+}
+
+// EffectivePolicy resolves the policy with the provided name against its base policies. The words of the result are
+// the words of the base policy without the excluded words, and the words of the policy itself. A word of the policy
+// replaces an equal word of the base policy. The phrases are the phrases of the base policy and of the policy itself,
+// and the mask options of the policy are merged on top of the options of the base policy. The DefaultPolicy resolves to
+// effective global word list and allowlist of the tenant, which includes the shared global list.
+func (sanitize *SanitizeDB) EffectivePolicy(name string) (Policy, error) {
+	return resolvePolicy(strings.ToLower(strings.TrimSpace(name)), 0, sanitize.defaultPolicy, sanitize.GetPolicy)
+}
+
+// defaultPolicy returns the effective global word list and allowlist of the tenant as the DefaultPolicy
+func (sanitize *SanitizeDB) defaultPolicy() (Policy, error) {
+	words, err := sanitize.EffectiveWords()
+	if err != nil {
+		return Policy{}, err
+	}
+	phrases, err := sanitize.EffectiveAllowedPhrases()
+	if err != nil {
+		return Policy{}, err
+	}
+	return Policy{Name: DefaultPolicy, Words: words, Phrases: sortedValues(phrases)}, nil
+}
+
+// resolvePolicy resolves the policy with the provided name against its base policies, see EffectivePolicy. The
+// defaults function returns the DefaultPolicy, and find returns the policy with the provided name
+func resolvePolicy(name string, depth int, defaults func() (Policy, error), find func(string) (Policy, error)) (Policy, error) {
+	if depth > maxPolicyDepth {
+		return Policy{}, fmt.Errorf("policy %q inherits from more than %d policies", name, maxPolicyDepth)
+	}
+
+	switch name {
+	case "":
+		return Policy{}, nil
+	case DefaultPolicy:
+		return defaults()
+	}
+
+	p, err := find(name)
+	if err != nil {
+		return Policy{}, err
+	}
+
+	base, err := resolvePolicy(p.Base, depth+1, defaults, find)
+	if err != nil {
+		return Policy{}, err
+	}
+
+	result := Policy{ID: p.ID, Name: p.Name, Base: p.Base, Mask: base.Mask.Merge(p.Mask)}
+	for _, word := range base.Words {
+		if !containsWord(p.Excluded, word) && !containsWord(sensitiveValues(p.Words), word) {
+			result.Words = append(result.Words, word)
+		}
+	}
+	result.Words = append(result.Words, p.Words...)
+
+	result.Phrases = append(result.Phrases, base.Phrases...)
+	for _, phrase := range p.Phrases {
+		if !containsFold(result.Phrases, phrase) {
+			result.Phrases = append(result.Phrases, phrase)
+		}
+	}
+
+	return result, nil
+}
+
+// validateBase checks whether the base policy exists, and that the policy does not inherit from itself
+func (sanitize *SanitizeDB) validateBase(p Policy) error {
+	return checkBase(p, func(name string) (Policy, error) {
+		row, err := sanitize.findPolicy(name)
+		return Policy{Name: row.Name, Base: row.Base}, err
+	})
+}
+
+// parsePolicyName normalizes the name of a policy that is added, the name is stored in lowercase and may only contain
+// letters, digits, dashes and underscores
+func parsePolicyName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !policyName.MatchString(name) || name == DefaultPolicy {
+		return "", fmt.Errorf("invalid policy name %q", name)
+	}
+	return name, nil
+}
+
+// checkBase checks whether the base policy exists, and that the policy does not inherit from itself. The find
+// function returns the policy with the provided name
+func checkBase(p Policy, find func(string) (Policy, error)) error {
+	name := strings.ToLower(strings.TrimSpace(p.Base))
+	for depth := 0; name != "" && name != DefaultPolicy; depth++ {
+		if name == p.Name || depth > maxPolicyDepth {
+			return fmt.Errorf("policy %q can not inherit from itself", p.Name)
+		}
+
+		base, err := find(name)
+		if err != nil {
+			return fmt.Errorf("base policy %q: %w", name, err)
+		}
+		name = base.Base
+	}
+	return nil
+}
+
+// findPolicy returns the policy record with the provided name
+func (sanitize *SanitizeDB) findPolicy(name string) (policy, error) {
+	var row policy
+
+	err := sanitize.scoped().Where("name = ?", strings.ToLower(strings.TrimSpace(name))).Limit(1).Find(&row).Error
+	if err != nil {
+		return policy{}, err
+	}
+	if row.ID == 0 {
+		return policy{}, ErrNotFound
+	}
+	return row, nil
+}
+
+// toPolicy converts the database record to a Policy, loading its words, exclusions and phrases
+func (sanitize *SanitizeDB) toPolicy(row policy) (Policy, error) {
+	result := Policy{
+		ID:   row.ID,
+		Name: row.Name,
+		Base: row.Base,
+		Mask: logic.MaskOptions{
+			Strategy:    logic.MaskStrategy(row.MaskStrategy),
+			Token:       row.MaskToken,
+			RevealFirst: row.MaskRevealFirst,
+			RevealLast:  row.MaskRevealLast,
+			Label:       row.MaskLabel,
+		},
+	}
+	if row.MaskCharacter != "" {
+		result.Mask.Character, _ = utf8.DecodeRuneInString(row.MaskCharacter)
+	}
+
+	var err error
+	result.Words, err = sanitize.listWords(row.ID)
+	if err != nil {
+		return Policy{}, err
+	}
+
+	phrases, err := sanitize.listAllowedPhrases(row.ID)
+	if err != nil {
+		return Policy{}, err
+	}
+	result.Phrases = sortedValues(phrases)
+
+	err = sanitize.scoped().Model(&policyExclusion{}).Where("policy_id = ?", row.ID).Order("id").
+		Pluck("sensitive", &result.Excluded).Error
+	if err != nil {
+		return Policy{}, err
+	}
+
+	return result, nil
+}
+
+// fromPolicy converts a Policy to its database record, without its words, exclusions and phrases
+func fromPolicy(p Policy) policy {
+	row := policy{
+		Name:            p.Name,
+		Base:            strings.ToLower(strings.TrimSpace(p.Base)),
+		MaskStrategy:    string(p.Mask.Strategy),
+		MaskToken:       p.Mask.Token,
+		MaskRevealFirst: p.Mask.RevealFirst,
+		MaskRevealLast:  p.Mask.RevealLast,
+		MaskLabel:       p.Mask.Label,
+	}
+	if p.Mask.Character != 0 {
+		row.MaskCharacter = string(p.Mask.Character)
+	}
+	return row
+}
+
+// insertPolicyContent adds the words, exclusions and phrases of the policy of the tenant, the exclusions and phrases
+// are stored as they are, see normalizePolicy
+func insertPolicyContent(tx *gorm.DB, tenant string, id uint, p Policy) error {
+	for _, word := range p.Words {
+		word.PolicyID = id
+		if _, err := insertWord(tx, tenant, word); err != nil {
+			return fmt.Errorf("word %q: %w", word.Sensitive, err)
+		}
+	}
+
+	for _, excluded := range p.Excluded {
+		if err := tx.Create(&policyExclusion{Tenant: tenant, PolicyID: id, Sensitive: excluded}).Error; err != nil {
+			return fmt.Errorf("excluded word %q: %w", excluded, err)
+		}
+	}
+
+	for _, phrase := range p.Phrases {
+		if err := tx.Create(&allowedPhrase{Tenant: tenant, PolicyID: id, Phrase: phrase}).Error; err != nil {
+			return fmt.Errorf("phrase %q: %w", phrase, err)
+		}
+	}
+
+	return nil
+}
+
+// deletePolicyContent removes the words, exclusions and phrases of the policy of the tenant
+func deletePolicyContent(tx *gorm.DB, tenant string, id uint) error {
+	words := tx.Model(&sensitiveWord{}).Select("id").Where("tenant = ? AND policy_id = ?", tenant, id)
+	if err := tx.Where("tenant = ? AND word_id IN (?)", tenant, words).Delete(&wordCategory{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("tenant = ? AND policy_id = ?", tenant, id).Delete(&sensitiveWord{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("tenant = ? AND policy_id = ?", tenant, id).Delete(&allowedPhrase{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("tenant = ? AND policy_id = ?", tenant, id).Delete(&policyExclusion{}).Error
+}
+
+// containsWord reports whether any of the values refers to the word, see Word.Equals
+func containsWord(values []string, word Word) bool {
+	for _, value := range values {
+		if word.Equals(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether the values contain the value, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, current := range values {
+		if strings.EqualFold(current, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedValues returns the values of the records ordered by their id
+func sortedValues(records map[uint]string) []string {
+	values := make([]string, 0, len(records))
+	for _, id := range slices.Sorted(maps.Keys(records)) {
+		values = append(values, records[id])
+	}
+	return values
+}
+
+// sensitiveValues returns the values of the words
+func sensitiveValues(words []Word) []string {
+	values := make([]string, 0, len(words))
+	for _, word := range words {
+		values = append(values, word.Sensitive)
+	}
+	return values
+}
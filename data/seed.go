@@ -0,0 +1,169 @@
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sanitize/logic"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// this is a private object definition used in the database by Gorm to build the seed set table. Every seed set that
+// was imported is a row, so that it is imported once per database
+type seedSet struct {
+	ID        uint   `gorm:"primaryKey; autoIncrement:true;"`
+	Name      string `gorm:"index:idx_seed_name,unique"`
+	Checksum  string
+	Inserted  int
+	Skipped   int
+	AppliedAt time.Time
+}
+
+// SeedReport is the outcome of importing a seed set
+type SeedReport struct {
+	Name     string
+	Checksum string
+	// Applied is false when the seed set was already imported with the same checksum, nothing is imported then
+	Applied bool
+	// Inserted are the words that were added, Skipped the words that were already present
+	Inserted []string
+	Skipped  []string
+}
+
+// SeedDirectory imports every seed file of the directory in alphabetical order, see SeedFile. Files with another
+// extension than .json, .yaml or .yml are ignored.
+func (sanitize *SanitizeDB) SeedDirectory(directory string) ([]SeedReport, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []SeedReport
+	for _, entry := range entries {
+		if entry.IsDir() || !slices.Contains([]string{".json", ".yaml", ".yml"}, strings.ToLower(filepath.Ext(entry.Name()))) {
+			continue
+		}
+
+		report, err := sanitize.SeedFile(filepath.Join(directory, entry.Name()))
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// SeedFile imports a seed file into the global list of the shared tenant, the file name is the name of the seed set.
+// A JSON or YAML seed file holds a list of words, either as plain strings, which are literal words, or with the same
+// fields as the words of a FileStore.
+func (sanitize *SanitizeDB) SeedFile(path string) (SeedReport, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return SeedReport{}, err
+	}
+
+	words, err := parseSeed(raw, strings.ToLower(filepath.Ext(path)) == ".json")
+	if err != nil {
+		return SeedReport{}, fmt.Errorf("seed file %q: %w", path, err)
+	}
+
+	sum := sha256.Sum256(raw)
+	return sanitize.Seed(filepath.Base(path), hex.EncodeToString(sum[:]), words)
+}
+
+// Seed imports a seed set into the global list of the shared tenant once per database. A seed set that was already
+// imported with the same checksum is skipped, a seed set whose checksum changed is imported again, which only adds the
+// words that are not present. Either every word of the seed set is imported or none, and an import is recorded as a
+// version of the shared tenant. Instances that import into the same database at the same time wait for each other, so
+// that only one of them imports the seed set.
+func (sanitize *SanitizeDB) Seed(name string, checksum string, words []Word) (SeedReport, error) {
+	report := SeedReport{Name: name, Checksum: checksum}
+
+	err := sanitize.exclusive(func() error {
+		return sanitize.seed(&report, words)
+	})
+	if err != nil {
+		return SeedReport{}, fmt.Errorf("seed set %q: %w", name, err)
+	}
+	return report, nil
+}
+
+// seed imports the words of the seed set of the report while the caller holds the database lock
+func (sanitize *SanitizeDB) seed(report *SeedReport, words []Word) error {
+	name, checksum := report.Name, report.Checksum
+	return sanitize.forTenant("").change("seed "+name, func(scoped *SanitizeDB) error {
+		tx := scoped.db
+		var applied seedSet
+		if err := tx.Where("name = ?", name).Limit(1).Find(&applied).Error; err != nil {
+			return err
+		}
+		if applied.ID != 0 && applied.Checksum == checksum {
+			return errUnchanged
+		}
+
+		//The import starts over when it is applied again after a conflicting version
+		report.Applied, report.Inserted, report.Skipped = true, nil, nil
+		for _, word := range words {
+			_, err := insertWord(tx, "", word)
+			switch {
+			case errors.Is(err, ErrDuplicate):
+				report.Skipped = append(report.Skipped, word.Sensitive)
+			case err != nil:
+				return fmt.Errorf("word %q: %w", word.Sensitive, err)
+			default:
+				report.Inserted = append(report.Inserted, word.Sensitive)
+			}
+		}
+
+		applied.Name, applied.Checksum, applied.AppliedAt = name, checksum, time.Now()
+		applied.Inserted, applied.Skipped = len(report.Inserted), len(report.Skipped)
+		return tx.Save(&applied).Error
+	})
+}
+
+// String describes the outcome of importing the seed set
+func (report SeedReport) String() string {
+	if !report.Applied {
+		return fmt.Sprintf("seed set %s was already imported", report.Name)
+	}
+	return fmt.Sprintf("imported seed set %s, %d words inserted and %d already present", report.Name,
+		len(report.Inserted), len(report.Skipped))
+}
+
+// parseSeed reads the words of a JSON or YAML seed file, words without a pattern type are literal and every word is
+// part of the global list
+func parseSeed(raw []byte, isJSON bool) ([]Word, error) {
+	unmarshal := yaml.Unmarshal
+	if isJSON {
+		unmarshal = json.Unmarshal
+	}
+
+	var values []string
+	if err := unmarshal(raw, &values); err == nil {
+		words := make([]Word, 0, len(values))
+		for _, value := range values {
+			words = append(words, Word{Sensitive: value, PatternType: logic.Literal})
+		}
+		return words, nil
+	}
+
+	var words []Word
+	if err := unmarshal(raw, &words); err != nil {
+		return nil, errors.New("expected a list of words")
+	}
+	for i := range words {
+		words[i].PolicyID = 0
+		if words[i].PatternType == "" {
+			words[i].PatternType = logic.Literal
+		}
+	}
+	return words, nil
+}
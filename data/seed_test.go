@@ -0,0 +1,76 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"sanitize/logic"
+)
+
+func TestSeed(t *testing.T) {
+	db, err := Initialize("sqlite://" + filepath.Join(t.TempDir(), "seed.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	directory := t.TempDir()
+	files := map[string]string{
+		"01-sql.json":  `["select", "drop", "Select"]`,
+		"02-more.yaml": "- sensitive: dr.p\n  patternType: regex\n  severity: high\n- sensitive: delete\n",
+		"readme.txt":   "ignored",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(directory, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reports, err := db.SeedDirectory(directory)
+	if err != nil || len(reports) != 2 || reports[0].Name != "01-sql.json" || !reports[0].Applied ||
+		!slices.Equal(reports[0].Inserted, []string{"select", "drop"}) || !slices.Equal(reports[0].Skipped, []string{"Select"}) ||
+		len(reports[1].Inserted) != 2 {
+		t.Fatalf("Expected every seed file to be imported %v %v", reports, err)
+	}
+
+	words, err := db.ListWords()
+	if err != nil || !slices.Equal(sensitiveValues(words), []string{"SELECT", "DROP", "dr.p", "DELETE"}) ||
+		words[2].PatternType != logic.Regex || words[2].Severity != logic.SeverityHigh {
+		t.Fatalf("Expected the seeded words %v %v", words, err)
+	}
+
+	//The seed sets are imported once, even after their words were removed
+	if err = db.RemoveEntry(words[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	reports, err = db.SeedDirectory(directory)
+	if err != nil || len(reports) != 2 || reports[0].Applied || reports[1].Applied {
+		t.Fatalf("Expected the seed sets not to be imported twice %v %v", reports, err)
+	}
+
+	//A changed seed set only adds the words that are not present
+	if err = os.WriteFile(filepath.Join(directory, "01-sql.json"), []byte(`["drop", "truncate"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report, err := db.SeedFile(filepath.Join(directory, "01-sql.json"))
+	if err != nil || !report.Applied || !slices.Equal(report.Inserted, []string{"truncate"}) ||
+		!slices.Equal(report.Skipped, []string{"drop"}) {
+		t.Fatalf("Expected the changed seed set to be imported %v %v", report, err)
+	}
+
+	//An invalid seed set imports nothing
+	if _, err = db.Seed("invalid", "1", []Word{{Sensitive: "valid", PatternType: logic.Literal},
+		{Sensitive: "(", PatternType: logic.Regex}}); err == nil {
+		t.Fatal("Expected an invalid word to be refused")
+	}
+	if words, err = db.ListWords(); err != nil || len(words) != 4 {
+		t.Fatalf("Expected no word of the invalid seed set %v %v", words, err)
+	}
+	if err = os.WriteFile(filepath.Join(directory, "03-broken.json"), []byte(`{"words": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.SeedDirectory(directory); err == nil {
+		t.Fatal("Expected a broken seed file to be refused")
+	}
+}
@@ -0,0 +1,295 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sanitize/logic"
+	"slices"
+	"strings"
+	"time"
+)
+
+// WordStore stores the word lists, allowlists, categories, policies and versions of every tenant. A store is scoped to
+// a single tenant, see ForTenant, and the store that is opened manages the shared tenant. SanitizeDB stores them in a
+// database through GORM, MemoryStore keeps them in memory and FileStore keeps them in a JSON or YAML file. Every change
+// records a new version of the tenant together with the change, see WithReason, and a Batch of changes records one.
+type WordStore interface {
+	// ForTenant returns a store on the same storage that only reads and changes the records of the tenant. An empty
+	// tenant is the shared tenant.
+	ForTenant(tenant string) WordStore
+	// Tenant returns the name of the tenant the store is scoped to
+	Tenant() string
+	// WithReason returns a store on the same storage and tenant that records the reason in the versions of its changes,
+	// instead of the name of the operation
+	WithReason(reason string) WordStore
+	// Batch calls fn with a store on the same tenant whose changes are applied together, in a single transaction that
+	// records a single version. A change of the batch that fails is discarded on its own and its error is returned to
+	// fn, which may carry on with the other changes, while an error returned by fn discards every change of the batch.
+	// fn may be called again when another instance changed the tenant at the same time, and must then start over.
+	Batch(fn func(store WordStore) error) error
+
+	// ListWords returns all the words of the global list of the tenant, ordered by their id
+	ListWords() ([]Word, error)
+	// AddWord validates and adds a word, to the global list or to the policy with its PolicyID. Should the word already
+	// be present ErrDuplicate is returned
+	AddWord(word Word) (uint, error)
+	// UpdateEntries changes the values of words in a single transaction, either every word is changed or none
+	UpdateEntries(updates []EntryUpdate) ([]error, error)
+	// SetEnabled enables or disables a word, a disabled word is kept but not matched
+	SetEnabled(id uint, enabled bool) error
+	// RemoveEntry removes a word together with its categories
+	RemoveEntry(id uint) error
+
+	// ListCategories returns the names of all the categories in use, in alphabetical order
+	ListCategories() ([]string, error)
+	// AddCategories adds categories to a word, categories the word is already part of are ignored
+	AddCategories(id uint, categories []string) error
+	// RemoveCategories removes categories from a word
+	RemoveCategories(id uint, categories []string) error
+
+	// ListAllowedPhrases returns the phrases of the global allowlist keyed by their id
+	ListAllowedPhrases() (map[uint]string, error)
+	// AddAllowedPhrase adds a phrase to the global allowlist
+	AddAllowedPhrase(phrase string) (uint, error)
+	// UpdateAllowedPhrase changes a phrase of the global allowlist in place, the phrase keeps its id
+	UpdateAllowedPhrase(id uint, phrase string) error
+	// RemoveAllowedPhrase removes a phrase from the global allowlist
+	RemoveAllowedPhrase(id uint) error
+
+	// ListPolicies returns all the policies in alphabetical order, with their own words, exclusions and phrases
+	ListPolicies() ([]Policy, error)
+	// GetPolicy returns the policy with the provided name
+	GetPolicy(name string) (Policy, error)
+	// AddPolicy validates and adds a policy together with its words, exclusions and phrases
+	AddPolicy(p Policy) (uint, error)
+	// UpdatePolicy replaces the policy with the same name as a whole
+	UpdatePolicy(p Policy) error
+	// RemovePolicy removes a policy that is not the base of another policy
+	RemovePolicy(name string) error
+
+	// RecordVersion records the current content as a new version when it changed outside the store, or when the shared
+	// tenant changed since the latest version of the tenant, and returns the latest version
+	RecordVersion(reason string) (VersionedList, error)
+	// ListVersions returns all the recorded versions, the newest version first
+	ListVersions() ([]Version, error)
+	// LoadVersion returns a recorded version with its content
+	LoadVersion(number uint) (VersionedList, error)
+	// DiffVersions returns the changes that lead from the first recorded version to the second
+	DiffVersions(from uint, to uint) (VersionDiff, error)
+	// RollbackVersion replaces the content with the content of a recorded version
+	RollbackVersion(number uint) error
+
+	// Watch reports changes that are made to the storage outside of this store, such as by another instance of the
+	// service or by editing the file of a FileStore. The storage is checked every interval until the context is done,
+	// and the channel is closed once it is.
+	Watch(ctx context.Context, interval time.Duration) (<-chan struct{}, error)
+}
+
+// errUnchanged is returned by a change to a store that turned out not to change anything, the change is then discarded
+// without an error and no version is recorded
+var errUnchanged = errors.New("nothing changed")
+
+// batchActions are the actions of the changes of a batch, in the order they were first applied
+type batchActions []string
+
+// add adds the action of a change that was applied
+func (actions *batchActions) add(action string) {
+	if !slices.Contains(*actions, action) {
+		*actions = append(*actions, action)
+	}
+}
+
+// reason names the version recorded by the batch after its actions
+func (actions batchActions) reason() string {
+	return strings.Join(actions, ", ")
+}
+
+// enableAction names the operation that enables or disables a word, in the versions it records
+func enableAction(enabled bool) string {
+	if enabled {
+		return "enable word"
+	}
+	return "disable word"
+}
+
+// normalizeWord validates the word that is about to be stored and returns it as it is stored. A word without a
+// severity is of low severity, and all words besides regular expressions and case sensitive words are uppercase.
+func normalizeWord(word Word) (Word, error) {
+	if err := logic.ValidatePattern(word.Sensitive, word.PatternType); err != nil {
+		return Word{}, err
+	}
+
+	categories, err := logic.ParseCategories(word.Categories)
+	if err != nil {
+		return Word{}, err
+	}
+	word.Categories = nil
+	if len(categories) > 0 {
+		word.Categories = categories
+	}
+
+	if _, err := logic.ParseBoundary(string(word.Boundary)); err != nil {
+		return Word{}, err
+	}
+
+	if word.Severity == 0 {
+		word.Severity = logic.SeverityLow
+	}
+	word.Sensitive = storedValue(word, word.Sensitive)
+	return word, nil
+}
+
+// normalizePhrase validates an allowlist phrase that is about to be stored and returns it as it is stored, in uppercase
+func normalizePhrase(phrase string) (string, error) {
+	if strings.TrimSpace(phrase) == "" {
+		return "", errors.New("phrase is empty")
+	}
+	return strings.ToUpper(phrase), nil
+}
+
+// normalizePolicy validates the base, exclusions and phrases of a policy that is about to be stored and returns it as
+// it is stored. Exclusions and phrases that are listed twice are refused with ErrDuplicate. The name is parsed by
+// parsePolicyName and the words are validated as they are added, see normalizeWord.
+func normalizePolicy(p Policy) (Policy, error) {
+	base := strings.ToLower(strings.TrimSpace(p.Base))
+	result := Policy{ID: p.ID, Name: p.Name, Base: base, Mask: p.Mask, Words: p.Words}
+
+	for _, excluded := range p.Excluded {
+		if strings.TrimSpace(excluded) == "" {
+			return Policy{}, errors.New("excluded word is empty")
+		}
+		if slices.Contains(result.Excluded, excluded) {
+			return Policy{}, fmt.Errorf("excluded word %q: %w", excluded, ErrDuplicate)
+		}
+		result.Excluded = append(result.Excluded, excluded)
+	}
+
+	for _, phrase := range p.Phrases {
+		normalized, err := normalizePhrase(phrase)
+		if err != nil {
+			return Policy{}, err
+		}
+		if slices.Contains(result.Phrases, normalized) {
+			return Policy{}, fmt.Errorf("phrase %q: %w", phrase, ErrDuplicate)
+		}
+		result.Phrases = append(result.Phrases, normalized)
+	}
+
+	return result, nil
+}
+
+// prepareUpdates validates every update of a batch before any is applied, so that the outcome of every update is
+// known. The prepare function validates a single update, its outcome is stored in errs in the order of the updates.
+func prepareUpdates[T any](updates []EntryUpdate, errs []error, prepare func(EntryUpdate) (T, error)) ([]T, error) {
+	prepared := make([]T, len(updates))
+	var failed bool
+	for i, update := range updates {
+		prepared[i], errs[i] = prepare(update)
+		failed = failed || errs[i] != nil
+	}
+	if failed {
+		return nil, errors.New("entries not updated")
+	}
+	return prepared, nil
+}
+
+// updateOutcome returns the outcome of every update of a batch. When the batch failed, the updates that were valid
+// were not applied either and report errNotApplied.
+func updateOutcome(errs []error, err error) ([]error, error) {
+	if err == nil {
+		return errs, nil
+	}
+
+	for i := range errs {
+		if errs[i] == nil {
+			errs[i] = errNotApplied
+		}
+	}
+	return errs, err
+}
+
+// updatedValue validates the new value of the word and returns it as it is stored
+func updatedValue(word Word, value string) (string, error) {
+	if err := logic.ValidatePattern(value, word.PatternType); err != nil {
+		return "", err
+	}
+	return storedValue(word, value), nil
+}
+
+// storedValue returns the value of the word as it is stored, regular expressions and case sensitive words are stored
+// as is and all other words as uppercase
+func storedValue(word Word, value string) string {
+	if word.PatternType != logic.Regex && !word.CaseSensitive {
+		return strings.ToUpper(value)
+	}
+	return value
+}
+
+// nextVersion returns the version that should be recorded for the snapshot after the latest version of a tenant, and
+// whether it has to be recorded. Nothing is recorded when the content did not change, or when the tenant never stored
+// anything, in which case the latest version is returned.
+func nextVersion(latest listVersion, snapshot Snapshot, sharedNumber uint, reason string) (listVersion, bool, error) {
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		return listVersion{}, false, err
+	}
+
+	if latest.Number == 0 && snapshot.empty() {
+		return latest, false, nil
+	}
+	if latest.Number != 0 && latest.Content == string(content) && latest.SharedNumber == sharedNumber {
+		return latest, false, nil
+	}
+
+	return listVersion{
+		Tenant:       latest.Tenant,
+		Number:       latest.Number + 1,
+		SharedNumber: sharedNumber,
+		Reason:       reason,
+		Content:      string(content),
+	}, true, nil
+}
+
+// readSnapshot reads the content of a recorded version
+func readSnapshot(version listVersion) (Snapshot, error) {
+	var snapshot Snapshot
+	if err := json.Unmarshal([]byte(version.Content), &snapshot); err != nil {
+		return Snapshot{}, err
+	}
+	return snapshot, nil
+}
+
+// poll calls changed every interval until the context is done, and reports every change on the returned channel. A
+// change that is not yet received is not reported twice.
+func poll(ctx context.Context, interval time.Duration, changed func() (bool, error)) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer close(changes)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			found, err := changed()
+			if err != nil {
+				log.Printf("Unable to check the word store for changes: %v", err)
+				continue
+			}
+			if found {
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return changes
+}
@@ -0,0 +1,606 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"sanitize/logic"
+)
+
+// storeBackend opens an empty WordStore of a backend, and a function that opens it again from its storage for the
+// backends that keep their content
+type storeBackend struct {
+	name string
+	open func(t *testing.T) (store WordStore, reopen func() (WordStore, error))
+}
+
+// storeBackends are the backends every contract of a WordStore is tested against, they must behave the same
+var storeBackends = []storeBackend{
+	{name: "database", open: func(t *testing.T) (WordStore, func() (WordStore, error)) {
+		db, err := Initialize(sampleDatabase)
+		t.Cleanup(func() { db.removeDatabaseFile(sampleDatabase) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &db, func() (WordStore, error) {
+			reopened, err := Open(sampleDatabase)
+			return &reopened, err
+		}
+	}},
+	{name: "memory", open: func(t *testing.T) (WordStore, func() (WordStore, error)) {
+		return NewMemoryStore(), nil
+	}},
+	{name: "words.json", open: openFileBackend("words.json")},
+	{name: "words.yaml", open: openFileBackend("words.yaml")},
+}
+
+// openFileBackend opens a file store with the name in a temporary directory
+func openFileBackend(name string) func(t *testing.T) (WordStore, func() (WordStore, error)) {
+	return func(t *testing.T) (WordStore, func() (WordStore, error)) {
+		path := filepath.Join(t.TempDir(), name)
+		store, err := OpenFileStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store, func() (WordStore, error) { return OpenFileStore(path) }
+	}
+}
+
+// refusedChange is a change every WordStore must refuse with the same error, errRefused accepts any error
+var errRefused = errors.New("any error")
+
+// refusedChanges are run against a store with the content of storeFixture, the IDs are those of the fixture
+var refusedChanges = []struct {
+	name   string
+	change func(store WordStore, ids fixtureIDs) error
+	want   error
+}{
+	{"duplicate word", func(store WordStore, _ fixtureIDs) error {
+		_, err := store.AddWord(Word{Sensitive: "First", PatternType: logic.Literal})
+		return err
+	}, ErrDuplicate},
+	{"invalid pattern", func(store WordStore, _ fixtureIDs) error {
+		_, err := store.AddWord(Word{Sensitive: "(", PatternType: logic.Regex})
+		return err
+	}, logic.ErrInvalidPattern},
+	{"invalid category", func(store WordStore, _ fixtureIDs) error {
+		_, err := store.AddWord(Word{Sensitive: "third", PatternType: logic.Literal, Categories: []string{"no way!"}})
+		return err
+	}, errRefused},
+	{"word of an unknown policy", func(store WordStore, _ fixtureIDs) error {
+		_, err := store.AddWord(Word{Sensitive: "third", PatternType: logic.Literal, PolicyID: 999})
+		return err
+	}, ErrNotFound},
+	{"update to a duplicate", func(store WordStore, ids fixtureIDs) error {
+		errs, _ := store.UpdateEntries([]EntryUpdate{{ID: ids.second, Value: "first"}})
+		return errs[0]
+	}, ErrDuplicate},
+	{"update of an unknown word", func(store WordStore, _ fixtureIDs) error {
+		errs, _ := store.UpdateEntries([]EntryUpdate{{ID: 999, Value: "third"}})
+		return errs[0]
+	}, ErrNotFound},
+	{"update in a failed batch", func(store WordStore, ids fixtureIDs) error {
+		errs, _ := store.UpdateEntries([]EntryUpdate{{ID: ids.first, Value: "third"}, {ID: 999, Value: "fourth"}})
+		return errs[0]
+	}, errNotApplied},
+	{"enable an unknown word", func(store WordStore, _ fixtureIDs) error {
+		return store.SetEnabled(999, true)
+	}, ErrNotFound},
+	{"remove an unknown word", func(store WordStore, _ fixtureIDs) error {
+		return store.RemoveEntry(999)
+	}, ErrNotFound},
+	{"categories of an unknown word", func(store WordStore, _ fixtureIDs) error {
+		return store.AddCategories(999, []string{"sql"})
+	}, ErrNotFound},
+	{"remove a missing category", func(store WordStore, ids fixtureIDs) error {
+		return store.RemoveCategories(ids.first, []string{"other"})
+	}, ErrNotFound},
+	{"empty phrase", func(store WordStore, _ fixtureIDs) error {
+		_, err := store.AddAllowedPhrase("  ")
+		return err
+	}, errRefused},
+	{"duplicate phrase", func(store WordStore, _ fixtureIDs) error {
+		_, err := store.AddAllowedPhrase("One Way")
+		return err
+	}, ErrDuplicate},
+	{"update an unknown phrase", func(store WordStore, _ fixtureIDs) error {
+		return store.UpdateAllowedPhrase(999, "another way")
+	}, ErrNotFound},
+	{"update to an empty phrase", func(store WordStore, ids fixtureIDs) error {
+		return store.UpdateAllowedPhrase(ids.phrase, " ")
+	}, errRefused},
+	{"update to a duplicate phrase", func(store WordStore, ids fixtureIDs) error {
+		return store.UpdateAllowedPhrase(ids.phrase, "Another Way")
+	}, ErrDuplicate},
+	{"remove an unknown phrase", func(store WordStore, _ fixtureIDs) error {
+		return store.RemoveAllowedPhrase(999)
+	}, ErrNotFound},
+	{"invalid policy name", func(store WordStore, _ fixtureIDs) error {
+		_, err := store.AddPolicy(Policy{Name: "no way"})
+		return err
+	}, errRefused},
+	{"default policy name", func(store WordStore, _ fixtureIDs) error {
+		_, err := store.AddPolicy(Policy{Name: DefaultPolicy})
+		return err
+	}, errRefused},
+	{"duplicate policy", func(store WordStore, _ fixtureIDs) error {
+		_, err := store.AddPolicy(Policy{Name: "STRICT"})
+		return err
+	}, ErrDuplicate},
+	{"unknown base policy", func(store WordStore, _ fixtureIDs) error {
+		_, err := store.AddPolicy(Policy{Name: "loose", Base: "missing"})
+		return err
+	}, ErrNotFound},
+	{"policy inheriting from itself", func(store WordStore, _ fixtureIDs) error {
+		return store.UpdatePolicy(Policy{Name: "strict", Base: "stricter"})
+	}, errRefused},
+	{"invalid policy word", func(store WordStore, _ fixtureIDs) error {
+		_, err := store.AddPolicy(Policy{Name: "loose", Words: []Word{{Sensitive: "(", PatternType: logic.Regex}}})
+		return err
+	}, logic.ErrInvalidPattern},
+	{"empty exclusion", func(store WordStore, _ fixtureIDs) error {
+		_, err := store.AddPolicy(Policy{Name: "loose", Excluded: []string{" "}})
+		return err
+	}, errRefused},
+	{"duplicate exclusion", func(store WordStore, _ fixtureIDs) error {
+		_, err := store.AddPolicy(Policy{Name: "loose", Excluded: []string{"first", "first"}})
+		return err
+	}, ErrDuplicate},
+	{"duplicate policy phrase", func(store WordStore, _ fixtureIDs) error {
+		return store.UpdatePolicy(Policy{Name: "strict", Phrases: []string{"a plan", "A Plan"}})
+	}, ErrDuplicate},
+	{"update an unknown policy", func(store WordStore, _ fixtureIDs) error {
+		return store.UpdatePolicy(Policy{Name: "missing"})
+	}, ErrNotFound},
+	{"remove an unknown policy", func(store WordStore, _ fixtureIDs) error {
+		return store.RemovePolicy("missing")
+	}, ErrNotFound},
+	{"remove a base policy", func(store WordStore, _ fixtureIDs) error {
+		return store.RemovePolicy("strict")
+	}, errRefused},
+	{"load an unknown version", func(store WordStore, _ fixtureIDs) error {
+		_, err := store.LoadVersion(99)
+		return err
+	}, ErrNotFound},
+	{"diff an unknown version", func(store WordStore, _ fixtureIDs) error {
+		_, err := store.DiffVersions(1, 99)
+		return err
+	}, ErrNotFound},
+	{"rollback to an unknown version", func(store WordStore, _ fixtureIDs) error {
+		return store.RollbackVersion(99)
+	}, ErrNotFound},
+}
+
+// fixtureIDs are the IDs of the words and of the first phrase added by storeFixture
+type fixtureIDs struct {
+	first  uint
+	second uint
+	phrase uint
+}
+
+// storeFixture adds two words, two phrases and two policies, the second policy based on the first
+func storeFixture(t *testing.T, store WordStore) fixtureIDs {
+	first, err := store.AddWord(Word{Sensitive: "first", PatternType: logic.Literal, Categories: []string{"test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.AddWord(Word{Sensitive: "second", PatternType: logic.Literal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	phrase, err := store.AddAllowedPhrase("one way")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.AddAllowedPhrase("another way"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.AddPolicy(Policy{Name: "strict"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.AddPolicy(Policy{Name: "stricter", Base: "strict"}); err != nil {
+		t.Fatal(err)
+	}
+	return fixtureIDs{first: first, second: second, phrase: phrase}
+}
+
+// testWordStore runs the same operations against every WordStore, the stores must behave the same
+func testWordStore(t *testing.T, store WordStore) {
+	first, err := store.AddWord(Word{Sensitive: "first", PatternType: logic.Literal, Severity: logic.SeverityHigh})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.AddWord(Word{Sensitive: "sec.nd", PatternType: logic.Regex, Categories: []string{"Test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	words, err := store.ListWords()
+	if err != nil || !slices.Equal(sensitiveValues(words), []string{"FIRST", "sec.nd"}) ||
+		words[0].Severity != logic.SeverityHigh || words[1].Severity != logic.SeverityLow {
+		t.Fatalf("Expected the added words to be listed %v %v", words, err)
+	}
+
+	errs, err := store.UpdateEntries([]EntryUpdate{{ID: first, Value: "one"}, {ID: 999, Value: "two"}})
+	if err == nil || !errors.Is(errs[1], ErrNotFound) || !errors.Is(errs[0], errNotApplied) {
+		t.Fatalf("Expected the batch to be refused %v %v", errs, err)
+	}
+	if _, err = store.UpdateEntries([]EntryUpdate{{ID: first, Value: "one"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.SetEnabled(second, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.AddCategories(first, []string{"other", "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.RemoveCategories(second, []string{"test"}); err != nil {
+		t.Fatal(err)
+	}
+	categories, err := store.ListCategories()
+	if err != nil || !slices.Equal(categories, []string{"other", "test"}) {
+		t.Fatalf("Expected the categories in use %v %v", categories, err)
+	}
+
+	phrase, err := store.AddAllowedPhrase("one way")
+	if err != nil {
+		t.Fatal(err)
+	}
+	phrases, err := store.ListAllowedPhrases()
+	if err != nil || phrases[phrase] != "ONE WAY" {
+		t.Fatalf("Expected the allowed phrase to be listed %v %v", phrases, err)
+	}
+
+	if _, err = store.AddPolicy(Policy{Name: "Strict", Words: []Word{{Sensitive: "strict", PatternType: logic.Literal}},
+		Excluded: []string{"one"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.AddPolicy(Policy{Name: "stricter", Base: "strict"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.UpdatePolicy(Policy{Name: "stricter", Base: "strict", Phrases: []string{"strict mode"}}); err != nil {
+		t.Fatal(err)
+	}
+	p, err := store.GetPolicy("STRICTER")
+	if err != nil || p.Base != "strict" || !slices.Equal(p.Phrases, []string{"STRICT MODE"}) {
+		t.Fatalf("Expected the updated policy %v %v", p, err)
+	}
+	policies, err := store.ListPolicies()
+	if err != nil || len(policies) != 2 || policies[0].Name != "strict" || len(policies[0].Words) != 1 {
+		t.Fatalf("Expected the policies in alphabetical order %v %v", policies, err)
+	}
+
+	//Every change records its own version, refused changes record none
+	list, err := store.RecordVersion("nothing")
+	if err != nil || list.Number != 10 || list.Reason != "update policy" ||
+		!slices.Equal(sensitiveValues(list.EffectiveWords()), []string{"ONE", "sec.nd"}) {
+		t.Fatalf("Expected a version of every change %v %v", list, err)
+	}
+	effective, err := list.EffectivePolicy("stricter")
+	if err != nil || !slices.Equal(sensitiveValues(effective.Words), []string{"STRICT"}) {
+		t.Fatalf("Expected the policy to be resolved from the version %v %v", effective, err)
+	}
+
+	if err = store.RemoveEntry(second); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.RemoveAllowedPhrase(phrase); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.WithReason("cleanup").RemovePolicy("stricter"); err != nil {
+		t.Fatal(err)
+	}
+	if list, err = store.RecordVersion("nothing"); err != nil || list.Number != 13 || list.Reason != "cleanup" {
+		t.Fatalf("Expected the version of the last change %v %v", list, err)
+	}
+
+	diff, err := store.DiffVersions(10, 13)
+	if err != nil || len(diff.RemovedWords) != 1 || len(diff.RemovedPhrases) != 1 ||
+		!slices.Equal(diff.RemovedPolicies, []string{"stricter"}) {
+		t.Fatalf("Expected the removals in the diff %+v %v", diff, err)
+	}
+
+	tenant := store.ForTenant("acme")
+	if tenant.Tenant() != "acme" {
+		t.Fatalf("Expected the store to be scoped to the tenant %q", tenant.Tenant())
+	}
+	if _, err = tenant.AddWord(Word{Sensitive: "private", PatternType: logic.Literal}); err != nil {
+		t.Fatal(err)
+	}
+	if list, err = tenant.RecordVersion("tenant"); err != nil || list.Number != 1 || list.SharedNumber != 13 ||
+		!slices.Equal(sensitiveValues(list.EffectiveWords()), []string{"ONE", "PRIVATE"}) {
+		t.Fatalf("Expected the tenant version to include the shared words %v %v", list, err)
+	}
+	if words, err = store.ListWords(); err != nil || len(words) != 1 {
+		t.Fatalf("Expected the tenant's words not to be shared %v %v", words, err)
+	}
+
+	if err = store.RollbackVersion(10); err != nil {
+		t.Fatal(err)
+	}
+	if list, err = store.RecordVersion("nothing"); err != nil || list.Number != 14 ||
+		!slices.Equal(sensitiveValues(list.EffectiveWords()), []string{"ONE", "sec.nd"}) || len(list.Policies) != 2 {
+		t.Fatalf("Expected version 10 to be restored %v %v", list, err)
+	}
+	if words, err = store.ListWords(); err != nil || len(words) != 2 || words[0].ID != first || words[1].ID != second {
+		t.Fatalf("Expected the restored words to keep their ids %v %v", words, err)
+	}
+	restored, err := store.ListPolicies()
+	if err != nil || len(restored) != 2 || restored[0].ID != policies[0].ID || restored[1].ID != policies[1].ID ||
+		restored[0].Words[0].ID != policies[0].Words[0].ID {
+		t.Fatalf("Expected the restored policies to keep their ids %v %v", restored, err)
+	}
+
+	versions, err := store.ListVersions()
+	if err != nil || len(versions) != 14 || versions[0].Reason != "rollback to version 10" {
+		t.Fatalf("Expected the versions newest first %v %v", versions, err)
+	}
+}
+
+func TestWordStores(t *testing.T) {
+	for _, backend := range storeBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			store, reopen := backend.open(t)
+			testWordStore(t, store)
+			if reopen == nil {
+				return
+			}
+
+			//The content is read back from the storage
+			reopened, err := reopen()
+			if err != nil {
+				t.Fatal(err)
+			}
+			versions, err := reopened.ListVersions()
+			if err != nil || len(versions) != 14 {
+				t.Fatalf("Expected the versions to be kept %v %v", versions, err)
+			}
+			words, err := reopened.ForTenant("acme").ListWords()
+			if err != nil || !slices.Equal(sensitiveValues(words), []string{"PRIVATE"}) {
+				t.Fatalf("Expected the tenant's words to be kept %v %v", words, err)
+			}
+			id, err := reopened.AddWord(Word{Sensitive: "third", PatternType: logic.Literal})
+			if err != nil || id <= words[0].ID {
+				t.Fatalf("Expected new ids after the ids that are kept %v %v", id, err)
+			}
+		})
+	}
+}
+
+func TestRefusedChanges(t *testing.T) {
+	for _, backend := range storeBackends {
+		for _, refused := range refusedChanges {
+			t.Run(backend.name+"/"+refused.name, func(t *testing.T) {
+				store, _ := backend.open(t)
+				ids := storeFixture(t, store)
+
+				err := refused.change(store, ids)
+				if err == nil || refused.want != errRefused && !errors.Is(err, refused.want) {
+					t.Fatalf("Expected the change to be refused with %v, got %v", refused.want, err)
+				}
+
+				//A refused change leaves the content and the versions as they were
+				list, err := store.RecordVersion("refused")
+				if err != nil || list.Number != 6 || list.Reason != "add policy" {
+					t.Fatalf("Expected no version of the refused change %v %v", list.Version, err)
+				}
+			})
+		}
+	}
+}
+
+func TestBatch(t *testing.T) {
+	for _, backend := range storeBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			store, _ := backend.open(t)
+			ids := storeFixture(t, store)
+
+			//The changes of a batch record a single version, a failed change is discarded on its own
+			err := store.Batch(func(batch WordStore) error {
+				if _, err := batch.AddWord(Word{Sensitive: "third", PatternType: logic.Literal}); err != nil {
+					return err
+				}
+				_, err := batch.AddWord(Word{Sensitive: "first", PatternType: logic.Literal})
+				if !errors.Is(err, ErrDuplicate) {
+					t.Errorf("Expected the duplicate to be refused within the batch %v", err)
+				}
+				words, err := batch.ListWords()
+				if err != nil || len(words) != 3 {
+					t.Errorf("Expected the batch to read its own changes %v %v", words, err)
+				}
+				return batch.RemoveEntry(ids.second)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			words, err := store.ListWords()
+			if err != nil || !slices.Equal(sensitiveValues(words), []string{"FIRST", "THIRD"}) {
+				t.Fatalf("Expected the changes of the batch %v %v", words, err)
+			}
+			versions, err := store.ListVersions()
+			if err != nil || len(versions) != 7 || versions[0].Reason != "add word, remove word" {
+				t.Fatalf("Expected a single version of the batch %v %v", versions, err)
+			}
+
+			//An error returned by the batch discards every change, and a batch without changes records no version
+			err = store.WithReason("cleanup").Batch(func(batch WordStore) error {
+				if err := batch.RemoveEntry(ids.first); err != nil {
+					return err
+				}
+				return errRefused
+			})
+			if !errors.Is(err, errRefused) {
+				t.Fatalf("Expected the error of the batch %v", err)
+			}
+			err = store.Batch(func(batch WordStore) error { return batch.RemoveEntry(999) })
+			if !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Expected the error of the batch %v", err)
+			}
+			if err = store.Batch(func(WordStore) error { return nil }); err != nil {
+				t.Fatal(err)
+			}
+
+			words, err = store.ListWords()
+			if err != nil || len(words) != 2 {
+				t.Fatalf("Expected the failed batch to be discarded %v %v", words, err)
+			}
+			versions, err = store.ListVersions()
+			if err != nil || len(versions) != 7 {
+				t.Fatalf("Expected no version of the failed batches %v %v", versions, err)
+			}
+		})
+	}
+}
+
+func TestUpdateAllowedPhrase(t *testing.T) {
+	for _, backend := range storeBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			store, _ := backend.open(t)
+			ids := storeFixture(t, store)
+
+			//The phrase is changed in place, a change of its case changes nothing
+			if err := store.UpdateAllowedPhrase(ids.phrase, "the only way"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.UpdateAllowedPhrase(ids.phrase, "The Only Way"); err != nil {
+				t.Fatal(err)
+			}
+
+			phrases, err := store.ListAllowedPhrases()
+			if err != nil || len(phrases) != 2 || phrases[ids.phrase] != "THE ONLY WAY" {
+				t.Fatalf("Expected the phrase to keep its id %v %v", phrases, err)
+			}
+			versions, err := store.ListVersions()
+			if err != nil || len(versions) != 7 || versions[0].Reason != "update phrase" {
+				t.Fatalf("Expected a single version of the update %v %v", versions, err)
+			}
+		})
+	}
+}
+
+func TestCaseSensitiveWords(t *testing.T) {
+	for _, backend := range storeBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			store, _ := backend.open(t)
+
+			//Case sensitive words and regular expressions that only differ in case are different words on every
+			//database
+			words := []Word{
+				{Sensitive: "Drop", PatternType: logic.Literal, CaseSensitive: true},
+				{Sensitive: "DROP", PatternType: logic.Literal, CaseSensitive: true},
+				{Sensitive: "[a-z]+", PatternType: logic.Regex},
+				{Sensitive: "[A-Z]+", PatternType: logic.Regex},
+			}
+			ids := make([]uint, len(words))
+			for i, word := range words {
+				id, err := store.AddWord(word)
+				if err != nil {
+					t.Fatalf("Expected %s to be added %v", word.Sensitive, err)
+				}
+				ids[i] = id
+			}
+
+			errs, err := store.UpdateEntries([]EntryUpdate{{ID: ids[1], Value: "dROP"}})
+			if err != nil {
+				t.Fatalf("Expected the case of the word to change %v %v", errs, err)
+			}
+			errs, _ = store.UpdateEntries([]EntryUpdate{{ID: ids[0], Value: "dROP"}})
+			if !errors.Is(errs[0], ErrDuplicate) {
+				t.Fatalf("Expected the same case to be a duplicate %v", errs)
+			}
+
+			listed, err := store.ListWords()
+			if err != nil || len(listed) != 4 {
+				t.Fatalf("Expected every word to be listed %v %v", listed, err)
+			}
+			for i, value := range []string{"Drop", "dROP", "[a-z]+", "[A-Z]+"} {
+				if listed[i].ID != ids[i] || listed[i].Sensitive != value {
+					t.Fatalf("Expected %s to keep its case %v", value, listed[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	if _, err := OpenFileStore(filepath.Join(t.TempDir(), "words.txt")); err == nil {
+		t.Fatal("Expected an unsupported extension to be refused")
+	}
+
+	//A file edited by hand needs no ids, and severities are stored by name
+	path := filepath.Join(t.TempDir(), "words.yaml")
+	err := os.WriteFile(path, []byte(`tenants:
+  "":
+    words:
+      - sensitive: select
+        severity: critical
+        categories: [sql]
+    phrases:
+      - phrase: select a plan
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	words, err := store.ListWords()
+	if err != nil || len(words) != 1 || words[0].ID == 0 || words[0].Sensitive != "SELECT" ||
+		words[0].Severity != logic.SeverityCritical || words[0].PatternType != logic.Literal {
+		t.Fatalf("Expected the word of the file %v %v", words, err)
+	}
+
+	if _, err = store.AddWord(Word{Sensitive: "drop", PatternType: logic.Literal, Severity: logic.SeverityHigh}); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(raw), "severity: high") {
+		t.Fatalf("Expected the severity to be stored by name %s %v", raw, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes, err := store.Watch(ctx, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//Another process replaces the file
+	other, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = other.AddWord(Word{Sensitive: "delete", PatternType: logic.Literal}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the change to the file to be reported")
+	}
+	words, err = store.ListWords()
+	if err != nil || !slices.Equal(sensitiveValues(words), []string{"SELECT", "DROP", "DELETE"}) {
+		t.Fatalf("Expected the changed file to be read %v %v", words, err)
+	}
+
+	cancel()
+	for range changes {
+	}
+
+	if err = os.WriteFile(path, []byte("tenants: ["), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenFileStore(path); err == nil {
+		t.Fatal("Expected an invalid file to be refused")
+	}
+}
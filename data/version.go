@@ -0,0 +1,541 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// this is a private object definition used in the database by Gorm to build the version table. Every version holds
+// the complete word list, allowlist and policies of a tenant as a JSON snapshot, so that any version can be restored
+// without replaying the changes in between
+type listVersion struct {
+	ID     uint   `gorm:"primaryKey; autoIncrement:true;" json:"-" yaml:"-"`
+	Tenant string `gorm:"index:idx_tenant_version,unique,priority:1" json:"-" yaml:"-"`
+	Number uint   `gorm:"index:idx_tenant_version,unique,priority:2" json:"number" yaml:"number"`
+	// SharedNumber is the version of the shared tenant the version was recorded with
+	SharedNumber uint      `json:"sharedNumber,omitempty" yaml:"sharedNumber,omitempty"`
+	Reason       string    `json:"reason" yaml:"reason"`
+	Content      string    `json:"content" yaml:"content"`
+	CreatedAt    time.Time `json:"createdAt" yaml:"createdAt"`
+}
+
+// Snapshot is the content of the word list of a tenant at a version, its global list, its global allowlist and its
+// policies with their own words, exclusions and phrases
+type Snapshot struct {
+	Words    []Word   `json:"words"`
+	Phrases  []string `json:"phrases"`
+	Policies []Policy `json:"policies"`
+}
+
+// empty reports whether the snapshot holds no words, phrases or policies
+func (s Snapshot) empty() bool {
+	return len(s.Words) == 0 && len(s.Phrases) == 0 && len(s.Policies) == 0
+}
+
+// Version describes a recorded version of the word list of a tenant. The versions of a tenant are numbered from one,
+// and the version number zero refers to a tenant that has never stored anything.
+type Version struct {
+	Number uint
+	// SharedNumber is the version of the shared tenant whose words are part of this version, it is zero for the shared
+	// tenant itself
+	SharedNumber uint
+	// Reason describes the change that produced the version
+	Reason    string
+	CreatedAt time.Time
+}
+
+// VersionedList is the word list of a tenant at a version, together with the word list of the shared tenant at the
+// version it was recorded with
+type VersionedList struct {
+	Version
+	Snapshot
+	Shared Snapshot
+}
+
+// EffectiveWords returns the words of the shared global list followed by the words of the global list of the tenant
+func (l VersionedList) EffectiveWords() []Word {
+	return append(append([]Word{}, l.Shared.Words...), l.Words...)
+}
+
+// EffectivePhrases returns the phrases of the shared global allowlist followed by the phrases of the tenant
+func (l VersionedList) EffectivePhrases() []string {
+	return append(append([]string{}, l.Shared.Phrases...), l.Phrases...)
+}
+
+// EffectivePolicy resolves the policy with the provided name against its base policies, in the same way as
+// SanitizeDB.EffectivePolicy but from the content of the version
+func (l VersionedList) EffectivePolicy(name string) (Policy, error) {
+	defaults := func() (Policy, error) {
+		return Policy{Name: DefaultPolicy, Words: l.EffectiveWords(), Phrases: l.EffectivePhrases()}, nil
+	}
+	find := func(name string) (Policy, error) {
+		if p, found := findPolicy(l.Policies, name); found {
+			return p, nil
+		}
+		return Policy{}, ErrNotFound
+	}
+	return resolvePolicy(strings.ToLower(strings.TrimSpace(name)), 0, defaults, find)
+}
+
+// VersionDiff lists the differences between two versions of the word list of a tenant. Words are compared by their
+// stored value, a word whose other options differ is changed and listed as it is in the To version. Policies are
+// compared by their name.
+type VersionDiff struct {
+	From            Version
+	To              Version
+	AddedWords      []Word
+	RemovedWords    []Word
+	ChangedWords    []Word
+	AddedPhrases    []string
+	RemovedPhrases  []string
+	AddedPolicies   []string
+	RemovedPolicies []string
+	ChangedPolicies []string
+}
+
+// errVersionConflict is returned when another instance recorded the same version number of the tenant at the same time
+var errVersionConflict = errors.New("version number already recorded")
+
+// versionAttempts is the number of times a change is applied before a conflicting version number is reported
+const versionAttempts = 5
+
+// RecordVersion records the current word list, allowlist and policies of the tenant as a new version, and returns the
+// latest version together with its content. Every change through the SanitizeDB already records its own version, this
+// records the changes that were made directly on the database. No version is recorded when nothing changed since the
+// latest version, or when the tenant has never stored anything. The version of the shared tenant is recorded first, a
+// change to the shared tenant also results in a new version of the tenant.
+func (sanitize *SanitizeDB) RecordVersion(reason string) (VersionedList, error) {
+	var result VersionedList
+	if sanitize.tenant != "" {
+		shared, err := sanitize.forTenant("").RecordVersion(reason)
+		if err != nil {
+			return VersionedList{}, err
+		}
+		result.SharedNumber, result.Shared = shared.Number, shared.Snapshot
+	}
+
+	err := sanitize.transaction(func(tx *SanitizeDB) error {
+		var err error
+		result.Version, result.Snapshot, err = tx.recordVersion(reason, result.SharedNumber)
+		return err
+	})
+	if err != nil {
+		return VersionedList{}, err
+	}
+	return result, nil
+}
+
+// change applies the change to the records of the tenant, and records the changed content as a new version with the
+// reason of the SanitizeDB, or the action when it has none, in the same transaction. Every change therefore records its
+// own version, and a change is never applied without it. A change that returns errUnchanged is rolled back without an
+// error.
+func (sanitize *SanitizeDB) change(action string, change func(tx *SanitizeDB) error) error {
+	if sanitize.batch != nil {
+		//The change of a batch is rolled back to its savepoint when it fails, the batch records the version
+		err := sanitize.db.Transaction(func(tx *gorm.DB) error {
+			return change(&SanitizeDB{db: tx, tenant: sanitize.tenant, reason: sanitize.reason})
+		})
+		if err == nil {
+			sanitize.batch.add(action)
+		}
+		if errors.Is(err, errUnchanged) {
+			return nil
+		}
+		return err
+	}
+
+	err := sanitize.transaction(func(tx *SanitizeDB) error {
+		if err := change(tx); err != nil {
+			return err
+		}
+		return tx.recordChange(action)
+	})
+	if errors.Is(err, errUnchanged) {
+		return nil
+	}
+	return err
+}
+
+// Batch applies the changes that fn makes through the provided SanitizeDB in a single transaction, which records a
+// single version named after the actions of the changes, unless the SanitizeDB has a reason. Every change of the batch
+// runs in a savepoint of the transaction, so a failed change is rolled back on its own. No version is recorded when no
+// change was applied.
+func (sanitize *SanitizeDB) Batch(fn func(store WordStore) error) error {
+	err := sanitize.transaction(func(tx *SanitizeDB) error {
+		var actions batchActions
+		tx.batch = &actions
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if len(actions) == 0 {
+			return errUnchanged
+		}
+
+		tx.batch = nil
+		return tx.recordChange(actions.reason())
+	})
+	if errors.Is(err, errUnchanged) {
+		return nil
+	}
+	return err
+}
+
+// recordChange records the changed content of the tenant as a new version with the reason of the SanitizeDB, or the
+// action when it has none. The SanitizeDB is on a transaction.
+func (sanitize *SanitizeDB) recordChange(action string) error {
+	var sharedNumber uint
+	if sanitize.tenant != "" {
+		shared, err := sanitize.forTenant("").latestVersion()
+		if err != nil {
+			return err
+		}
+		sharedNumber = shared.Number
+	}
+
+	_, _, err := sanitize.recordVersion(cmp.Or(sanitize.reason, action), sharedNumber)
+	return err
+}
+
+// transaction calls fn with a SanitizeDB of the tenant on a new transaction. Instances that change the same tenant at
+// the same time both try to record the next version number, the unique index of the versions refuses the second one
+// which is then applied again on top of the first, up to versionAttempts times.
+func (sanitize *SanitizeDB) transaction(fn func(tx *SanitizeDB) error) error {
+	var err error
+	for range versionAttempts {
+		err = sanitize.db.Transaction(func(tx *gorm.DB) error {
+			return fn(&SanitizeDB{db: tx, tenant: sanitize.tenant, reason: sanitize.reason})
+		})
+		if !errors.Is(err, errVersionConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+// recordVersion records the current content of the tenant as a new version when it changed since the latest version,
+// and returns the latest version together with the content. The SanitizeDB is on a transaction.
+func (sanitize *SanitizeDB) recordVersion(reason string, sharedNumber uint) (Version, Snapshot, error) {
+	snapshot, err := sanitize.currentSnapshot()
+	if err != nil {
+		return Version{}, Snapshot{}, err
+	}
+
+	latest, err := sanitize.latestVersion()
+	if err != nil {
+		return Version{}, Snapshot{}, err
+	}
+
+	version, record, err := nextVersion(latest, snapshot, sharedNumber, reason)
+	if err != nil {
+		return Version{}, Snapshot{}, err
+	}
+	if record {
+		err := sanitize.db.Create(&version).Error
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return Version{}, Snapshot{}, fmt.Errorf("version %d: %w", version.Number, errVersionConflict)
+		}
+		if err != nil {
+			return Version{}, Snapshot{}, fmt.Errorf("version not recorded: %w", err)
+		}
+	}
+	return toVersion(version), snapshot, nil
+}
+
+// latestVersion returns the latest version of the tenant, or a version numbered zero when none was recorded
+func (sanitize *SanitizeDB) latestVersion() (listVersion, error) {
+	latest := listVersion{Tenant: sanitize.tenant}
+	err := sanitize.scoped().Order("number DESC").Limit(1).Find(&latest).Error
+	return latest, err
+}
+
+// ListVersions returns all the recorded versions of the tenant, the newest version first. In the case where it cannot
+// connect or an error occurred the method will return an error
+func (sanitize *SanitizeDB) ListVersions() ([]Version, error) {
+	var rows []listVersion
+
+	err := sanitize.scoped().Omit("content").Order("number DESC").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]Version, 0, len(rows))
+	for _, row := range rows {
+		versions = append(versions, toVersion(row))
+	}
+	return versions, nil
+}
+
+// LoadVersion returns the recorded version of the tenant with its content, and the content of the version of the
+// shared tenant it was recorded with. Should the version not exist ErrNotFound is returned
+func (sanitize *SanitizeDB) LoadVersion(number uint) (VersionedList, error) {
+	version, snapshot, err := sanitize.findVersion(number)
+	if err != nil {
+		return VersionedList{}, err
+	}
+
+	result := VersionedList{Version: version, Snapshot: snapshot}
+	if sanitize.tenant != "" && version.SharedNumber != 0 {
+		_, result.Shared, err = sanitize.forTenant("").findVersion(version.SharedNumber)
+		if err != nil {
+			return VersionedList{}, fmt.Errorf("shared version %d: %w", version.SharedNumber, err)
+		}
+	}
+	return result, nil
+}
+
+// DiffVersions returns the changes that lead from the first recorded version of the tenant to the second, which may
+// also be the older one. Should either version not exist ErrNotFound is returned
+func (sanitize *SanitizeDB) DiffVersions(from uint, to uint) (VersionDiff, error) {
+	older, fromSnapshot, err := sanitize.findVersion(from)
+	if err != nil {
+		return VersionDiff{}, err
+	}
+	newer, toSnapshot, err := sanitize.findVersion(to)
+	if err != nil {
+		return VersionDiff{}, err
+	}
+
+	return diffSnapshots(older, fromSnapshot, newer, toSnapshot), nil
+}
+
+// diffSnapshots returns the changes that lead from the content of the first version to the content of the second
+func diffSnapshots(from Version, fromSnapshot Snapshot, to Version, toSnapshot Snapshot) VersionDiff {
+	result := VersionDiff{From: from, To: to}
+	for _, word := range toSnapshot.Words {
+		previous, found := findWord(fromSnapshot.Words, word.Sensitive)
+		if !found {
+			result.AddedWords = append(result.AddedWords, word)
+		} else if !reflect.DeepEqual(comparableWord(previous), comparableWord(word)) {
+			result.ChangedWords = append(result.ChangedWords, word)
+		}
+	}
+	for _, word := range fromSnapshot.Words {
+		if _, found := findWord(toSnapshot.Words, word.Sensitive); !found {
+			result.RemovedWords = append(result.RemovedWords, word)
+		}
+	}
+
+	result.AddedPhrases = missingValues(toSnapshot.Phrases, fromSnapshot.Phrases)
+	result.RemovedPhrases = missingValues(fromSnapshot.Phrases, toSnapshot.Phrases)
+
+	for _, p := range toSnapshot.Policies {
+		previous, found := findPolicy(fromSnapshot.Policies, p.Name)
+		if !found {
+			result.AddedPolicies = append(result.AddedPolicies, p.Name)
+		} else if !reflect.DeepEqual(comparablePolicy(previous), comparablePolicy(p)) {
+			result.ChangedPolicies = append(result.ChangedPolicies, p.Name)
+		}
+	}
+	for _, p := range fromSnapshot.Policies {
+		if _, found := findPolicy(toSnapshot.Policies, p.Name); !found {
+			result.RemovedPolicies = append(result.RemovedPolicies, p.Name)
+		}
+	}
+
+	return result
+}
+
+// RollbackVersion replaces the word list, allowlist and policies of the tenant with the content of the recorded
+// version. The words and policies are restored with the IDs and timestamps they had in the version, unless another
+// tenant took an ID in the meantime, and the phrases receive new IDs. The rollback itself is recorded as a new version
+// in the same transaction. Should the version not exist ErrNotFound is returned
+func (sanitize *SanitizeDB) RollbackVersion(number uint) error {
+	_, snapshot, err := sanitize.findVersion(number)
+	if err != nil {
+		return err
+	}
+
+	return sanitize.change(fmt.Sprintf("rollback to version %d", number), func(scoped *SanitizeDB) error {
+		tx := scoped.db
+		for _, model := range []any{&wordCategory{}, &sensitiveWord{}, &allowedPhrase{}, &policyExclusion{}, &policy{}} {
+			if err := tx.Where("tenant = ?", sanitize.tenant).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, word := range snapshot.Words {
+			word.PolicyID = 0
+			if err := restoreWord(tx, sanitize.tenant, word); err != nil {
+				return fmt.Errorf("word %q: %w", word.Sensitive, err)
+			}
+		}
+
+		for _, phrase := range snapshot.Phrases {
+			if err := tx.Create(&allowedPhrase{Tenant: sanitize.tenant, Phrase: phrase}).Error; err != nil {
+				return fmt.Errorf("phrase %q: %w", phrase, err)
+			}
+		}
+
+		for _, p := range snapshot.Policies {
+			row := fromPolicy(p)
+			row.ID, row.Tenant = p.ID, sanitize.tenant
+			if err := restoreRow(tx, &row, &row.ID); err != nil {
+				return fmt.Errorf("policy %q: %w", p.Name, err)
+			}
+			for _, word := range p.Words {
+				word.PolicyID = row.ID
+				if err := restoreWord(tx, sanitize.tenant, word); err != nil {
+					return fmt.Errorf("policy %q, word %q: %w", p.Name, word.Sensitive, err)
+				}
+			}
+			content := Policy{Excluded: p.Excluded, Phrases: p.Phrases}
+			if err := insertPolicyContent(tx, sanitize.tenant, row.ID, content); err != nil {
+				return fmt.Errorf("policy %q: %w", p.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// restoreWord adds the word of a recorded version of the tenant with its ID and timestamps, see restoreRow
+func restoreWord(tx *gorm.DB, tenant string, word Word) error {
+	row := toSensitiveWord(tenant, word)
+	row.ID, row.CreatedAt, row.UpdatedAt = word.ID, word.CreatedAt, word.UpdatedAt
+	if err := restoreRow(tx, &row, &row.ID); err != nil {
+		return err
+	}
+
+	for _, category := range word.Categories {
+		if err := tx.Create(&wordCategory{Tenant: tenant, WordID: row.ID, Category: category}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreRow adds the record of a recorded version with the ID it had. The ID was only ever assigned to the tenant,
+// but once the tenant removed the record the database may have assigned it to another tenant, the record then
+// receives a new ID.
+func restoreRow(tx *gorm.DB, row any, id *uint) error {
+	taken, err := exists(tx.Where("id = ?", *id), row)
+	if err != nil {
+		return err
+	}
+	if taken {
+		*id = 0
+	}
+	return tx.Create(row).Error
+}
+
+// Watch reports the versions recorded by other instances of the service on the same database, by checking for a new
+// version every interval. Changes made directly on the database are not reported, as they do not record a version.
+func (sanitize *SanitizeDB) Watch(ctx context.Context, interval time.Duration) (<-chan struct{}, error) {
+	latest, err := sanitize.latestVersionID()
+	if err != nil {
+		return nil, err
+	}
+
+	return poll(ctx, interval, func() (bool, error) {
+		current, err := sanitize.latestVersionID()
+		if err != nil || current == latest {
+			return false, err
+		}
+		latest = current
+		return true, nil
+	}), nil
+}
+
+// latestVersionID returns the id of the version that was recorded last, for any tenant
+func (sanitize *SanitizeDB) latestVersionID() (uint, error) {
+	var id uint
+	err := sanitize.db.Model(&listVersion{}).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	return id, err
+}
+
+// currentSnapshot reads the current word list, allowlist and policies of the tenant
+func (sanitize *SanitizeDB) currentSnapshot() (Snapshot, error) {
+	words, err := sanitize.ListWords()
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	phrases, err := sanitize.ListAllowedPhrases()
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	policies, err := sanitize.ListPolicies()
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	return Snapshot{Words: words, Phrases: sortedValues(phrases), Policies: policies}, nil
+}
+
+// findVersion returns the recorded version of the tenant with its content
+func (sanitize *SanitizeDB) findVersion(number uint) (Version, Snapshot, error) {
+	var row listVersion
+
+	err := sanitize.scoped().Where("number = ?", number).Limit(1).Find(&row).Error
+	if err != nil {
+		return Version{}, Snapshot{}, err
+	}
+	if row.ID == 0 {
+		return Version{}, Snapshot{}, ErrNotFound
+	}
+
+	snapshot, err := readSnapshot(row)
+	if err != nil {
+		return Version{}, Snapshot{}, fmt.Errorf("version %d can not be read: %w", number, err)
+	}
+	return toVersion(row), snapshot, nil
+}
+
+// toVersion converts the database record to a Version
+func toVersion(row listVersion) Version {
+	return Version{Number: row.Number, SharedNumber: row.SharedNumber, Reason: row.Reason, CreatedAt: row.CreatedAt}
+}
+
+// comparableWord removes the fields of the word that change whenever it is stored again
+func comparableWord(word Word) Word {
+	word.ID, word.PolicyID, word.CreatedAt, word.UpdatedAt = 0, 0, time.Time{}, time.Time{}
+	return word
+}
+
+// comparablePolicy removes the fields of the policy and its words that change whenever it is stored again
+func comparablePolicy(p Policy) Policy {
+	words := make([]Word, 0, len(p.Words))
+	for _, word := range p.Words {
+		words = append(words, comparableWord(word))
+	}
+	p.ID, p.Words = 0, words
+	return p
+}
+
+// findWord returns the word with the stored value
+func findWord(words []Word, value string) (Word, bool) {
+	for _, word := range words {
+		if word.Sensitive == value {
+			return word, true
+		}
+	}
+	return Word{}, false
+}
+
+// findPolicy returns the policy with the name
+func findPolicy(policies []Policy, name string) (Policy, bool) {
+	for _, p := range policies {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Policy{}, false
+}
+
+// missingValues returns the values that are not part of the other values
+func missingValues(values []string, other []string) []string {
+	var missing []string
+	for _, value := range values {
+		if !containsFold(other, value) {
+			missing = append(missing, value)
+		}
+	}
+	return missing
+}
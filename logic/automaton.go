@@ -0,0 +1,140 @@
+package logic
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// automaton is an Aho-Corasick automaton that is built once from the word list. It finds every occurrence of every
+// word in a single pass over the text, instead of compiling and running a regular expression per word.
+// The automaton works on runes and folds the case of every rune on its own, so the offsets it reports always refer
+// to the text that was scanned, no matter how the case mapping changes the encoded length of a character.
+type automaton struct {
+	nodes []acNode
+	// lengths holds the length in runes of every pattern
+	lengths []int
+}
+
+type acNode struct {
+	next map[rune]int32
+	// fail points to the node representing the longest proper suffix of this node that is also a prefix of a pattern
+	fail int32
+	// output is the index of the pattern ending at this node, or -1 when no pattern ends here
+	output int32
+	// dict points to the nearest node on the fail chain that has an output, or -1 when there is none
+	dict int32
+}
+
+// newAutomaton builds the automaton for the provided patterns. Empty patterns are ignored and duplicate patterns are
+// reported once, using the index of the first occurrence.
+func newAutomaton(patterns []string) *automaton {
+	a := &automaton{
+		nodes:   []acNode{{next: map[rune]int32{}, output: -1, dict: -1}},
+		lengths: make([]int, len(patterns)),
+	}
+
+	//Building the trie of all the patterns
+	for index, pattern := range patterns {
+		if len(pattern) == 0 {
+			continue
+		}
+
+		current := int32(0)
+		for _, r := range pattern {
+			r = foldRune(r)
+			next, ok := a.nodes[current].next[r]
+			if !ok {
+				next = int32(len(a.nodes))
+				a.nodes = append(a.nodes, acNode{next: map[rune]int32{}, output: -1, dict: -1})
+				a.nodes[current].next[r] = next
+			}
+			current = next
+			a.lengths[index]++
+		}
+
+		if a.nodes[current].output < 0 {
+			a.nodes[current].output = int32(index)
+		}
+	}
+
+	//Linking the failure and dictionary suffix links breadth first, so that the links of shorter prefixes are
+	//always available when the longer ones are calculated
+	queue := make([]int32, 0, len(a.nodes))
+	for _, child := range a.nodes[0].next {
+		queue = append(queue, child)
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for r, child := range a.nodes[current].next {
+			fail := a.nodes[current].fail
+			for {
+				if next, ok := a.nodes[fail].next[r]; ok {
+					a.nodes[child].fail = next
+					break
+				}
+				if fail == 0 {
+					a.nodes[child].fail = 0
+					break
+				}
+				fail = a.nodes[fail].fail
+			}
+
+			failNode := a.nodes[a.nodes[child].fail]
+			if failNode.output >= 0 {
+				a.nodes[child].dict = a.nodes[child].fail
+			} else {
+				a.nodes[child].dict = failNode.dict
+			}
+
+			queue = append(queue, child)
+		}
+	}
+
+	return a
+}
+
+// findAll scans the text once and calls found for every occurrence of every pattern, including overlapping ones.
+// The start and end values are byte offsets into the text. Occurrences ending at the same offset are reported
+// longest first.
+func (a *automaton) findAll(text string, found func(start int, end int, pattern int)) {
+	//offsets holds the byte offset of every rune scanned so far, to translate the rune length of a pattern back to
+	//the byte offset where the occurrence started
+	offsets := make([]int, 0, len(text))
+
+	current := int32(0)
+	for i := 0; i < len(text); {
+		r, width := utf8.DecodeRuneInString(text[i:])
+		offsets = append(offsets, i)
+		r = foldRune(r)
+		i += width
+
+		for {
+			if next, ok := a.nodes[current].next[r]; ok {
+				current = next
+				break
+			}
+			if current == 0 {
+				break
+			}
+			current = a.nodes[current].fail
+		}
+
+		for node := current; node >= 0; node = a.nodes[node].dict {
+			if output := a.nodes[node].output; output >= 0 {
+				found(offsets[len(offsets)-a.lengths[output]], i, int(output))
+			}
+			if node == 0 {
+				break
+			}
+		}
+	}
+}
+
+// foldRune maps a rune to the form used for the case insensitive comparison. It always maps a single rune to a single
+// rune, so the matches can be mapped back to the original text.
+func foldRune(r rune) rune {
+	return unicode.ToUpper(r)
+}
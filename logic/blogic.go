@@ -1,60 +1,68 @@
 package logic
 
 import (
-	"errors"
-	"regexp"
-	"runtime/debug"
-	"sort"
-	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // SanitizeText sanitizes the input text according to the provided word list and returns the result in the same order
-// it was provided. In the case of an error the method will return a empty string list, and the appropriate error
+// it was provided. In the case of an error the method will return a empty string list, and the appropriate error.
+// The word list is compiled on every call, callers sanitizing text repeatedly against the same list should build a
+// Matcher once with NewMatcher instead.
 func SanitizeText(textToSanitize []string, sanitizeWords []string) (result []string, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			debug.PrintStack()
-			err = errors.New("an error occurred, while sanitizing text")
-		}
-	}()
-
-	//The slice had to be sorted due to the longest words first
-	//In the case that it is not it will produce invalid results and cause uncensored text
-	sort.Slice(sanitizeWords, func(i, j int) bool {
-		return len(sanitizeWords[i]) > len(sanitizeWords[j])
-	})
-
-	for _, text := range textToSanitize {
-		//Building a response string, and censoring as we continue to loop through the words
-		//to sensor the requested string
-
-		var replace []int
-		for _, word := range sanitizeWords {
-			//escaping special regex strings
-			word = strings.ReplaceAll(word, "*", "\\*")
-
-			re := regexp.MustCompile("\\b" + strings.ToUpper(word) + "\\b")
-			matches := re.FindAllStringIndex(strings.ToUpper(text), -1)
-
-			for _, match := range matches {
-				for idx := match[0]; idx < match[1]; idx++ {
-					replace = append(replace, idx)
-				}
-			}
+	words := make(map[uint]string, len(sanitizeWords))
+	for index, word := range sanitizeWords {
+		words[uint(index)] = word
+	}
+
+	return NewMatcher(words).Sanitize(textToSanitize)
+}
+
+// SanitizePatterns sanitizes the input text the same way as SanitizeText, honouring the pattern type, replacement,
+// case sensitivity, boundary and disabled state of every pattern. An error is returned when any of the patterns is
+// invalid.
+func SanitizePatterns(textToSanitize []string, patterns []Pattern) (result []string, err error) {
+	matcher, err := CompilePatterns(patterns, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return matcher.Sanitize(textToSanitize)
+}
+
+// isWholeWord reports whether the match between the byte offsets start and end is not part of a larger word. A match
+// that starts (or ends) with a word character may not be preceded (or followed) by another word character, edges made
+// up of other characters, such as the * in "SELECT *", are not restricted.
+func isWholeWord(text string, start int, end int) bool {
+	if start > 0 {
+		first, _ := utf8.DecodeRuneInString(text[start:])
+		previous, _ := utf8.DecodeLastRuneInString(text[:start])
+		if isWordRune(first) && isWordRune(previous) {
+			return false
 		}
+	}
 
-		sanitized := []rune(text)
-		for _, index := range replace {
-			if index < len(sanitized) {
-				if sanitized[index] != ' ' {
-					sanitized[index] = '*'
-				}
-			}
+	if end < len(text) {
+		last, _ := utf8.DecodeLastRuneInString(text[:end])
+		next, _ := utf8.DecodeRuneInString(text[end:])
+		if isWordRune(last) && isWordRune(next) {
+			return false
 		}
+	}
 
-		result = append(result, string(sanitized))
+	return true
+}
 
+// isWordRune reports whether the rune is part of a word. Letters, digits, combining marks and underscores are word
+// characters in any script, except for scripts that are written without spaces between words, such as Chinese, Japanese
+// and Thai, where every character is treated as a word on its own.
+func isWordRune(r rune) bool {
+	switch {
+	case r == '_':
+		return true
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai):
+		return false
+	default:
+		return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r)
 	}
-
-	return result, nil
 }
@@ -0,0 +1,184 @@
+package logic
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Stage is a single step of the canonicalization pipeline, which rewrites the text before it is matched so that
+// visually equivalent text matches the same words
+type Stage string
+
+const (
+	// StageNFKC applies Unicode NFKC normalization, for example fullwidth ＤＲＯＰ becomes DROP
+	StageNFKC Stage = "nfkc"
+	// StageCaseFold applies full Unicode case folding, for example ß becomes ss
+	StageCaseFold Stage = "casefold"
+	// StageInvisible removes zero-width, bidirectional control and other invisible formatting characters
+	StageInvisible Stage = "invisible"
+	// StageHomoglyph replaces Cyrillic and Greek letters that look like Latin letters with the Latin letter
+	StageHomoglyph Stage = "homoglyph"
+	// StageWhitespace collapses every run of whitespace into a single space
+	StageWhitespace Stage = "whitespace"
+)
+
+// stageOrder is the order in which the stages are always applied, no matter the order they were requested in
+var stageOrder = []Stage{StageNFKC, StageCaseFold, StageInvisible, StageHomoglyph, StageWhitespace}
+
+// homoglyphs maps Cyrillic and Greek letters to the Latin letter they are indistinguishable from
+var homoglyphs = map[rune]rune{
+	'А': 'A', 'В': 'B', 'С': 'C', 'Е': 'E', 'Н': 'H', 'І': 'I', 'Ј': 'J', 'К': 'K', 'М': 'M', 'О': 'O', 'Р': 'P',
+	'Ѕ': 'S', 'Т': 'T', 'Х': 'X', 'У': 'Y', 'Ԁ': 'D', 'Ԛ': 'Q', 'Ԝ': 'W',
+	'а': 'a', 'с': 'c', 'е': 'e', 'һ': 'h', 'і': 'i', 'ј': 'j', 'о': 'o', 'р': 'p', 'ѕ': 's', 'х': 'x', 'у': 'y',
+	'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w',
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M', 'Ν': 'N', 'Ο': 'O', 'Ρ': 'P',
+	'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+	'ο': 'o', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x',
+}
+
+// ParseStage converts the name of a canonicalization stage to a Stage
+func ParseStage(name string) (Stage, error) {
+	stage := Stage(strings.ToLower(strings.TrimSpace(name)))
+	if !slices.Contains(stageOrder, stage) {
+		return "", fmt.Errorf("unsupported canonicalization stage %q", name)
+	}
+	return stage, nil
+}
+
+// mappedText is a rewritten version of an original text, which keeps track of where every byte came from so that
+// offsets in the rewritten text can be mapped back to the original
+type mappedText struct {
+	text string
+	// origins holds the original span of every byte of the text, it is nil when the text was not rewritten
+	origins []span
+	// decoded holds the decodings that produced every byte of the text, it is nil when nothing was decoded
+	decoded []decodingSet
+}
+
+// original maps the byte offsets of a range in the rewritten text to the range in the original text it came from
+func (t mappedText) original(start int, end int) (int, int) {
+	if t.origins == nil {
+		return start, end
+	}
+	return t.origins[start].start, t.origins[end-1].end
+}
+
+// decodedIn returns the decodings that produced any of the bytes in the range of the rewritten text
+func (t mappedText) decodedIn(start int, end int) decodingSet {
+	var result decodingSet
+	if t.decoded != nil {
+		for _, set := range t.decoded[start:end] {
+			result |= set
+		}
+	}
+	return result
+}
+
+// transform rewrites the text one segment at a time. The rewrite function receives the remaining text and returns
+// the length of the segment it consumed and the text that replaces it, every byte of the replacement maps back to
+// the original span of the whole segment.
+func (t mappedText) transform(rewrite func(remaining string) (int, string)) mappedText {
+	return t.rewrite(func(remaining string) (int, string, bool) {
+		length, replacement := rewrite(remaining)
+		return length, replacement, false
+	}, 0)
+}
+
+// rewrite rewrites the text the same way as transform, when the rewrite function reports that it decoded a segment
+// the decoding is recorded for every byte of the replacement
+func (t mappedText) rewrite(rewrite func(remaining string) (int, string, bool), decoding decodingSet) mappedText {
+	var result mappedText
+	var builder strings.Builder
+	builder.Grow(len(t.text))
+	result.origins = make([]span, 0, len(t.text))
+	if t.decoded != nil || decoding != 0 {
+		result.decoded = make([]decodingSet, 0, len(t.text))
+	}
+
+	for i := 0; i < len(t.text); {
+		length, replacement, decoded := rewrite(t.text[i:])
+		if length <= 0 {
+			length = len(t.text) - i
+		}
+
+		start, end := t.original(i, i+length)
+		set := t.decodedIn(i, i+length)
+		if decoded {
+			set |= decoding
+		}
+
+		builder.WriteString(replacement)
+		for b := 0; b < len(replacement); b++ {
+			result.origins = append(result.origins, span{start: start, end: end})
+			if result.decoded != nil {
+				result.decoded = append(result.decoded, set)
+			}
+		}
+		i += length
+	}
+
+	result.text = builder.String()
+	return result
+}
+
+// canonicalize applies the requested stages to the text
+func (t mappedText) canonicalize(stages []Stage) mappedText {
+	result := t
+	for _, stage := range stageOrder {
+		if !slices.Contains(stages, stage) {
+			continue
+		}
+
+		switch stage {
+		case StageNFKC:
+			result = result.transform(func(remaining string) (int, string) {
+				length := norm.NFKC.NextBoundaryInString(remaining, true)
+				if length <= 0 {
+					length = len(remaining)
+				}
+				return length, norm.NFKC.String(remaining[:length])
+			})
+		case StageCaseFold:
+			caser := cases.Fold()
+			result = result.transform(func(remaining string) (int, string) {
+				_, width := utf8.DecodeRuneInString(remaining)
+				return width, caser.String(remaining[:width])
+			})
+		case StageInvisible:
+			result = result.transform(func(remaining string) (int, string) {
+				r, width := utf8.DecodeRuneInString(remaining)
+				if unicode.Is(unicode.Cf, r) {
+					return width, ""
+				}
+				return width, remaining[:width]
+			})
+		case StageHomoglyph:
+			result = result.transform(func(remaining string) (int, string) {
+				r, width := utf8.DecodeRuneInString(remaining)
+				if latin, ok := homoglyphs[r]; ok {
+					return width, string(latin)
+				}
+				return width, remaining[:width]
+			})
+		case StageWhitespace:
+			result = result.transform(func(remaining string) (int, string) {
+				length := strings.IndexFunc(remaining, func(r rune) bool {
+					return !unicode.IsSpace(r)
+				})
+				if length == 0 {
+					_, width := utf8.DecodeRuneInString(remaining)
+					return width, remaining[:width]
+				}
+				return length, " "
+			})
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,79 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// maxCategoryLength is the maximum length in characters of a category name
+const maxCategoryLength = 64
+
+// CategoryFilter restricts the words that are matched by their categories. When Include is set, only words in at
+// least one of the included categories are matched, and words in any of the excluded categories are never matched.
+// A zero value matches every word.
+type CategoryFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// ParseCategory normalizes the name of a category, such as "SQL Keyword" to "sql-keyword". Category names consist of
+// letters, digits, dashes and underscores, spaces are replaced with dashes.
+func ParseCategory(name string) (string, error) {
+	category := strings.ToLower(strings.Join(strings.Fields(name), "-"))
+	if category == "" {
+		return "", errors.New("category is empty")
+	}
+	if len([]rune(category)) > maxCategoryLength {
+		return "", fmt.Errorf("category %q is longer than %d characters", name, maxCategoryLength)
+	}
+
+	for _, r := range category {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return "", fmt.Errorf("category %q contains an unsupported character %q", name, r)
+		}
+	}
+	return category, nil
+}
+
+// ParseCategories normalizes the names of categories, the result is sorted and without duplicates
+func ParseCategories(names []string) ([]string, error) {
+	categories := make([]string, 0, len(names))
+	for _, name := range names {
+		category, err := ParseCategory(name)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+
+	slices.Sort(categories)
+	return slices.Compact(categories), nil
+}
+
+// allows reports whether the pattern is matched according to the filter
+func (f CategoryFilter) allows(pattern Pattern) bool {
+	for _, category := range pattern.Categories {
+		if slices.Contains(f.Exclude, category) {
+			return false
+		}
+	}
+
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, category := range pattern.Categories {
+		if slices.Contains(f.Include, category) {
+			return true
+		}
+	}
+	return false
+}
+
+// categoryLabel converts a category to the label used by the category mask strategy, such as "sql-keyword" to
+// "SQL_KEYWORD"
+func categoryLabel(category string) string {
+	return strings.ToUpper(strings.ReplaceAll(category, "-", "_"))
+}
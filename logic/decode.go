@@ -0,0 +1,280 @@
+package logic
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Decoding is an encoding that is decoded before the text is matched, so that words hidden in an encoded payload
+// are still found. The encoded span of a match is masked in the original text.
+type Decoding string
+
+const (
+	// DecodeURL decodes URL percent encoding, for example %53%45%4C%45%43%54
+	DecodeURL Decoding = "url"
+	// DecodeHTML decodes HTML character references, for example &#x53;ELECT or &#83;ELECT
+	DecodeHTML Decoding = "html"
+	// DecodeHex decodes hexadecimal string literals, for example 0x53454C454354
+	DecodeHex Decoding = "hex"
+	// DecodeBase64 decodes standard base64 encoded tokens that decode to printable text, for example U0VMRUNU
+	DecodeBase64 Decoding = "base64"
+	// DecodeChar decodes SQL character functions and their concatenations, for example CHAR(83)+CHAR(69)
+	DecodeChar Decoding = "char"
+)
+
+// DefaultDecodeDepth is the number of times the decodings are applied when no depth is provided, so that payloads that
+// were encoded more than once are also decoded
+const DefaultDecodeDepth = 3
+
+// MaxDecodeDepth limits the number of times the decodings are applied
+const MaxDecodeDepth = 8
+
+// minimumEncodedLength is the smallest number of decoded bytes of a hexadecimal or base64 token
+const minimumEncodedLength = 2
+
+// decodingOrder is the order in which the decodings are applied in every round
+var decodingOrder = []Decoding{DecodeURL, DecodeHTML, DecodeHex, DecodeBase64, DecodeChar}
+
+var charFunction = regexp.MustCompile(`^(?i)(?:N?CHAR|CHR)\(\s*(\d{1,7})\s*\)`)
+var charConcatenation = regexp.MustCompile(`^(?i)\s*(?:\+|\|\||,)\s*(?:N?CHAR|CHR)\(\s*(\d{1,7})\s*\)`)
+var hexLiteral = regexp.MustCompile(`^0[xX]((?:[0-9A-Fa-f]{2})+)`)
+var base64Token = regexp.MustCompile(`^[A-Za-z0-9+/]+={0,2}`)
+
+// decodingSet is a set of decodings, every decoding is a bit in the position it has in decodingOrder
+type decodingSet uint8
+
+// list returns the decodings in the set
+func (s decodingSet) list() []Decoding {
+	var result []Decoding
+	for index, decoding := range decodingOrder {
+		if s&(1<<index) != 0 {
+			result = append(result, decoding)
+		}
+	}
+	return result
+}
+
+// ParseDecoding converts the name of a decoding to a Decoding
+func ParseDecoding(name string) (Decoding, error) {
+	decoding := Decoding(strings.ToLower(strings.TrimSpace(name)))
+	if !slices.Contains(decodingOrder, decoding) {
+		return "", fmt.Errorf("unsupported decoding %q", name)
+	}
+	return decoding, nil
+}
+
+// segment is a part of an encoded token and the text it decodes to
+type segment struct {
+	length      int
+	replacement string
+}
+
+// decoder returns the segments of the encoded token at the start of the remaining text, or nil when there is none.
+// The previous rune is the last rune before the remaining text, or utf8.RuneError at the start of the text.
+type decoder func(remaining string, previous rune) []segment
+
+// decode applies the requested decodings to the text, repeating them until nothing changes or the depth is reached
+func decode(text string, decodings []Decoding, depth int) mappedText {
+	if depth <= 0 {
+		depth = DefaultDecodeDepth
+	}
+	depth = min(depth, MaxDecodeDepth)
+
+	result := mappedText{text: text}
+	for round := 0; round < depth; round++ {
+		changed := false
+		for index, decoding := range decodingOrder {
+			if !slices.Contains(decodings, decoding) {
+				continue
+			}
+
+			decoded, ok := result.decode(decoders[decoding], decodingSet(1)<<index)
+			if ok {
+				result = decoded
+				changed = true
+			}
+		}
+
+		if !changed {
+			break
+		}
+	}
+
+	return result
+}
+
+// decode rewrites every token the decoder recognizes, it returns false when there was nothing to decode
+func (t mappedText) decode(decode decoder, decoding decodingSet) (mappedText, bool) {
+	var pending []segment
+	previous := utf8.RuneError
+	changed := false
+
+	result := t.rewrite(func(remaining string) (int, string, bool) {
+		if len(pending) == 0 {
+			pending = decode(remaining, previous)
+		}
+
+		if len(pending) > 0 {
+			next := pending[0]
+			pending = pending[1:]
+			previous, _ = utf8.DecodeLastRuneInString(remaining[:next.length])
+			changed = true
+			return next.length, next.replacement, true
+		}
+
+		r, width := utf8.DecodeRuneInString(remaining)
+		previous = r
+		return width, remaining[:width], false
+	}, decoding)
+
+	return result, changed
+}
+
+var decoders = map[Decoding]decoder{
+	DecodeURL:    decodeURL,
+	DecodeHTML:   decodeHTML,
+	DecodeHex:    decodeHex,
+	DecodeBase64: decodeBase64,
+	DecodeChar:   decodeChar,
+}
+
+// decodeURL decodes a single percent encoded byte
+func decodeURL(remaining string, _ rune) []segment {
+	if len(remaining) < 3 || remaining[0] != '%' {
+		return nil
+	}
+
+	decoded, err := hex.DecodeString(remaining[1:3])
+	if err != nil {
+		return nil
+	}
+	return []segment{{length: 3, replacement: string(decoded)}}
+}
+
+// decodeHTML decodes a single named or numeric character reference, which must be terminated by a semicolon
+func decodeHTML(remaining string, _ rune) []segment {
+	if len(remaining) < 4 || remaining[0] != '&' {
+		return nil
+	}
+
+	end := strings.IndexByte(remaining[:min(len(remaining), 12)], ';')
+	if end < 0 {
+		return nil
+	}
+
+	entity := remaining[:end+1]
+	decoded := html.UnescapeString(entity)
+	if decoded == entity {
+		return nil
+	}
+	return []segment{{length: len(entity), replacement: decoded}}
+}
+
+// decodeHex decodes a hexadecimal literal that starts a token and decodes to printable text. The prefix is part of
+// the first decoded byte, every following byte maps to its two digits.
+func decodeHex(remaining string, previous rune) []segment {
+	if isWordRune(previous) {
+		return nil
+	}
+
+	loc := hexLiteral.FindStringSubmatchIndex(remaining)
+	if loc == nil || endsInWord(remaining, loc[1]) {
+		return nil
+	}
+
+	decoded, err := hex.DecodeString(remaining[loc[2]:loc[3]])
+	if err != nil || len(decoded) < minimumEncodedLength || !isPrintable(decoded) {
+		return nil
+	}
+
+	segments := make([]segment, len(decoded))
+	for index, b := range decoded {
+		segments[index] = segment{length: 2, replacement: string([]byte{b})}
+	}
+	segments[0].length += 2
+	return segments
+}
+
+// decodeBase64 decodes a base64 token that decodes to printable text. Every group of four characters maps to the
+// bytes it decodes to.
+func decodeBase64(remaining string, previous rune) []segment {
+	if isWordRune(previous) {
+		return nil
+	}
+
+	loc := base64Token.FindStringIndex(remaining)
+	if loc == nil || loc[1]%4 != 0 || endsInWord(remaining, loc[1]) {
+		return nil
+	}
+
+	token := remaining[:loc[1]]
+	decoded, err := base64.StdEncoding.DecodeString(token)
+	if err != nil || len(decoded) < minimumEncodedLength || !isPrintable(decoded) {
+		return nil
+	}
+
+	var segments []segment
+	for group := 0; group < len(token); group += 4 {
+		from := group / 4 * 3
+		to := min(from+3, len(decoded))
+		segments = append(segments, segment{length: 4, replacement: string(decoded[from:to])})
+	}
+	return segments
+}
+
+// decodeChar decodes a chain of SQL character functions concatenated with +, || or a comma. Every function call maps
+// to the character it returns, including the operator before it.
+func decodeChar(remaining string, previous rune) []segment {
+	if isWordRune(previous) {
+		return nil
+	}
+
+	var segments []segment
+	expression := charFunction
+	for offset := 0; ; {
+		loc := expression.FindStringSubmatchIndex(remaining[offset:])
+		if loc == nil {
+			break
+		}
+
+		code, err := strconv.Atoi(remaining[offset+loc[2] : offset+loc[3]])
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			break
+		}
+
+		segments = append(segments, segment{length: loc[1], replacement: string(rune(code))})
+		offset += loc[1]
+		expression = charConcatenation
+	}
+
+	return segments
+}
+
+// endsInWord reports whether the token ending at the offset is followed by a word character, and is therefore not a
+// token on its own
+func endsInWord(text string, offset int) bool {
+	next, _ := utf8.DecodeRuneInString(text[offset:])
+	return offset < len(text) && isWordRune(next)
+}
+
+// isPrintable reports whether the decoded bytes are valid UTF-8 text without control characters
+func isPrintable(decoded []byte) bool {
+	if !utf8.Valid(decoded) {
+		return false
+	}
+
+	for _, r := range string(decoded) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package logic
+
+import (
+	"cmp"
+	"slices"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Match is a single occurrence of a stored word in a text. The offsets are provided in bytes, runes and UTF-16 code
+// units, the start is inclusive and the end exclusive.
+type Match struct {
+	Pattern Pattern
+	// Decodings lists the decodings that exposed the match, it is empty when the match was found in the text as is
+	Decodings  []Decoding
+	Text       string
+	Start      int
+	End        int
+	RuneStart  int
+	RuneEnd    int
+	UTF16Start int
+	UTF16End   int
+}
+
+// Detect finds all the matches in the input text without altering it, and returns them per text in the same order
+// it was provided. The matches of a text are ordered by their start offset, longest first. Overlapping matches of
+// different words are all reported. In the case of an error the method will return an empty list, and the appropriate
+// error
+func (m *Matcher) Detect(textToDetect []string) (result [][]Match, err error) {
+	return m.DetectWith(textToDetect, Options{})
+}
+
+// DetectWith finds all the matches in the input text the same way as Detect, matching according to the provided
+// options. The mask options are ignored.
+func (m *Matcher) DetectWith(textToDetect []string, options Options) (result [][]Match, err error) {
+	for _, text := range textToDetect {
+		result = append(result, m.matches(text, options))
+	}
+
+	return result, nil
+}
+
+// matches returns all the matches in the text, ordered by their start offset, longest first
+func (m *Matcher) matches(text string, options Options) []Match {
+	matches := []Match{}
+	m.find(text, options, func(start int, end int, pattern Pattern, decodings []Decoding) {
+		matches = append(matches, Match{Pattern: pattern, Decodings: decodings, Text: text[start:end], Start: start, End: end})
+	})
+
+	slices.SortFunc(matches, func(a, b Match) int {
+		return cmp.Or(cmp.Compare(a.Start, b.Start), cmp.Compare(b.End, a.End), cmp.Compare(a.Pattern.ID, b.Pattern.ID))
+	})
+	setOffsets(text, matches)
+
+	return matches
+}
+
+// setOffsets calculates the rune and UTF-16 offsets of the matches from their byte offsets, in a single pass over
+// the text
+func setOffsets(text string, matches []Match) {
+	if len(matches) == 0 {
+		return
+	}
+
+	type position struct {
+		runes int
+		utf16 int
+	}
+
+	positions := make(map[int]position, len(matches)*2)
+	for _, match := range matches {
+		positions[match.Start] = position{}
+		positions[match.End] = position{}
+	}
+
+	var current position
+	for i := 0; ; {
+		if _, ok := positions[i]; ok {
+			positions[i] = current
+		}
+		if i >= len(text) {
+			break
+		}
+
+		r, width := utf8.DecodeRuneInString(text[i:])
+		current.runes++
+		current.utf16 += utf16.RuneLen(r)
+		i += width
+	}
+
+	for index := range matches {
+		start, end := positions[matches[index].Start], positions[matches[index].End]
+		matches[index].RuneStart, matches[index].RuneEnd = start.runes, end.runes
+		matches[index].UTF16Start, matches[index].UTF16End = start.utf16, end.utf16
+	}
+}
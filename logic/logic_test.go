@@ -1,12 +1,15 @@
 package logic
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"reflect"
 	td "sanitize/testdata"
 	"slices"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -161,3 +164,620 @@ func TestFilterTwoWords(t *testing.T) {
 		t.Error("Not complying to expected output")
 	}
 }
+
+func TestFilterOverlapping(t *testing.T) {
+	result, err := SanitizeText([]string{"select * from users", "SELECTED users", "alter the table"},
+		[]string{"SELECT", "SELECT * FROM", "FROM", "TABLE"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := []string{"************* users", "SELECTED users", "alter the *****"}
+	if !slices.Equal(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
+
+func BenchmarkSanitizeText(b *testing.B) {
+	var requests []string
+	for i := 0; i < 500; i++ {
+		requests = append(requests, strings.Replace(unitTest[0], "<string0>", td.Data[i%len(td.Data)], -1))
+	}
+
+	for i := 0; i < b.N; i++ {
+		_, err := SanitizeText(requests, td.Data)
+		if err != nil {
+			b.Error(err)
+		}
+	}
+}
+
+func TestMatcherConcurrent(t *testing.T) {
+	matcher := NewMatcher(map[uint]string{1: "select", 2: "drop"})
+	if matcher.Len() != 2 {
+		t.Error("Expected 2 words, got ", matcher.Len())
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := matcher.Sanitize([]string{"drop the table", "select all"})
+			if err != nil {
+				t.Error(err)
+			}
+			if !slices.Equal(result, []string{"**** the table", "****** all"}) {
+				t.Error("Not complying to expected output ", result)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestFilterUnicode(t *testing.T) {
+	for _, entry := range td.Unicode {
+		result, err := SanitizeText([]string{entry.Sentence}, td.Data)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if result[0] != entry.Sanitized {
+			t.Errorf("%s != %s", result[0], entry.Sanitized)
+		}
+	}
+}
+
+func TestFilterInvalidUTF8(t *testing.T) {
+	result, err := SanitizeText([]string{"\xffdrop\xfe table"}, td.Data)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if result[0] != "\xff****\xfe *****" {
+		t.Errorf("Not complying to expected output %q", result[0])
+	}
+}
+
+func TestPatternTypes(t *testing.T) {
+	matcher, err := CompilePatterns([]Pattern{
+		{ID: 1, Value: "C++", Type: Literal},
+		{ID: 2, Value: "A.B", Type: Literal},
+		{ID: 3, Value: "SYS*", Type: Glob},
+		{ID: 4, Value: "T?B", Type: Glob},
+		{ID: 5, Value: `DROP\s+TABLE`, Type: Regex},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := matcher.Sanitize([]string{
+		"I write C++ and AXB but not A.B",
+		"query SYSACC, systbldef and SYS but not ASYSACC",
+		"TAB TUB TABLE",
+		"drop   table users",
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := []string{
+		"I write *** and AXB but not ***",
+		"query ******, ********* and *** but not ASYSACC",
+		"*** *** TABLE",
+		"************ users",
+	}
+	if !slices.Equal(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	invalid := []Pattern{
+		{Value: "", Type: Literal},
+		{Value: "*?*", Type: Glob},
+		{Value: "DROP(", Type: Regex},
+		{Value: "A*", Type: Regex},
+		{Value: "DROP", Type: "unknown"},
+	}
+
+	for _, pattern := range invalid {
+		if err := ValidatePattern(pattern.Value, pattern.Type); err == nil {
+			t.Errorf("Expected %q of type %s to be invalid", pattern.Value, pattern.Type)
+		}
+	}
+
+	if _, err := CompilePatterns(invalid[2:3], nil); err == nil {
+		t.Error("Expected an invalid regular expression not to compile")
+	}
+}
+
+func TestMaskStrategies(t *testing.T) {
+	matcher := NewMatcher(map[uint]string{1: "SELECT", 2: "DROP", 3: "SELECT * FROM"})
+	text := []string{"select * from users; drop it"}
+	one, two := 1, 2
+
+	tests := []struct {
+		options  MaskOptions
+		expected string
+	}{
+		{MaskOptions{}, "************* users; **** it"},
+		{MaskOptions{Character: '#'}, "############# users; #### it"},
+		{MaskOptions{Strategy: MaskToken}, "[REDACTED] users; [REDACTED] it"},
+		{MaskOptions{Strategy: MaskToken, Token: "<hidden>"}, "<hidden> users; <hidden> it"},
+		{MaskOptions{Strategy: MaskPartial, RevealFirst: &one, RevealLast: &one}, "s***********m users; d**p it"},
+		{MaskOptions{Strategy: MaskPartial, RevealFirst: &two, RevealLast: &two}, "se*********om users; **** it"},
+		{MaskOptions{Strategy: MaskCategory}, "[SENSITIVE] users; [SENSITIVE] it"},
+		{MaskOptions{Strategy: MaskCategory, Label: "SQL_KEYWORD"}, "[SQL_KEYWORD] users; [SQL_KEYWORD] it"},
+	}
+
+	for _, test := range tests {
+		result, err := matcher.SanitizeWith(text, Options{Mask: test.options})
+		if err != nil {
+			t.Error(err)
+		}
+		if result[0] != test.expected {
+			t.Errorf("%s != %s", result[0], test.expected)
+		}
+	}
+}
+
+func TestMaskMerge(t *testing.T) {
+	zero, two := 0, 2
+	defaults := MaskOptions{Strategy: MaskPartial, RevealFirst: &two, RevealLast: &two}
+
+	merged := defaults.Merge(MaskOptions{RevealFirst: &zero})
+	if *merged.RevealFirst != 0 || *merged.RevealLast != 2 {
+		t.Errorf("Expected an explicit 0 to override only the first revealed characters %v", merged)
+	}
+
+	merged = defaults.Merge(MaskOptions{})
+	if *merged.RevealFirst != 2 || *merged.RevealLast != 2 {
+		t.Errorf("Expected unset reveal counts to keep the defaults %v", merged)
+	}
+
+	matcher := NewMatcher(map[uint]string{1: "SECRET"})
+	result, err := matcher.SanitizeWith([]string{"secret"}, Options{Mask: defaults.Merge(MaskOptions{RevealFirst: &zero})})
+	if err != nil || result[0] != "****et" {
+		t.Errorf("Expected only the last characters to be revealed %v %v", result, err)
+	}
+}
+
+func TestMaskHash(t *testing.T) {
+	matcher := NewMatcher(map[uint]string{1: "DROP"})
+
+	_, err := matcher.SanitizeWith([]string{"drop"}, Options{Mask: MaskOptions{Strategy: MaskHash}})
+	if err == nil {
+		t.Error("hash strategy was accepted without a key")
+	}
+
+	options := Options{Mask: MaskOptions{Strategy: MaskHash, HashKey: []byte("secret")}}
+	result, err := matcher.SanitizeWith([]string{"drop it", "DROP it"}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result[0] != result[1] || !strings.HasPrefix(result[0], "[") || strings.Contains(strings.ToUpper(result[0]), "DROP") {
+		t.Errorf("Expected the same hash for the same word, got %v", result)
+	}
+
+	options.Mask.HashKey = []byte("other")
+	other, err := matcher.SanitizeWith([]string{"drop it"}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other[0] == result[0] {
+		t.Error("Expected a different key to produce a different hash")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	matcher := NewMatcher(map[uint]string{7: "SELECT", 8: "SELECT * FROM", 9: "DROP"})
+
+	result, err := matcher.Detect([]string{"🔥 é select * from t", "nothing here"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 2 || len(result[0]) != 2 || len(result[1]) != 0 {
+		t.Fatalf("Unexpected matches %v", result)
+	}
+
+	expected := []Match{
+		{Pattern: Pattern{ID: 8, Value: "SELECT * FROM", Type: Literal}, Text: "select * from",
+			Start: 8, End: 21, RuneStart: 4, RuneEnd: 17, UTF16Start: 5, UTF16End: 18},
+		{Pattern: Pattern{ID: 7, Value: "SELECT", Type: Literal}, Text: "select",
+			Start: 8, End: 14, RuneStart: 4, RuneEnd: 10, UTF16Start: 5, UTF16End: 11},
+	}
+	if !reflect.DeepEqual(result[0], expected) {
+		t.Errorf("Expected %v, got %v", expected, result[0])
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	matcher, err := CompilePatterns([]Pattern{{ID: 1, Value: "ORDER"}, {ID: 2, Value: "ON"}, {ID: 3, Value: "DROP"}},
+		[]string{"your order", "on its way", "drop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := matcher.Sanitize([]string{
+		"Your ORDER is ON its way",
+		"ORDER BY name ON table",
+		"your orders ON time",
+		"drop",
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := []string{
+		"Your ORDER is ON its way",
+		"***** BY name ** table",
+		"your orders ** time",
+		"drop",
+	}
+	if !slices.Equal(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
+
+func TestObfuscation(t *testing.T) {
+	matcher := NewMatcher(map[uint]string{1: "SELECT", 2: "DROP", 3: "SELECT * FROM", 4: "SET", 5: "ORDER", 6: "GOTO"})
+
+	requests := []string{
+		"S E L E C T name",
+		"SEL/**/ECT name",
+		"s3l3ct * fr0m users",
+		"please DR0P it",
+		"d.r.o.p",
+		"D-R/* hidden */O-P table",
+		"5 3 7 apples",
+		"border and s    e    t",
+		"selected",
+		"go to the shop",
+		"let's go   to it",
+		"g o t o",
+		"go.to",
+		"se lect one",
+	}
+	expected := []string{
+		"*********** name",
+		"********** name",
+		"************* users",
+		"please **** it",
+		"*******",
+		"****************** table",
+		"5 3 7 apples",
+		"border and s    e    t",
+		"selected",
+		"go to the shop",
+		"let's go   to it",
+		"*******",
+		"*****",
+		"se lect one",
+	}
+
+	result, err := matcher.SanitizeWith(requests, Options{Obfuscation: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result, expected) {
+		for index, res := range result {
+			if res != expected[index] {
+				t.Errorf("%s != %s", res, expected[index])
+			}
+		}
+	}
+
+	//Obfuscated words are only matched when requested
+	result, err = matcher.Sanitize(requests[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result[0] != requests[0] {
+		t.Error("Expected the obfuscated word not to be sanitized, got ", result[0])
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	matcher := NewMatcher(map[uint]string{1: "DROP", 2: "SELECT * FROM", 3: "STRASSE"})
+	all := []Stage{StageNFKC, StageCaseFold, StageInvisible, StageHomoglyph, StageWhitespace}
+
+	tests := []struct {
+		stages    []Stage
+		sentence  string
+		sanitized string
+	}{
+		{all, "DR​OP table", "***** table"},
+		{all, "a​DROP", "a​DROP"},
+		{all, "‮DROP‬ table", "‮****‬ table"},
+		{all, "ＤＲＯＰ table", "**** table"},
+		{all, "DRОP table", "**** table"},
+		{all, "ΡΟΡ DRΟP", "ΡΟΡ ****"},
+		{all, "select  *\t\tfrom users", "*************** users"},
+		{all, "die straße", "die ******"},
+		{[]Stage{StageWhitespace}, "DR​OP select   * from", "DR​OP ***************"},
+		{nil, "DR​OP ＤＲＯＰ", "DR​OP ＤＲＯＰ"},
+	}
+
+	for _, test := range tests {
+		result, err := matcher.SanitizeWith([]string{test.sentence}, Options{Canonicalize: test.stages})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result[0] != test.sanitized {
+			t.Errorf("%q != %q", result[0], test.sanitized)
+		}
+	}
+
+	detected, err := matcher.DetectWith([]string{"x ＤＲ​ＯＰ"}, Options{Canonicalize: all})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(detected[0]) != 1 || detected[0][0].Text != "ＤＲ​ＯＰ" || detected[0][0].RuneStart != 2 || detected[0][0].RuneEnd != 7 {
+		t.Error("Unexpected detection ", detected)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	matcher := NewMatcher(map[uint]string{1: "SELECT", 2: "DROP", 3: "UNION"})
+	all := Options{Decode: []Decoding{DecodeURL, DecodeHTML, DecodeHex, DecodeBase64, DecodeChar}}
+
+	tests := []struct {
+		sentence  string
+		sanitized string
+		decodings []Decoding
+	}{
+		{"id=%53%45%4C%45%43%54 name", "id=****************** name", []Decoding{DecodeURL}},
+		{"&#x53;ELECT name", "*********** name", []Decoding{DecodeHTML}},
+		{"&#83;&#69;LECT name", "************** name", []Decoding{DecodeHTML}},
+		{"exec 0x53454C454354 now", "exec ************** now", []Decoding{DecodeHex}},
+		{"run U0VMRUNU now", "run ******** now", []Decoding{DecodeBase64}},
+		{"CHAR(68)+CHAR(82) + CHAR(79)||CHAR(80) t", "************************************** t", []Decoding{DecodeChar}},
+		{"id=%2553%2545%254C%2545%2543%2554", "id=******************************", []Decoding{DecodeURL}},
+		{"%26%2383%3BELECT", "****************", []Decoding{DecodeURL, DecodeHTML}},
+		{"0x53454C454354ED", "0x53454C454354ED", nil},
+		{"%53%45%4C%45%43%54ED", "%53%45%4C%45%43%54ED", nil},
+		{"SELECTED U0VMRUNURUQ=", "SELECTED U0VMRUNURUQ=", nil},
+		{"plain DROP", "plain ****", nil},
+	}
+
+	for _, test := range tests {
+		result, err := matcher.SanitizeMatches([]string{test.sentence}, all)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result[0].Text != test.sanitized {
+			t.Errorf("%q != %q", result[0].Text, test.sanitized)
+		}
+		for _, match := range result[0].Matches {
+			if match.Pattern.ID != 2 && !slices.Equal(match.Decodings, test.decodings) {
+				t.Errorf("Expected decodings %v for %q, got %v", test.decodings, test.sentence, match.Decodings)
+			}
+		}
+	}
+
+	//Decoding is only applied when requested, and limited by the depth
+	result, err := matcher.Sanitize([]string{"%53%45%4C%45%43%54"})
+	if err != nil || result[0] != "%53%45%4C%45%43%54" {
+		t.Error("Expected the encoded word not to be sanitized, got ", result)
+	}
+
+	result, err = matcher.SanitizeWith([]string{"%2553%2545%254C%2545%2543%2554"},
+		Options{Decode: []Decoding{DecodeURL}, DecodeDepth: 1})
+	if err != nil || result[0] != "%2553%2545%254C%2545%2543%2554" {
+		t.Error("Expected the depth to limit the decoding, got ", result)
+	}
+}
+
+func TestScore(t *testing.T) {
+	matcher := NewMatcher(map[uint]string{1: "SELECT", 2: "UNION", 3: "DROP", 4: "TABLE", 5: "FROM"})
+
+	tests := []struct {
+		sentence string
+		score    int
+		level    RiskLevel
+		reasons  []Reason
+	}{
+		{"your order is on its way", 0, RiskNone, nil},
+		{"don't forget the \"quoted\" text", 0, RiskNone, nil},
+		{"please select a colour", 10, RiskLow, []Reason{ReasonKeyword}},
+		{"admin'--", 35, RiskMedium, []Reason{ReasonComment, ReasonQuoteImbalance}},
+		{"' OR 1=1 --", 75, RiskHigh, []Reason{ReasonComment, ReasonTautology, ReasonQuoteImbalance}},
+		{"x' or 'a'='a", 40, RiskMedium, []Reason{ReasonTautology}},
+		{"1; DROP TABLE users", 50, RiskMedium, []Reason{ReasonKeyword, ReasonStackedStatement}},
+		{"1 UNION/**/ALL SELECT name FROM users", 90, RiskHigh, []Reason{ReasonKeyword, ReasonComment, ReasonUnionSelect}},
+		{"it''s 'fine'", 0, RiskNone, nil},
+		{"either this or that = something", 0, RiskNone, nil},
+	}
+
+	for _, test := range tests {
+		result, err := matcher.Score([]string{test.sentence})
+		if err != nil {
+			t.Fatal(err)
+		}
+		risk := result[0]
+		if risk.Score != test.score || risk.Level != test.level || !slices.Equal(risk.Reasons, test.reasons) {
+			t.Errorf("%q scored %d %s %v, expected %d %s %v", test.sentence, risk.Score, risk.Level, risk.Reasons,
+				test.score, test.level, test.reasons)
+		}
+	}
+
+	//Structural signals are also found in the decoded text
+	result, err := matcher.ScoreWith([]string{"id=1%27%20OR%201%3D1"}, Options{Decode: []Decoding{DecodeURL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(result[0].Reasons, ReasonTautology) {
+		t.Error("Expected the decoded tautology to be found, got ", result[0].Reasons)
+	}
+}
+
+func TestBlockPolicy(t *testing.T) {
+	matcher, err := CompilePatterns([]Pattern{
+		{ID: 1, Value: "SELECT"},
+		{ID: 2, Value: "DROP", Severity: SeverityCritical},
+		{ID: 3, Value: "ORDER", Severity: SeverityMedium},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		policy  BlockPolicy
+		blocked []bool
+	}{
+		{BlockPolicy{}, []bool{false, false, false, false}},
+		{BlockPolicy{Severity: SeverityHigh}, []bool{false, true, false, false}},
+		{BlockPolicy{Severity: SeverityMedium}, []bool{false, true, true, false}},
+		{BlockPolicy{Severity: SeverityLow}, []bool{true, true, true, false}},
+		{BlockPolicy{Matches: 2}, []bool{false, false, true, false}},
+	}
+
+	sentences := []string{"select a colour", "drop it", "select order", "nothing"}
+	for _, test := range tests {
+		result, err := matcher.SanitizeMatches(sentences, Options{Block: test.policy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for index, sanitized := range result {
+			if sanitized.Blocked != test.blocked[index] {
+				t.Errorf("Policy %v expected blocked %v for %q", test.policy, test.blocked[index], sentences[index])
+			}
+		}
+		if result[1].Text != "**** it" {
+			t.Error("Expected a blocked sentence to still be sanitized, got ", result[1].Text)
+		}
+	}
+
+	if _, err := ParseSeverity("severe"); err == nil {
+		t.Error("Expected an unknown severity to be rejected")
+	}
+	if severity, err := ParseSeverity(" High "); err != nil || severity != SeverityHigh {
+		t.Error("Expected the high severity, got ", severity, err)
+	}
+	if _, err := matcher.SanitizeMatches(sentences, Options{Block: BlockPolicy{Matches: -1}}); err == nil {
+		t.Error("Expected a negative match count to be rejected")
+	}
+}
+
+func TestCategories(t *testing.T) {
+	matcher, err := CompilePatterns([]Pattern{
+		{ID: 1, Value: "SELECT", Categories: []string{"sql-keyword"}},
+		{ID: 2, Value: "DARN", Categories: []string{"profanity"}},
+		{ID: 3, Value: "SYSOBJECTS", Categories: []string{"sql-catalog", "sql-keyword"}},
+		{ID: 4, Value: "PHOENIX"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sentence := []string{"darn, select from sysobjects for phoenix"}
+	tests := []struct {
+		filter    CategoryFilter
+		sanitized string
+	}{
+		{CategoryFilter{}, "****, ****** from ********** for *******"},
+		{CategoryFilter{Include: []string{"profanity"}}, "****, select from sysobjects for phoenix"},
+		{CategoryFilter{Exclude: []string{"sql-keyword"}}, "****, select from sysobjects for *******"},
+		{CategoryFilter{Include: []string{"sql-keyword"}, Exclude: []string{"sql-catalog"}}, "darn, ****** from sysobjects for phoenix"},
+	}
+
+	for _, test := range tests {
+		result, err := matcher.SanitizeWith(sentence, Options{Categories: test.filter})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result[0] != test.sanitized {
+			t.Errorf("%v: %q != %q", test.filter, result[0], test.sanitized)
+		}
+	}
+
+	result, err := matcher.SanitizeWith(sentence, Options{Mask: MaskOptions{Strategy: MaskCategory}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result[0] != "[PROFANITY], [SQL_KEYWORD] from [SQL_CATALOG] for [SENSITIVE]" {
+		t.Error("Unexpected category labels ", result[0])
+	}
+
+	categories, err := ParseCategories([]string{"SQL Keyword", "profanity", "sql-keyword"})
+	if err != nil || !slices.Equal(categories, []string{"profanity", "sql-keyword"}) {
+		t.Error("Unexpected categories ", categories, err)
+	}
+	if _, err := ParseCategory("sql/keyword"); err == nil {
+		t.Error("Expected an invalid category to be rejected")
+	}
+}
+
+func TestPatternMetadata(t *testing.T) {
+	matcher, err := CompilePatterns([]Pattern{
+		{ID: 1, Value: "SELECT", Replacement: "[QUERY]"},
+		{ID: 2, Value: "Bob", CaseSensitive: true},
+		{ID: 3, Value: "DROP", Boundary: BoundaryNone},
+		{ID: 4, Value: "ORDER", Disabled: true},
+		{ID: 5, Value: "un.on", Type: Regex, Boundary: BoundaryWord},
+		{ID: 6, Value: "Ex?c", Type: Glob, CaseSensitive: true},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if matcher.Len() != 5 {
+		t.Error("Expected the disabled pattern to be left out, got ", matcher.Len())
+	}
+
+	tests := map[string]string{
+		"select your order":       "[QUERY] your order",
+		"Bob and bob":             "*** and bob",
+		"BACKDROPS":               "BACK****S",
+		"union reunion unionized": "***** reunion unionized",
+		"Exec EXEC":               "**** EXEC",
+	}
+	for text, expected := range tests {
+		result, err := matcher.Sanitize([]string{text})
+		if err != nil || result[0] != expected {
+			t.Errorf("Expected %q for %q, got %v %v", expected, text, result, err)
+		}
+	}
+
+	result, err := SanitizePatterns([]string{"select order"}, []Pattern{{ID: 1, Value: "ORDER", Replacement: "#"}})
+	if err != nil || result[0] != "select #" {
+		t.Error("Expected the replacement of the pattern, got ", result, err)
+	}
+
+	if _, err := CompilePatterns([]Pattern{{ID: 1, Value: "SELECT", Boundary: "line"}}, nil); err == nil {
+		t.Error("Expected an unsupported boundary to be rejected")
+	}
+	if err := ValidatePattern("(", Regex); !errors.Is(err, ErrInvalidPattern) {
+		t.Error("Expected an invalid pattern, got ", err)
+	}
+	if err := ValidatePattern("*", Glob); !errors.Is(err, ErrInvalidPattern) {
+		t.Error("Expected an invalid pattern, got ", err)
+	}
+
+	if boundary, err := ParseBoundary(" None "); err != nil || boundary != BoundaryNone {
+		t.Error("Expected the none boundary, got ", boundary, err)
+	}
+}
+
+func TestRegexAnchors(t *testing.T) {
+	matcher, err := CompilePatterns([]Pattern{
+		{ID: 1, Value: `^AB`, Type: Regex, Boundary: BoundaryNone},
+		{ID: 2, Value: `\bUN`, Type: Regex, Boundary: BoundaryNone},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//The remainder after a match does not start a new text
+	result, err := matcher.Sanitize([]string{"abab", "ununited"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"**ab", "**united"}
+	if !slices.Equal(result, expected) {
+		t.Errorf("Expected the anchors to apply to the whole text %v, got %v", expected, result)
+	}
+}
@@ -0,0 +1,230 @@
+package logic
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaskStrategy defines how a match is replaced in the sanitized text
+type MaskStrategy string
+
+const (
+	// MaskCharacter replaces every character of the match with the mask character, this is the default
+	MaskCharacter MaskStrategy = "character"
+	// MaskToken replaces the match with a fixed token, so that the length of the match is not revealed
+	MaskToken MaskStrategy = "token"
+	// MaskPartial keeps the first and last characters of the match and replaces the rest with the mask character
+	MaskPartial MaskStrategy = "partial"
+	// MaskCategory replaces the match with the label of the first category of the word, for example [SQL_KEYWORD] for
+	// the category sql-keyword
+	MaskCategory MaskStrategy = "category"
+	// MaskHash replaces the match with a keyed hash of the matched word, the same word always has the same replacement
+	MaskHash MaskStrategy = "hash"
+)
+
+// hashLength is the number of hexadecimal characters of the keyed hash used as the replacement
+const hashLength = 16
+
+// MaskOptions configures how matches are replaced in the sanitized text. Options that are not set use the values
+// of DefaultMaskOptions.
+type MaskOptions struct {
+	Strategy MaskStrategy
+	// Character is used by the character and partial strategies
+	Character rune
+	// Token is used by the token strategy
+	Token string
+	// RevealFirst and RevealLast are the number of characters kept at the start and end by the partial strategy, nil
+	// when they are not set, which reveals nothing
+	RevealFirst *int
+	RevealLast  *int
+	// Label is used by the category strategy for words without a category
+	Label string
+	// HashKey is the secret key of the hash strategy, the strategy can not be used without it
+	HashKey []byte
+}
+
+// DefaultMaskOptions returns the options used when none are provided, every character of a match is replaced with *
+func DefaultMaskOptions() MaskOptions {
+	return MaskOptions{
+		Strategy:  MaskCharacter,
+		Character: '*',
+		Token:     "[REDACTED]",
+		Label:     "SENSITIVE",
+	}
+}
+
+// ParseMaskStrategy converts the name of a strategy to a MaskStrategy, an empty name defaults to MaskCharacter
+func ParseMaskStrategy(name string) (MaskStrategy, error) {
+	switch MaskStrategy(strings.ToLower(strings.TrimSpace(name))) {
+	case "", MaskCharacter:
+		return MaskCharacter, nil
+	case MaskToken:
+		return MaskToken, nil
+	case MaskPartial:
+		return MaskPartial, nil
+	case MaskCategory:
+		return MaskCategory, nil
+	case MaskHash:
+		return MaskHash, nil
+	default:
+		return "", fmt.Errorf("unsupported mask strategy %q", name)
+	}
+}
+
+// Merge returns a copy of the options where every option that is set in override replaces the current value. The
+// revealed characters are set when they are not nil, so that an override can reveal 0 characters.
+func (o MaskOptions) Merge(override MaskOptions) MaskOptions {
+	if override.Strategy != "" {
+		o.Strategy = override.Strategy
+	}
+	if override.Character != 0 {
+		o.Character = override.Character
+	}
+	if override.Token != "" {
+		o.Token = override.Token
+	}
+	if override.RevealFirst != nil {
+		o.RevealFirst = override.RevealFirst
+	}
+	if override.RevealLast != nil {
+		o.RevealLast = override.RevealLast
+	}
+	if override.Label != "" {
+		o.Label = override.Label
+	}
+	if len(override.HashKey) > 0 {
+		o.HashKey = override.HashKey
+	}
+	return o
+}
+
+// Validate checks whether the options can be used to mask text
+func (o MaskOptions) Validate() error {
+	if _, err := ParseMaskStrategy(string(o.Strategy)); err != nil {
+		return err
+	}
+	if revealed(o.RevealFirst) < 0 || revealed(o.RevealLast) < 0 {
+		return errors.New("the number of revealed characters can not be negative")
+	}
+	if o.Strategy == MaskHash && len(o.HashKey) == 0 {
+		return errors.New("the hash strategy requires a hash key")
+	}
+	return nil
+}
+
+// replacement returns the text that replaces the match of the pattern, the replacement of the pattern takes precedence
+// over the strategy
+func (o MaskOptions) replacement(match string, pattern Pattern) string {
+	if pattern.Replacement != "" {
+		return pattern.Replacement
+	}
+
+	switch o.Strategy {
+	case MaskToken:
+		return o.Token
+	case MaskCategory:
+		if len(pattern.Categories) > 0 {
+			return "[" + categoryLabel(pattern.Categories[0]) + "]"
+		}
+		return "[" + o.Label + "]"
+	case MaskHash:
+		mac := hmac.New(sha256.New, o.HashKey)
+		mac.Write([]byte(strings.ToUpper(match)))
+		return "[" + hex.EncodeToString(mac.Sum(nil))[:hashLength] + "]"
+	case MaskPartial:
+		length := utf8.RuneCountInString(match)
+		first, last := revealed(o.RevealFirst), revealed(o.RevealLast)
+		if first+last >= length {
+			return maskCharacters(match, o.Character, 0, length)
+		}
+		return maskCharacters(match, o.Character, first, length-last)
+	default:
+		return maskCharacters(match, o.Character, 0, utf8.RuneCountInString(match))
+	}
+}
+
+// revealed returns the number of revealed characters, none when it is not set
+func revealed(count *int) int {
+	if count == nil {
+		return 0
+	}
+	return *count
+}
+
+// maskCharacters replaces the characters from index from up to index to with the mask character
+func maskCharacters(text string, character rune, from int, to int) string {
+	var builder strings.Builder
+	builder.Grow(len(text))
+
+	index := 0
+	for _, r := range text {
+		if index >= from && index < to {
+			builder.WriteRune(character)
+		} else {
+			builder.WriteRune(r)
+		}
+		index++
+	}
+
+	return builder.String()
+}
+
+// span is a range of byte offsets in a text that matched a pattern, the start is inclusive and the end exclusive
+type span struct {
+	start   int
+	end     int
+	pattern Pattern
+}
+
+// mergeSpans sorts the spans and combines the ones that overlap, so that every byte is covered at most once.
+// A combined span keeps the pattern of the longest span it was built from.
+func mergeSpans(spans []span) []span {
+	slices.SortFunc(spans, func(a, b span) int {
+		if a.start != b.start {
+			return a.start - b.start
+		}
+		return b.end - a.end
+	})
+
+	var merged []span
+	for _, s := range spans {
+		if len(merged) > 0 && s.start < merged[len(merged)-1].end {
+			last := &merged[len(merged)-1]
+			if s.end-s.start > last.end-last.start {
+				last.pattern = s.pattern
+			}
+			last.end = max(last.end, s.end)
+			continue
+		}
+		merged = append(merged, s)
+	}
+
+	return merged
+}
+
+// maskSpans replaces the text inside the spans according to the options. The text outside the spans is copied as is,
+// so the result is valid UTF-8 whenever the input is.
+func maskSpans(text string, spans []span, options MaskOptions) string {
+	if len(spans) == 0 {
+		return text
+	}
+
+	var builder strings.Builder
+	builder.Grow(len(text))
+
+	last := 0
+	for _, s := range mergeSpans(spans) {
+		builder.WriteString(text[last:s.start])
+		builder.WriteString(options.replacement(text[s.start:s.end], s.pattern))
+		last = s.end
+	}
+	builder.WriteString(text[last:])
+
+	return builder.String()
+}
@@ -0,0 +1,273 @@
+package logic
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+)
+
+// Matcher is the compiled form of a word list. It is built once whenever the word list changes and is immutable
+// afterward, so a single Matcher can be shared by any number of goroutines without locking.
+type Matcher struct {
+	//literals are all matched in a single pass by the automaton, the other pattern types are matched one by one
+	automaton   *automaton
+	literals    []Pattern
+	expressions []expression
+	size        int
+	//allowed finds the allowlist phrases, a match that is covered by an allowed phrase is not reported
+	allowed *automaton
+	//obfuscated finds the skeletons of the literals in the skeleton of a text, see newSkeleton
+	obfuscated          *automaton
+	obfuscatedPatterns  []Pattern
+	obfuscatedSkeletons []skeleton
+}
+
+// Options configures how text is matched and sanitized by a single call
+type Options struct {
+	Mask MaskOptions
+	// Obfuscation also matches literals that are disguised with separators or inline comments between their
+	// characters, such as "S E L E C T" or "SEL/**/ECT", or with common character substitutions such as "DR0P"
+	Obfuscation bool
+	// Canonicalize lists the canonicalization stages applied to the text before it is matched, the matches are
+	// always reported and masked in the original text
+	Canonicalize []Stage
+	// Decode lists the encodings that are decoded before the text is matched, the text is matched both as is and
+	// decoded. DecodeDepth limits how many times nested encodings are decoded, DefaultDecodeDepth when not set.
+	Decode      []Decoding
+	DecodeDepth int
+	// Block defines when a text is refused instead of sanitized, the text is still sanitized but marked as blocked
+	Block BlockPolicy
+	// Categories restricts the words that are matched by their categories
+	Categories CategoryFilter
+}
+
+// Sanitized is the result of sanitizing a single text, with the matches that were masked
+type Sanitized struct {
+	Text    string
+	Matches []Match
+	// Blocked reports whether the matches exceed the block policy of the options
+	Blocked bool
+}
+
+type expression struct {
+	pattern    Pattern
+	re         *regexp.Regexp
+	wholeWords bool
+}
+
+// NewMatcher compiles the provided words, keyed by their unique id, into a Matcher. All the words are matched as
+// literals.
+func NewMatcher(words map[uint]string) *Matcher {
+	patterns := make([]Pattern, 0, len(words))
+	for id, word := range words {
+		patterns = append(patterns, Pattern{ID: id, Value: word, Type: Literal})
+	}
+
+	//Literal patterns can not fail to compile
+	matcher, _ := CompilePatterns(patterns, nil)
+	return matcher
+}
+
+// CompilePatterns compiles the provided patterns and allowlist phrases into a Matcher. An error is returned when any
+// of the patterns is invalid, see ValidatePattern. A match is not sanitized when an occurrence of an allowed phrase
+// covers it, for example the phrase "your order" allows "ORDER" in "your order is on its way". The phrases are
+// matched as case insensitive whole words. Disabled patterns are left out.
+func CompilePatterns(patterns []Pattern, allowed []string) (*Matcher, error) {
+	sorted := slices.DeleteFunc(slices.Clone(patterns), func(p Pattern) bool {
+		return p.Disabled
+	})
+	slices.SortFunc(sorted, func(a, b Pattern) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	m := &Matcher{size: len(sorted)}
+
+	var literals, obfuscated []string
+	for _, pattern := range sorted {
+		patternType := pattern.Type
+		if patternType == "" {
+			patternType = Literal
+		}
+
+		if err := ValidatePattern(pattern.Value, patternType); err != nil {
+			return nil, err
+		}
+
+		if _, err := ParseBoundary(string(pattern.Boundary)); err != nil {
+			return nil, err
+		}
+
+		pattern.Type = patternType
+		if patternType == Literal && !pattern.CaseSensitive {
+			m.literals = append(m.literals, pattern)
+			literals = append(literals, pattern.Value)
+
+			if reduced := skeletonPattern(pattern.Value); reduced.text != "" {
+				m.obfuscatedPatterns = append(m.obfuscatedPatterns, pattern)
+				m.obfuscatedSkeletons = append(m.obfuscatedSkeletons, reduced)
+				obfuscated = append(obfuscated, reduced.text)
+			}
+			continue
+		}
+
+		re, err := compilePattern(pattern.Value, patternType, pattern.CaseSensitive)
+		if err != nil {
+			return nil, err
+		}
+		m.expressions = append(m.expressions, expression{pattern: pattern, re: re, wholeWords: pattern.wholeWords()})
+	}
+
+	m.automaton = newAutomaton(literals)
+	m.obfuscated = newAutomaton(obfuscated)
+	if len(allowed) > 0 {
+		m.allowed = newAutomaton(allowed)
+	}
+	return m, nil
+}
+
+// Len returns the number of words the Matcher was compiled from
+func (m *Matcher) Len() int {
+	return m.size
+}
+
+// Sanitize sanitizes the input text against the compiled word list and returns the result in the same order
+// it was provided. In the case of an error the method will return a empty string list, and the appropriate error
+func (m *Matcher) Sanitize(textToSanitize []string) (result []string, err error) {
+	return m.SanitizeWith(textToSanitize, Options{})
+}
+
+// SanitizeWith sanitizes the input text the same way as Sanitize, matching and replacing according to the provided
+// options. Mask options that are not set use the values of DefaultMaskOptions.
+func (m *Matcher) SanitizeWith(textToSanitize []string, options Options) (result []string, err error) {
+	sanitized, err := m.SanitizeMatches(textToSanitize, options)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range sanitized {
+		result = append(result, s.Text)
+	}
+
+	return result, nil
+}
+
+// SanitizeMatches sanitizes the input text the same way as SanitizeWith, and also returns the matches that were
+// masked in every text, see Detect, and whether the text is blocked by the block policy of the options.
+func (m *Matcher) SanitizeMatches(textToSanitize []string, options Options) (result []Sanitized, err error) {
+	mask := DefaultMaskOptions().Merge(options.Mask)
+	if err := mask.Validate(); err != nil {
+		return nil, err
+	}
+	if err := options.Block.Validate(); err != nil {
+		return nil, err
+	}
+
+	for _, text := range textToSanitize {
+		//Collecting the byte offsets of all the matches against the original text, and censoring them once all the
+		//matches are known
+		matches := m.matches(text, options)
+
+		spans := make([]span, 0, len(matches))
+		for _, match := range matches {
+			spans = append(spans, span{start: match.Start, end: match.End, pattern: match.Pattern})
+		}
+
+		result = append(result, Sanitized{
+			Text:    maskSpans(text, spans, mask),
+			Matches: matches,
+			Blocked: options.Block.Blocks(matches),
+		})
+	}
+
+	return result, nil
+}
+
+// find calls found for every match of every pattern in the text, the offsets are byte offsets into the original text,
+// also when the text was decoded or canonicalized before it was matched. Matches that were only found once the text
+// was decoded are reported with the decodings that exposed them.
+func (m *Matcher) find(text string, options Options, found func(start int, end int, pattern Pattern, decodings []Decoding)) {
+	m.findView(mappedText{text: text}, options, found)
+
+	if len(options.Decode) > 0 {
+		decoded := decode(text, options.Decode, options.DecodeDepth)
+		if decoded.decoded == nil {
+			return
+		}
+
+		m.findView(decoded, options, func(start int, end int, pattern Pattern, decodings []Decoding) {
+			if len(decodings) > 0 {
+				found(start, end, pattern, decodings)
+			}
+		})
+	}
+}
+
+// findView canonicalizes the text and calls found for every match, mapped back to the original text
+func (m *Matcher) findView(view mappedText, options Options, found func(start int, end int, pattern Pattern, decodings []Decoding)) {
+	canonical := view.canonicalize(options.Canonicalize)
+	m.findIn(canonical.text, options, func(start int, end int, pattern Pattern) {
+		decodings := canonical.decodedIn(start, end).list()
+		start, end = canonical.original(start, end)
+		found(start, end, pattern, decodings)
+	})
+}
+
+// findIn calls found for every match of every pattern in the text, the offsets are byte offsets into the text. A match
+// is reported once, even when it is found in more than one way.
+func (m *Matcher) findIn(text string, options Options, found func(start int, end int, pattern Pattern)) {
+	allowed := m.findAllowed(text)
+	type key struct {
+		start int
+		end   int
+		id    uint
+	}
+	reported := make(map[key]bool)
+	report := func(start int, end int, pattern Pattern) {
+		if !options.Categories.allows(pattern) {
+			return
+		}
+
+		for _, s := range allowed {
+			if s.start <= start && end <= s.end {
+				return
+			}
+		}
+
+		if reported[key{start, end, pattern.ID}] {
+			return
+		}
+		reported[key{start, end, pattern.ID}] = true
+		found(start, end, pattern)
+	}
+
+	m.automaton.findAll(text, func(start int, end int, index int) {
+		if literal := m.literals[index]; !literal.wholeWords() || isWholeWord(text, start, end) {
+			report(start, end, literal)
+		}
+	})
+
+	for _, e := range m.expressions {
+		findExpression(e.re, text, e.wholeWords, func(start int, end int) {
+			report(start, end, e.pattern)
+		})
+	}
+
+	if options.Obfuscation {
+		m.findObfuscated(text, report)
+	}
+}
+
+// findAllowed returns the spans of all the allowlist phrases in the text
+func (m *Matcher) findAllowed(text string) []span {
+	if m.allowed == nil {
+		return nil
+	}
+
+	var allowed []span
+	m.allowed.findAll(text, func(start int, end int, _ int) {
+		if isWholeWord(text, start, end) {
+			allowed = append(allowed, span{start: start, end: end})
+		}
+	})
+	return allowed
+}
@@ -0,0 +1,152 @@
+package logic
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxObfuscationGap is the largest number of separators allowed between two characters of an obfuscated word. An
+// inline comment counts as a single separator, no matter its length. Whitespace only separates the characters of an
+// obfuscated word when they stand alone, such as in "S E L E C T", since it otherwise separates ordinary words.
+const maxObfuscationGap = 3
+
+// substitutions maps the characters commonly used to disguise letters back to the letter they replace
+var substitutions = map[rune]rune{
+	'0': 'O',
+	'1': 'I',
+	'3': 'E',
+	'4': 'A',
+	'5': 'S',
+	'7': 'T',
+	'8': 'B',
+	'@': 'A',
+	'$': 'S',
+	'!': 'I',
+	'|': 'I',
+}
+
+// skeleton is a text reduced to the characters that make up words, with every separator and inline comment removed
+// and the common character substitutions reversed. For every byte of the skeleton it records where the character it
+// belongs to came from in the original text, so that a match in the skeleton can be masked in the original.
+type skeleton struct {
+	text string
+	// origins holds the original span of the character every byte of the text belongs to
+	origins []span
+	// gaps holds the number of separators that preceded the character every byte of the text belongs to
+	gaps []int
+	// spaced holds whether the separators that preceded the character every byte of the text belongs to include
+	// whitespace
+	spaced []bool
+	// letters holds whether the character every byte of the text belongs to was a letter in the original
+	letters []bool
+}
+
+// newSkeleton reduces the text to its skeleton
+func newSkeleton(text string) skeleton {
+	var result skeleton
+	var builder strings.Builder
+	builder.Grow(len(text))
+
+	gap, spaced := 0, false
+	for i := 0; i < len(text); {
+		//Inline comments are skipped as a whole and count as a single separator
+		if strings.HasPrefix(text[i:], "/*") {
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				i = len(text)
+			} else {
+				i += end + 4
+			}
+			gap++
+			continue
+		}
+
+		r, width := utf8.DecodeRuneInString(text[i:])
+		folded, ok := skeletonRune(r)
+		if !ok {
+			gap++
+			spaced = spaced || unicode.IsSpace(r)
+			i += width
+			continue
+		}
+
+		builder.WriteRune(folded)
+		for b := 0; b < utf8.RuneLen(folded); b++ {
+			result.origins = append(result.origins, span{start: i, end: i + width})
+			result.gaps = append(result.gaps, gap)
+			result.spaced = append(result.spaced, spaced)
+			result.letters = append(result.letters, unicode.IsLetter(r))
+		}
+		gap, spaced = 0, false
+		i += width
+	}
+
+	result.text = builder.String()
+	return result
+}
+
+// skeletonRune returns the character that is kept in the skeleton for the rune, or false when the rune is a separator
+func skeletonRune(r rune) (rune, bool) {
+	if substitute, ok := substitutions[r]; ok {
+		return substitute, true
+	}
+	if unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r) {
+		return foldRune(r), true
+	}
+	return 0, false
+}
+
+// isolated reports whether the character the byte of the text belongs to stood alone in the original text, with
+// separators or the edges of the text on both sides
+func (s skeleton) isolated(i int) bool {
+	start, end := i, i+1
+	for start > 0 && s.sameCharacter(start-1, i) {
+		start--
+	}
+	for end < len(s.text) && s.sameCharacter(end, i) {
+		end++
+	}
+	return (start == 0 || s.gaps[start] > 0) && (end == len(s.text) || s.gaps[end] > 0)
+}
+
+// sameCharacter reports whether two bytes of the text belong to the same character of the original text
+func (s skeleton) sameCharacter(i int, j int) bool {
+	return s.origins[i].start == s.origins[j].start && s.origins[i].end == s.origins[j].end
+}
+
+// skeletonPattern reduces a pattern to its skeleton, in the same way as the text it is compared against. The gaps of
+// the skeleton are the separators of the pattern itself.
+func skeletonPattern(pattern string) skeleton {
+	return newSkeleton(pattern)
+}
+
+// findObfuscated calls found for every match of the obfuscated automaton in the text. A match must be a whole word in
+// the original text, may not have more than maxObfuscationGap separators between two characters and must contain at
+// least one letter, so that plain numbers are never mistaken for a word. Whitespace between two characters is only
+// allowed where the pattern itself has a separator, or when both characters stand alone, so that ordinary words such
+// as "go to" are not mistaken for an obfuscated GOTO. The offsets are byte offsets into the text.
+func (m *Matcher) findObfuscated(text string, found func(start int, end int, pattern Pattern)) {
+	s := newSkeleton(text)
+	m.obfuscated.findAll(s.text, func(start int, end int, index int) {
+		pattern := m.obfuscatedSkeletons[index]
+		hasLetter := s.letters[start]
+		for i := start + 1; i < end; i++ {
+			if s.sameCharacter(i, i-1) {
+				continue
+			}
+			if s.gaps[i] > maxObfuscationGap {
+				return
+			}
+			if s.spaced[i] && pattern.gaps[i-start] == 0 && !(s.isolated(i-1) && s.isolated(i)) {
+				return
+			}
+			hasLetter = hasLetter || s.letters[i]
+		}
+
+		originalStart, originalEnd := s.origins[start].start, s.origins[end-1].end
+		if hasLetter && isWholeWord(text, originalStart, originalEnd) {
+			found(originalStart, originalEnd, m.obfuscatedPatterns[index])
+		}
+	})
+}
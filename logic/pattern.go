@@ -0,0 +1,173 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PatternType defines how the value of a stored word is interpreted when matching text
+type PatternType string
+
+const (
+	// Literal matches the value as is, every character including regular expression meta characters is escaped
+	Literal PatternType = "literal"
+	// Glob matches the value as a whole word, where * matches any number of word characters and ? exactly one
+	Glob PatternType = "glob"
+	// Regex matches the value as a case insensitive RE2 regular expression, word boundaries are not added implicitly
+	Regex PatternType = "regex"
+)
+
+// Boundary defines whether a match of a pattern must be a whole word
+type Boundary string
+
+const (
+	// BoundaryDefault matches literals and globs as whole words, and regular expressions anywhere in the text
+	BoundaryDefault Boundary = ""
+	// BoundaryWord only matches whole words, a match may not be part of a larger word
+	BoundaryWord Boundary = "word"
+	// BoundaryNone also matches inside larger words, for example SELECT in "PRESELECTED"
+	BoundaryNone Boundary = "none"
+)
+
+// ErrInvalidPattern is wrapped by every error reporting that a value is not a valid pattern of its type
+var ErrInvalidPattern = errors.New("invalid pattern")
+
+// wordClass is the regular expression equivalent of isWordRune, used by the wildcards of a glob
+const wordClass = `[\p{L}\p{N}\p{M}_]`
+
+// Pattern is a single stored word together with the way it should be matched
+type Pattern struct {
+	ID    uint
+	Value string
+	Type  PatternType
+	// Severity is used by a BlockPolicy, SeverityLow when not set
+	Severity Severity
+	// Categories are the normalized names of the categories of the word, see ParseCategory
+	Categories []string
+	// Replacement replaces a match instead of the mask options, when it is not empty
+	Replacement string
+	// CaseSensitive only matches text with the exact case of the value, literals that are case sensitive are not
+	// matched when obfuscated
+	CaseSensitive bool
+	Boundary      Boundary
+	// Disabled patterns are kept in the word list, but never matched
+	Disabled bool
+}
+
+// ParsePatternType converts the name of a pattern type to a PatternType, an empty name defaults to Literal
+func ParsePatternType(name string) (PatternType, error) {
+	switch PatternType(strings.ToLower(strings.TrimSpace(name))) {
+	case "", Literal:
+		return Literal, nil
+	case Glob:
+		return Glob, nil
+	case Regex:
+		return Regex, nil
+	default:
+		return "", fmt.Errorf("unsupported pattern type %q", name)
+	}
+}
+
+// ParseBoundary converts the name of a boundary mode to a Boundary, an empty name is BoundaryDefault
+func ParseBoundary(name string) (Boundary, error) {
+	switch Boundary(strings.ToLower(strings.TrimSpace(name))) {
+	case BoundaryDefault:
+		return BoundaryDefault, nil
+	case BoundaryWord:
+		return BoundaryWord, nil
+	case BoundaryNone:
+		return BoundaryNone, nil
+	default:
+		return "", fmt.Errorf("unsupported boundary %q", name)
+	}
+}
+
+// wholeWords reports whether a match of the pattern must be a whole word
+func (p Pattern) wholeWords() bool {
+	switch p.Boundary {
+	case BoundaryWord:
+		return true
+	case BoundaryNone:
+		return false
+	default:
+		return p.Type != Regex
+	}
+}
+
+// ValidatePattern checks whether the value is a valid pattern of the provided type. Patterns that would match every
+// word or an empty string are rejected, as they would sanitize everything.
+func ValidatePattern(value string, patternType PatternType) error {
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("%w: pattern is empty", ErrInvalidPattern)
+	}
+
+	switch patternType {
+	case Literal:
+		return nil
+	case Glob:
+		if strings.Trim(value, "*?") == "" {
+			return fmt.Errorf("%w: glob pattern does not contain any characters besides wildcards", ErrInvalidPattern)
+		}
+		return nil
+	case Regex:
+		re, err := compilePattern(value, patternType, false)
+		if err != nil {
+			return err
+		}
+		if re.MatchString("") {
+			return fmt.Errorf("%w: regular expression matches an empty string", ErrInvalidPattern)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported pattern type %q", patternType)
+	}
+}
+
+// compilePattern compiles a pattern into a regular expression, which is case insensitive unless caseSensitive is set.
+// Literals are only compiled when they are case sensitive, the other literals are matched by the automaton.
+func compilePattern(value string, patternType PatternType, caseSensitive bool) (*regexp.Regexp, error) {
+	flags := "(?i)"
+	if caseSensitive {
+		flags = ""
+	}
+
+	switch patternType {
+	case Literal:
+		return regexp.Compile(flags + regexp.QuoteMeta(value))
+	case Glob:
+		var expression strings.Builder
+		expression.WriteString(flags)
+		for _, r := range value {
+			switch r {
+			case '*':
+				expression.WriteString(wordClass + "*")
+			case '?':
+				expression.WriteString(wordClass)
+			default:
+				expression.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+		return regexp.Compile(expression.String())
+	case Regex:
+		re, err := regexp.Compile(flags + value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid regular expression: %w", ErrInvalidPattern, err)
+		}
+		return re, nil
+	default:
+		return nil, fmt.Errorf("pattern type %q can not be compiled to a regular expression", patternType)
+	}
+}
+
+// findExpression calls found for the matches of the regular expression in the text. The whole text is searched at once,
+// so that anchors such as ^ and \b are evaluated against the text and not against the remainder after a match. When
+// wholeWords is set, matches that are part of a larger word are skipped.
+func findExpression(re *regexp.Regexp, text string, wholeWords bool, found func(start int, end int)) {
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		if loc[0] < loc[1] && (!wholeWords || isWholeWord(text, loc[0], loc[1])) {
+			found(loc[0], loc[1])
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package logic
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Reason is a stable code explaining why a text received a risk score
+type Reason string
+
+const (
+	// ReasonKeyword is reported when the text contains words from the word list
+	ReasonKeyword Reason = "KEYWORD"
+	// ReasonComment is reported for SQL comment tokens, such as -- or /* */
+	ReasonComment Reason = "COMMENT"
+	// ReasonStackedStatement is reported when a ; is followed by another statement
+	ReasonStackedStatement Reason = "STACKED_STATEMENT"
+	// ReasonTautology is reported for conditions that are always true, such as ' OR 1=1
+	ReasonTautology Reason = "TAUTOLOGY"
+	// ReasonUnionSelect is reported for UNION SELECT chains
+	ReasonUnionSelect Reason = "UNION_SELECT"
+	// ReasonQuoteImbalance is reported when a quote is opened but never closed
+	ReasonQuoteImbalance Reason = "QUOTE_IMBALANCE"
+)
+
+// RiskLevel classifies a risk score
+type RiskLevel string
+
+const (
+	RiskNone   RiskLevel = "none"
+	RiskLow    RiskLevel = "low"
+	RiskMedium RiskLevel = "medium"
+	RiskHigh   RiskLevel = "high"
+)
+
+// MaxRiskScore is the highest score a text can receive
+const MaxRiskScore = 100
+
+// weights holds the score every reason adds, a keyword adds its weight for every distinct word up to maxKeywordScore
+var weights = map[Reason]int{
+	ReasonKeyword:          10,
+	ReasonComment:          20,
+	ReasonStackedStatement: 30,
+	ReasonTautology:        40,
+	ReasonUnionSelect:      40,
+	ReasonQuoteImbalance:   15,
+}
+
+const maxKeywordScore = 30
+
+// reasonOrder is the order in which the reasons of a text are reported
+var reasonOrder = []Reason{
+	ReasonKeyword,
+	ReasonComment,
+	ReasonStackedStatement,
+	ReasonTautology,
+	ReasonUnionSelect,
+	ReasonQuoteImbalance,
+}
+
+// separator matches whitespace and inline comments, which SQL accepts between any two tokens
+const separator = `(?:\s|/\*.*?\*/)+`
+
+var (
+	commentToken   = regexp.MustCompile(`--|/\*|'\s*#`)
+	stackedQuery   = regexp.MustCompile(`(?i);` + `(?:\s|/\*.*?\*/)*` + `\b(?:SELECT|INSERT|UPDATE|DELETE|DROP|CREATE|ALTER|TRUNCATE|EXEC|EXECUTE|DECLARE|GRANT|REVOKE|SHUTDOWN|WAITFOR)\b`)
+	unionSelect    = regexp.MustCompile(`(?i)\bUNION` + separator + `(?:(?:ALL|DISTINCT)` + separator + `)?SELECT\b`)
+	tautologyTrue  = regexp.MustCompile(`(?i)\bOR` + separator + `(?:TRUE|1)\b\s*(?:--|#|;|/\*|$)`)
+	tautologyEqual = regexp.MustCompile(`(?i)\bOR` + separator + `(['"]?)(\w+)['"]?\s*(?:=|LIKE\s)\s*(['"]?)(\w+)`)
+)
+
+// Risk is the injection risk of a single text. The score is between 0 and MaxRiskScore, and the reasons explain which
+// signals contributed to it.
+type Risk struct {
+	Score   int
+	Level   RiskLevel
+	Reasons []Reason
+	// Matches are the words from the word list that were found in the text, see Detect
+	Matches []Match
+}
+
+// Score rates how much every text looks like an SQL injection attempt, and returns the result per text in the same
+// order it was provided. The score combines the words from the word list found in the text with structural signals,
+// such as comment tokens, stacked statements, tautologies, UNION SELECT chains and unbalanced quotes. In the case of an
+// error the method will return an empty list, and the appropriate error
+func (m *Matcher) Score(textToScore []string) (result []Risk, err error) {
+	return m.ScoreWith(textToScore, Options{})
+}
+
+// ScoreWith rates the text the same way as Score, matching according to the provided options. The structural signals
+// are also searched for in the canonicalized and decoded text. The mask options are ignored.
+func (m *Matcher) ScoreWith(textToScore []string, options Options) (result []Risk, err error) {
+	for _, text := range textToScore {
+		result = append(result, m.score(text, options))
+	}
+
+	return result, nil
+}
+
+// score calculates the risk of a single text
+func (m *Matcher) score(text string, options Options) Risk {
+	risk := Risk{Matches: m.matches(text, options)}
+
+	found := make(map[Reason]bool)
+	views := []string{mappedText{text: text}.canonicalize(options.Canonicalize).text}
+	if len(options.Decode) > 0 {
+		if decoded := decode(text, options.Decode, options.DecodeDepth); decoded.decoded != nil {
+			views = append(views, decoded.canonicalize(options.Canonicalize).text)
+		}
+	}
+	for _, view := range views {
+		for _, reason := range structuralReasons(view) {
+			found[reason] = true
+		}
+	}
+
+	words := make(map[uint]bool)
+	for _, match := range risk.Matches {
+		words[match.Pattern.ID] = true
+	}
+	if len(words) > 0 {
+		found[ReasonKeyword] = true
+		risk.Score += min(len(words)*weights[ReasonKeyword], maxKeywordScore)
+	}
+
+	for _, reason := range reasonOrder {
+		if !found[reason] {
+			continue
+		}
+		risk.Reasons = append(risk.Reasons, reason)
+		if reason != ReasonKeyword {
+			risk.Score += weights[reason]
+		}
+	}
+
+	risk.Score = min(risk.Score, MaxRiskScore)
+	risk.Level = riskLevel(risk.Score)
+	return risk
+}
+
+// structuralReasons returns the structural signals found in the text
+func structuralReasons(text string) (reasons []Reason) {
+	if commentToken.MatchString(text) {
+		reasons = append(reasons, ReasonComment)
+	}
+	if stackedQuery.MatchString(text) {
+		reasons = append(reasons, ReasonStackedStatement)
+	}
+	if isTautology(text) {
+		reasons = append(reasons, ReasonTautology)
+	}
+	if unionSelect.MatchString(text) {
+		reasons = append(reasons, ReasonUnionSelect)
+	}
+	if hasUnbalancedQuotes(text) {
+		reasons = append(reasons, ReasonQuoteImbalance)
+	}
+	return reasons
+}
+
+// isTautology reports whether the text contains an OR condition that is always true, such as OR 1=1, ' OR 'a'='a or
+// a trailing OR TRUE
+func isTautology(text string) bool {
+	if tautologyTrue.MatchString(text) {
+		return true
+	}
+
+	//RE2 does not support back references, so the two sides of the comparison are compared afterward
+	for _, groups := range tautologyEqual.FindAllStringSubmatch(text, -1) {
+		if strings.EqualFold(groups[2], groups[4]) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasUnbalancedQuotes reports whether a single or double quote is left open. Doubled quotes are escaped quotes, and an
+// apostrophe between two letters, as in "don't", is not a quote.
+func hasUnbalancedQuotes(text string) bool {
+	var open rune
+	var previous rune
+	for i := 0; i < len(text); {
+		r, width := utf8.DecodeRuneInString(text[i:])
+		i += width
+		next, _ := utf8.DecodeRuneInString(text[i:])
+
+		switch {
+		case r != '\'' && r != '"':
+		case open == 0 && r == '\'' && isWordRune(previous) && isWordRune(next):
+		case open == 0:
+			open = r
+		case r == open && next == open:
+			//An escaped quote inside a quoted string, skipping the second quote
+			i += width
+			r = 0
+		case r == open:
+			open = 0
+		}
+		previous = r
+	}
+
+	return open != 0
+}
+
+// riskLevel classifies a risk score
+func riskLevel(score int) RiskLevel {
+	switch {
+	case score == 0:
+		return RiskNone
+	case score < 30:
+		return RiskLow
+	case score < 60:
+		return RiskMedium
+	default:
+		return RiskHigh
+	}
+}
@@ -0,0 +1,113 @@
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Severity ranks how harmful a stored word is, a higher severity is more harmful
+type Severity int
+
+const (
+	SeverityLow Severity = iota + 1
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityLow:      "low",
+	SeverityMedium:   "medium",
+	SeverityHigh:     "high",
+	SeverityCritical: "critical",
+}
+
+// ParseSeverity converts the name of a severity to a Severity, an empty name defaults to SeverityLow
+func ParseSeverity(name string) (Severity, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return SeverityLow, nil
+	}
+
+	for severity, severityName := range severityNames {
+		if severityName == name {
+			return severity, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported severity %q", name)
+}
+
+// String returns the name of the severity
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return severityNames[SeverityLow]
+}
+
+// MarshalText stores the severity by its name
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText reads the name of a severity, see ParseSeverity
+func (s *Severity) UnmarshalText(text []byte) error {
+	severity, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = severity
+	return nil
+}
+
+// UnmarshalJSON reads the name of a severity, or its number as it was stored before severities were stored by name
+func (s *Severity) UnmarshalJSON(data []byte) error {
+	var number int
+	if err := json.Unmarshal(data, &number); err == nil {
+		*s = Severity(number)
+		return nil
+	}
+
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	return s.UnmarshalText([]byte(name))
+}
+
+// BlockPolicy defines when a text is refused instead of sanitized. A text is blocked when it contains a word of at
+// least the Severity, or at least Matches matches. A zero value never blocks anything.
+type BlockPolicy struct {
+	Severity Severity
+	Matches  int
+}
+
+// Validate checks whether the policy can be applied
+func (p BlockPolicy) Validate() error {
+	if p.Severity != 0 {
+		if _, ok := severityNames[p.Severity]; !ok {
+			return fmt.Errorf("unsupported severity %d", p.Severity)
+		}
+	}
+	if p.Matches < 0 {
+		return fmt.Errorf("the block match count can not be negative")
+	}
+	return nil
+}
+
+// Blocks reports whether the matches of a text exceed the policy
+func (p BlockPolicy) Blocks(matches []Match) bool {
+	if p.Matches > 0 && len(matches) >= p.Matches {
+		return true
+	}
+
+	if p.Severity > 0 {
+		for _, match := range matches {
+			if max(match.Pattern.Severity, SeverityLow) >= p.Severity {
+				return true
+			}
+		}
+	}
+	return false
+}
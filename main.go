@@ -1,12 +1,17 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"github.com/gin-gonic/gin"
 	"log"
+	"net"
+	"net/url"
 	"os"
 	"sanitize/controller"
 	"sanitize/data"
+	"strconv"
+	"strings"
+	"time"
 
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -26,6 +31,7 @@ import (
 // @host		localhost:8080
 //	@BasePath	/api/v1
 
+var dbConnection = os.Getenv("dbConnection")
 var dbUsername = os.Getenv("dbUsername")
 var dbPassword = os.Getenv("dbPassword")
 var dbHost = os.Getenv("dbHost")
@@ -33,21 +39,97 @@ var dbPort = os.Getenv("dbPort")
 var dbDatabase = os.Getenv("dbDatabase")
 var servicePort = os.Getenv("servicePort")
 var swaggerInterface = os.Getenv("swaggerInterface")
+var maskStrategy = os.Getenv("maskStrategy")
+var maskCharacter = os.Getenv("maskCharacter")
+var maskToken = os.Getenv("maskToken")
+var maskRevealFirst = os.Getenv("maskRevealFirst")
+var maskRevealLast = os.Getenv("maskRevealLast")
+var maskLabel = os.Getenv("maskLabel")
+var maskHashKey = os.Getenv("maskHashKey")
+var matchObfuscation = os.Getenv("matchObfuscation")
+var matchCanonicalize = os.Getenv("matchCanonicalize")
+var matchDecode = os.Getenv("matchDecode")
+var matchDecodeDepth = os.Getenv("matchDecodeDepth")
+var blockSeverity = os.Getenv("blockSeverity")
+var blockMatches = os.Getenv("blockMatches")
+var apiKeys = os.Getenv("apiKeys")
+var tenants = os.Getenv("tenants")
+var storeFile = os.Getenv("storeFile")
+var seedDirectory = os.Getenv("seedDirectory")
+var watchInterval = os.Getenv("watchInterval")
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrate(os.Args[2:])
+		return
+	}
+
 	log.Println("Starting Service...")
 
-	log.Println("Setting up database")
-	db, err := data.Initialize(fmt.Sprintf("%s;%s://%s:%s@%s:%s?database=%s",
-		"sqlserver", "sqlserver", dbUsername, dbPassword, dbHost, dbPort, dbDatabase))
+	store, err := openStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r := gin.New()
+	r.Use(gin.Logger(), gin.CustomRecovery(controller.Recover), controller.RequestID)
+	c, err := controller.NewController(store)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	//Changes made by other instances of the service, or to the store file, are only picked up when watched
+	if interval := envInt(watchInterval); interval > 0 {
+		err = c.Watch(context.Background(), time.Duration(interval)*time.Second)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	err = c.SetMaskDefaults(controller.Mask{
+		Strategy:    maskStrategy,
+		Character:   maskCharacter,
+		Token:       maskToken,
+		RevealFirst: envOptionalInt(maskRevealFirst),
+		RevealLast:  envOptionalInt(maskRevealLast),
+		Label:       maskLabel,
+	}, maskHashKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.SetObfuscationDefault(matchObfuscation == "true")
+	if matchCanonicalize != "" {
+		err = c.SetCanonicalizationDefault(strings.Split(matchCanonicalize, ","))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if matchDecode != "" {
+		err = c.SetDecodingDefault(strings.Split(matchDecode, ","), envInt(matchDecodeDepth))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	err = c.SetBlockDefault(controller.Block{Severity: blockSeverity, Matches: envInt(blockMatches)})
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	r := gin.Default()
-	c := controller.NewController(&db)
+	if apiKeys != "" {
+		err = c.SetAPIKeys(envKeys(apiKeys))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if tenants != "" {
+		err = c.SetTenants(strings.Split(tenants, ","))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	v1 := r.Group("/api/v1")
+	v1.Use(c.ResolveTenant)
 	{
 		words := v1.Group("/words")
 		{
@@ -55,19 +137,51 @@ func main() {
 			words.PUT("", c.AddWords)
 			words.POST("", c.UpdateWords)
 			words.DELETE("", c.DeleteWords)
+			words.POST("/enabled", c.EnableWords)
+			words.GET("/categories", c.ListCategories)
+			words.PUT("/categories", c.AddCategories)
+			words.DELETE("/categories", c.DeleteCategories)
+		}
+		allowlist := v1.Group("/allowlist")
+		{
+			allowlist.GET("", c.ListAllowedPhrases)
+			allowlist.PUT("", c.AddAllowedPhrases)
+			allowlist.POST("", c.UpdateAllowedPhrases)
+			allowlist.DELETE("", c.DeleteAllowedPhrases)
+		}
+		policies := v1.Group("/policies")
+		{
+			policies.GET("", c.ListPolicies)
+			policies.GET("/:name", c.GetPolicy)
+			policies.PUT("", c.AddPolicy)
+			policies.POST("", c.UpdatePolicy)
+			policies.DELETE("/:name", c.DeletePolicy)
 		}
 		sanitized := v1.Group("/sanitize")
 		{
 			sanitized.POST("", c.Sanitize)
 		}
+		detect := v1.Group("/detect")
+		{
+			detect.POST("", c.Detect)
+		}
+		score := v1.Group("/score")
+		{
+			score.POST("", c.Score)
+		}
+		versions := v1.Group("/versions")
+		{
+			versions.GET("", c.ListVersions)
+			versions.GET("/diff", c.DiffVersions)
+			versions.POST("/:version/rollback", c.RollbackVersion)
+		}
 	}
 
-	if _, err := os.Stat("sql_sensitive_list.json"); err == nil {
-		log.Printf("Moving sample data to imported")
-		err := os.Rename("sql_sensitive_list.json", "sql_sensitive_list.imported")
-		if err != nil {
-			fmt.Printf("An error occured while renaming the file => %v", err)
-		}
+	//Version 2 only contains the endpoints whose requests changed, all other endpoints remain version 1
+	v2 := r.Group("/api/v2")
+	v2.Use(c.ResolveTenant)
+	{
+		v2.POST("/words", c.UpdateWordPairs)
 	}
 
 	if swaggerInterface == "true" {
@@ -80,3 +194,76 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// openStore opens the word store, the file store when a store file is configured and otherwise the database
+func openStore() (data.WordStore, error) {
+	if storeFile != "" {
+		log.Printf("Setting up file store %s", storeFile)
+		return data.OpenFileStore(storeFile)
+	}
+
+	log.Println("Setting up database")
+	db, err := data.Initialize(databaseConnection())
+	if err != nil {
+		return nil, err
+	}
+
+	//Every seed set is imported once per database, so the directory can be part of the image
+	if seedDirectory != "" {
+		reports, err := db.SeedDirectory(seedDirectory)
+		for _, report := range reports {
+			log.Printf("Seed data: %v", report)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &db, nil
+}
+
+// databaseConnection returns the URL of the database, the separate settings are kept for existing SQL Server deployments
+func databaseConnection() string {
+	if dbConnection != "" {
+		return dbConnection
+	}
+
+	return (&url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword(dbUsername, dbPassword),
+		Host:     net.JoinHostPort(dbHost, dbPort),
+		RawQuery: url.Values{"database": {dbDatabase}}.Encode(),
+	}).String()
+}
+
+// envInt converts an optional numeric environment variable, an empty value is zero
+func envInt(value string) int {
+	if value == "" {
+		return 0
+	}
+
+	result, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Invalid numeric setting %q: %v", value, err)
+	}
+	return result
+}
+
+// envOptionalInt converts an optional numeric environment variable, an empty value is not set
+func envOptionalInt(value string) *int {
+	if value == "" {
+		return nil
+	}
+
+	result := envInt(value)
+	return &result
+}
+
+// envKeys converts a comma separated list of key:tenant pairs, a key without a tenant belongs to the shared tenant
+func envKeys(value string) map[string]string {
+	keys := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		key, tenant, _ := strings.Cut(strings.TrimSpace(pair), ":")
+		keys[key] = tenant
+	}
+	return keys
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sanitize/data"
+	"strconv"
+)
+
+// migrate manages the schema of the database configured for the service, without starting it:
+//
+//	sanitize migrate              applies every pending migration
+//	sanitize migrate up [version] applies the pending migrations up to the version
+//	sanitize migrate down version reverts the migrations after the version, 0 drops every table
+//	sanitize migrate status       lists the migrations and whether they were applied
+func migrate(args []string) {
+	db, err := data.Open(databaseConnection())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch {
+	case command == "status" && len(args) == 1:
+		migrations, err := db.Migrations()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, m := range migrations {
+			applied := "pending"
+			if !m.AppliedAt.IsZero() {
+				applied = m.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d  %-19s  %s\n", m.Version, applied, m.Name)
+		}
+
+	case command == "up" && len(args) <= 2:
+		var target uint
+		if len(args) == 2 {
+			target = migrationVersion(args[1])
+		}
+		applied, err := db.MigrateUp(target)
+		for _, m := range applied {
+			log.Printf("Applied migration %d %s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+	case command == "down" && len(args) == 2:
+		reverted, err := db.MigrateDown(migrationVersion(args[1]))
+		for _, m := range reverted {
+			log.Printf("Reverted migration %d %s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+	default:
+		log.Fatal("Usage: sanitize migrate [status | up [version] | down version]")
+	}
+
+	version, err := db.SchemaVersion()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Schema version %d", version)
+}
+
+// migrationVersion converts the version argument of the migrate command
+func migrationVersion(value string) uint {
+	version, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		log.Fatalf("Invalid migration version %q", value)
+	}
+	return uint(version)
+}
@@ -0,0 +1,32 @@
+package testdata
+
+// Unicode is a corpus of sentences containing multi-byte characters, with the expected result once sanitized
+// against Data. It covers accented Latin scripts, combining marks, scripts written without spaces and emoji.
+var Unicode = []struct {
+	Sentence  string
+	Sanitized string
+}{
+	// Afrikaans
+	{"Sê vir hom om nie SELECT * FROM te gebruik nie", "Sê vir hom om nie ************* te gebruik nie"},
+	{"Môre gaan ons die tabel drop, néé?", "Môre gaan ons die tabel ****, néé?"},
+	{"Hy het ’n insert geskryf én ’n update", "Hy het ’n ****** geskryf én ’n ******"},
+	// Zulu
+	{"Ngicela ungabhali u-DROP etafuleni", "Ngicela ungabhali u-**** etafuleni"},
+	{"Sawubona! Ungasebenzisi i-delete noma i-update", "Sawubona! Ungasebenzisi i-****** noma i-******"},
+	// French
+	{"Évitez d’utiliser DROP TABLE élèves", "Évitez d’utiliser **** ***** élèves"},
+	{"À éviter : « select » où « insert »", "À éviter : « ****** » où « ****** »"},
+	{"Créez l’index déjà", "Créez l’***** déjà"},
+	{"Le paramètre SETé reste visible", "Le paramètre SETé reste visible"},
+	{"un café́ ON passe", "un café́ ** passe"},
+	{"café ONé", "café ONé"},
+	// Scripts written without spaces
+	{"请不要使用DROP语句", "请不要使用****语句"},
+	{"テーブルをDROPしないでください", "テーブルを****しないでください"},
+	{"กรุณาอย่าใช้DELETEครับ", "กรุณาอย่าใช้******ครับ"},
+	// Emoji and characters that change length when the case is changed
+	{"🔥DROP🔥 the 表", "🔥****🔥 the 表"},
+	{"👩‍💻 wrote SELECT again 👍🏽", "👩‍💻 wrote ****** again 👍🏽"},
+	{"ıı drop ſelect", "ıı **** ******"},
+	{"Ⱥ grant ȿ", "Ⱥ ***** ȿ"},
+}